@@ -0,0 +1,183 @@
+package ast
+
+// Copy returns a deep copy of the tree rooted at node, so that it can be
+// rewritten with Modify while the original stays intact.
+func Copy(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		return &Program{Statements: copyStatements(n.Statements)}
+
+	case *LetStatement:
+		c := *n
+		c.Name = copyIdentifier(n.Name)
+		c.Value = copyExpression(n.Value)
+		return &c
+
+	case *ReturnStatement:
+		c := *n
+		c.ReturnValue = copyExpression(n.ReturnValue)
+		return &c
+
+	case *ThrowStatement:
+		c := *n
+		c.Value = copyExpression(n.Value)
+		return &c
+
+	case *ExpressionStatement:
+		c := *n
+		c.Expression = copyExpression(n.Expression)
+		return &c
+
+	case *BlockStatement:
+		c := *n
+		c.Statements = copyStatements(n.Statements)
+		return &c
+
+	case *Identifier:
+		c := *n
+		return &c
+
+	case *IntegerLiteral:
+		c := *n
+		return &c
+
+	case *BooleanLiteral:
+		c := *n
+		return &c
+
+	case *StringLiteral:
+		c := *n
+		return &c
+
+	case *PrefixExpression:
+		c := *n
+		c.Right = copyExpression(n.Right)
+		return &c
+
+	case *InfixExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Right = copyExpression(n.Right)
+		return &c
+
+	case *IfExpression:
+		c := *n
+		c.Condition = copyExpression(n.Condition)
+		c.Consequence = copyBlock(n.Consequence)
+		c.Alternative = copyBlock(n.Alternative)
+		return &c
+
+	case *FunctionLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = copyBlock(n.Body)
+		return &c
+
+	case *MacroLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = copyBlock(n.Body)
+		return &c
+
+	case *CallExpression:
+		c := *n
+		c.Function = copyExpression(n.Function)
+		c.Arguments = copyExpressions(n.Arguments)
+		return &c
+
+	case *ArrayLiteral:
+		c := *n
+		c.Elements = copyExpressions(n.Elements)
+		return &c
+
+	case *HashLiteral:
+		c := *n
+		c.Keys = make([]Expression, len(n.Keys))
+		c.Pairs = make(map[Expression]Expression, len(n.Pairs))
+		for i, key := range n.Keys {
+			c.Keys[i] = copyExpression(key)
+			c.Pairs[c.Keys[i]] = copyExpression(n.Pairs[key])
+		}
+		return &c
+
+	case *IndexExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Index = copyExpression(n.Index)
+		return &c
+
+	case *SliceExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Start = copyExpression(n.Start)
+		c.End = copyExpression(n.End)
+		return &c
+
+	case *TryExpression:
+		c := *n
+		c.Block = copyBlock(n.Block)
+		c.Param = copyIdentifier(n.Param)
+		c.Catch = copyBlock(n.Catch)
+		c.Finally = copyBlock(n.Finally)
+		return &c
+	}
+
+	return node
+}
+
+func copyStatements(list []Statement) []Statement {
+	if list == nil {
+		return nil
+	}
+
+	c := make([]Statement, len(list))
+	for i, stmt := range list {
+		c[i] = Copy(stmt).(Statement)
+	}
+	return c
+}
+
+func copyExpressions(list []Expression) []Expression {
+	if list == nil {
+		return nil
+	}
+
+	c := make([]Expression, len(list))
+	for i, e := range list {
+		c[i] = copyExpression(e)
+	}
+	return c
+}
+
+func copyExpression(e Expression) Expression {
+	if e == nil {
+		return nil
+	}
+	return Copy(e).(Expression)
+}
+
+func copyBlock(b *BlockStatement) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	return Copy(b).(*BlockStatement)
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return Copy(ident).(*Identifier)
+}
+
+func copyIdentifiers(list []*Identifier) []*Identifier {
+	if list == nil {
+		return nil
+	}
+
+	c := make([]*Identifier, len(list))
+	for i, ident := range list {
+		c[i] = copyIdentifier(ident)
+	}
+	return c
+}
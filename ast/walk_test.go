@@ -0,0 +1,238 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+func nodeName(node ast.Node) string {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return node.Value
+	case *ast.IntegerLiteral:
+		return node.Token.Literal
+	case *ast.StringLiteral:
+		return fmt.Sprintf("%q", node.Value)
+	case *ast.BooleanLiteral:
+		return node.Token.Literal
+	default:
+		return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = -1 + 2;", "Program LetStatement x InfixExpression PrefixExpression 1 2"},
+		{"return true; throw false;", "Program ReturnStatement true ThrowStatement false"},
+		{
+			"if (a) { b } else { c }",
+			"Program ExpressionStatement IfExpression a BlockStatement ExpressionStatement b BlockStatement ExpressionStatement c",
+		},
+		{"fn(a, b) { a }", "Program ExpressionStatement FunctionLiteral a b BlockStatement ExpressionStatement a"},
+		{"f(1, [2])", "Program ExpressionStatement CallExpression f 1 ArrayLiteral 2"},
+		{`{"a": 1, b: 2}`, `Program ExpressionStatement HashLiteral "a" 1 b 2`},
+		{"x[0]; x.y; x[1:]; x[:2]", `Program ExpressionStatement IndexExpression x 0 ExpressionStatement IndexExpression x "y" ExpressionStatement SliceExpression x 1 ExpressionStatement SliceExpression x 2`},
+		{
+			"try { 1 } catch (e) { 2 } finally { 3 }",
+			"Program ExpressionStatement TryExpression BlockStatement ExpressionStatement 1 e BlockStatement ExpressionStatement 2 BlockStatement ExpressionStatement 3",
+		},
+	}
+
+	for _, tt := range tests {
+		var names []string
+		ast.Inspect(parse(t, tt.input), func(node ast.Node) bool {
+			if node != nil {
+				names = append(names, nodeName(node))
+			}
+			return true
+		})
+
+		if actual := strings.Join(names, " "); actual != tt.expected {
+			t.Errorf("wrong traversal for %q.\nwant = %s\ngot  = %s", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	var names []string
+	ast.Inspect(parse(t, "let f = fn(a) { a }; f(1)"), func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+		names = append(names, nodeName(node))
+		_, isFn := node.(*ast.FunctionLiteral)
+		return !isFn
+	})
+
+	expected := "Program LetStatement f FunctionLiteral ExpressionStatement CallExpression f 1"
+	if actual := strings.Join(names, " "); actual != expected {
+		t.Errorf("wrong traversal.\nwant = %s\ngot  = %s", expected, actual)
+	}
+}
+
+// counter counts the nodes below each node, checking that every Visit(nil)
+// matches a node.
+type counter struct {
+	depth *int
+	max   *int
+}
+
+func (c counter) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*c.depth--
+		return nil
+	}
+
+	*c.depth++
+	if *c.depth > *c.max {
+		*c.max = *c.depth
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	depth, max := 0, 0
+	ast.Walk(counter{&depth, &max}, parse(t, "let f = fn(a) { [a + 1] };"))
+
+	if depth != 0 {
+		t.Errorf("unbalanced Visit(nil) calls. depth = %d", depth)
+	}
+	// Program, LetStatement, FunctionLiteral, BlockStatement,
+	// ExpressionStatement, ArrayLiteral, InfixExpression, Identifier.
+	if max != 8 {
+		t.Errorf("wrong depth. want = 8, got = %d", max)
+	}
+}
+
+func TestModify(t *testing.T) {
+	one := func() ast.Expression { return &ast.IntegerLiteral{Value: 1} }
+	two := func() ast.Expression { return &ast.IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node ast.Node) ast.Node {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    ast.Node
+		expected ast.Node
+	}{
+		{one(), two()},
+		{
+			&ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+			&ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&ast.InfixExpression{Left: one(), Operator: "+", Right: one()},
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{&ast.PrefixExpression{Operator: "-", Right: one()}, &ast.PrefixExpression{Operator: "-", Right: two()}},
+		{&ast.IndexExpression{Left: one(), Index: one()}, &ast.IndexExpression{Left: two(), Index: two()}},
+		{&ast.SliceExpression{Left: one(), End: one()}, &ast.SliceExpression{Left: two(), End: two()}},
+		{
+			&ast.IfExpression{
+				Condition:   one(),
+				Consequence: &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+				Alternative: &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+			},
+			&ast.IfExpression{
+				Condition:   two(),
+				Consequence: &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+				Alternative: &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ast.ReturnStatement{ReturnValue: one()}, &ast.ReturnStatement{ReturnValue: two()}},
+		{&ast.ThrowStatement{Value: one()}, &ast.ThrowStatement{Value: two()}},
+		{&ast.LetStatement{Value: one()}, &ast.LetStatement{Value: two()}},
+		{
+			&ast.FunctionLiteral{
+				Parameters: []*ast.Identifier{},
+				Body:       &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+			},
+			&ast.FunctionLiteral{
+				Parameters: []*ast.Identifier{},
+				Body:       &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&ast.CallExpression{Function: one(), Arguments: []ast.Expression{one(), one()}},
+			&ast.CallExpression{Function: two(), Arguments: []ast.Expression{two(), two()}},
+		},
+		{&ast.ArrayLiteral{Elements: []ast.Expression{one(), one()}}, &ast.ArrayLiteral{Elements: []ast.Expression{two(), two()}}},
+		{
+			&ast.TryExpression{
+				Block:   &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+				Finally: &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+			},
+			&ast.TryExpression{
+				Block:   &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+				Finally: &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := ast.Modify(tt.input, turnOneIntoTwo)
+
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal.\nwant = %#v\ngot  = %#v", tt.expected, modified)
+		}
+	}
+
+	hash := &ast.HashLiteral{
+		Pairs: map[ast.Expression]ast.Expression{},
+		Keys:  []ast.Expression{one(), one()},
+	}
+	hash.Pairs[hash.Keys[0]] = one()
+	hash.Pairs[hash.Keys[1]] = one()
+
+	ast.Modify(hash, turnOneIntoTwo)
+
+	for _, key := range hash.Keys {
+		if key.(*ast.IntegerLiteral).Value != 2 {
+			t.Errorf("key was not modified. got = %d", key.(*ast.IntegerLiteral).Value)
+		}
+		value, ok := hash.Pairs[key]
+		if !ok || value.(*ast.IntegerLiteral).Value != 2 {
+			t.Errorf("value was not modified. got = %v", value)
+		}
+	}
+}
+
+func TestModifyIgnoresWrongKind(t *testing.T) {
+	program := parse(t, "1 + 2")
+
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.IntegerLiteral); ok {
+			return &ast.LetStatement{}
+		}
+		return node
+	})
+
+	if program.String() != "(1 + 2)" {
+		t.Errorf("wrong program. got = %q", program.String())
+	}
+}
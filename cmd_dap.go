@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"monkey/dap"
+	"os"
+	"strings"
+)
+
+const dapUsage = `usage: monkey dap
+
+Serves the Debug Adapter Protocol on stdin and stdout, for debugging
+programs on the VM from an editor. The launch request takes the file to
+run as "program", and "stopOnEntry" to stop before its first line.
+
+`
+
+func runDAP(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, dapUsage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	server := dap.NewServer(os.Stdin, os.Stdout)
+	server.Load = func(path string) (*dap.Program, error) {
+		prog, err := compileFile(path)
+		if err != nil {
+			var out strings.Builder
+			prog.renderErrors(&out, false, err)
+			return nil, errors.New(strings.TrimRight(out.String(), "\n"))
+		}
+
+		return &dap.Program{
+			Bytecode:       prog.bytecode,
+			Globals:        prog.globals,
+			LibraryGlobals: prog.libraryGlobals,
+			Sources:        prog.sources,
+		}, nil
+	}
+
+	if err := server.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "monkey dap:", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"monkey/debug"
+	"monkey/vm"
+	"os"
+)
+
+const debugUsage = `usage: monkey debug file.mk
+
+Runs a file on the VM under a debugger, stopped before its first line.
+Type help at the (mdb) prompt for the list of commands.
+
+`
+
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, debugUsage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	name := flags.Arg(0)
+
+	prog, err := compileFile(name)
+	if err != nil {
+		prog.reportErrors(err)
+		return 1
+	}
+
+	console := debug.NewConsole(os.Stdin, os.Stdout, name, prog.sources)
+
+	debugger := debug.New(prog.bytecode.GlobalNames)
+	debugger.Paused = console.Paused
+	debugger.StopOnEntry = true
+	debugger.LibraryGlobals = prog.libraryGlobals
+
+	machine := vm.NewWithGlobalStore(prog.bytecode, prog.globals)
+	machine.SetHook(debugger)
+
+	if err := machine.Run(); err != nil {
+		if errors.Is(err, debug.ErrQuit) {
+			return 0
+		}
+
+		prog.reportErrors(err)
+		return 1
+	}
+
+	fmt.Println("program exited")
+	return 0
+}
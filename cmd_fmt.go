@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/diag"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+)
+
+const fmtUsage = `usage: monkey fmt [-w] [-check] [files...]
+
+Formats monkey source files. With no files, formats standard input.
+`
+
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of standard output")
+	check := flags.Bool("check", false, "list files that are not formatted and exit with status 1")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, fmtUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "monkey fmt: cannot use -w with standard input")
+			return 2
+		}
+
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "monkey fmt:", err)
+			return 1
+		}
+
+		out, ok := formatFile("", string(src))
+		if !ok {
+			return 1
+		}
+		if *check {
+			if out != string(src) {
+				fmt.Println("<standard input>")
+				return 1
+			}
+			return 0
+		}
+
+		fmt.Print(out)
+		return 0
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "monkey fmt:", err)
+			status = 1
+			continue
+		}
+
+		out, ok := formatFile(name, string(src))
+		if !ok {
+			status = 1
+			continue
+		}
+
+		changed := !bytes.Equal(src, []byte(out))
+		switch {
+		case *check:
+			if changed {
+				fmt.Println(name)
+				status = 1
+			}
+		case *write:
+			if changed {
+				if err := os.WriteFile(name, []byte(out), 0644); err != nil {
+					fmt.Fprintln(os.Stderr, "monkey fmt:", err)
+					status = 1
+				}
+			}
+		default:
+			fmt.Print(out)
+		}
+	}
+
+	return status
+}
+
+// formatFile formats src, rendering its syntax errors to standard error if
+// it does not parse.
+func formatFile(name, src string) (string, bool) {
+	l := lexer.NewFile(name, src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		renderer := &diag.Renderer{Sources: map[string]string{name: src}, Color: diag.ColorEnabled(os.Stderr)}
+		for _, err := range errs {
+			renderer.Render(os.Stderr, diag.FromError(err))
+		}
+		return "", false
+	}
+
+	return format.Program(program, l.Comments(), src), true
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"monkey/diag"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/parser"
+	"os"
+	"strings"
+)
+
+const lintUsage = `usage: monkey lint [-rules list] [-json] files...
+
+Reports common mistakes in monkey source files. The rules are:
+
+  unused       let bindings inside functions that are never used
+  shadow       parameters redeclared by let or repeated in the parameter list
+  arity        builtins called with the wrong number of arguments
+  unreachable  statements after return or throw
+
+`
+
+// jsonIssue is the form of an issue in -json output.
+type jsonIssue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	rules := flags.String("rules", strings.Join(lint.Rules, ","), "comma-separated rules to run")
+	asJSON := flags.Bool("json", false, "print issues as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, lintUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	selected := strings.Split(*rules, ",")
+	for _, rule := range selected {
+		if !isRule(rule) {
+			fmt.Fprintf(os.Stderr, "monkey lint: unknown rule %q\n", rule)
+			return 2
+		}
+	}
+
+	status := 0
+	sources := map[string]string{}
+	issues := []lint.Issue{}
+
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "monkey lint:", err)
+			status = 1
+			continue
+		}
+		sources[name] = string(src)
+
+		p := parser.New(lexer.NewFile(name, string(src)))
+		program := p.ParseProgram()
+		if errs := p.Errors(); len(errs) != 0 {
+			renderer := &diag.Renderer{Sources: sources, Color: diag.ColorEnabled(os.Stderr)}
+			for _, err := range errs {
+				renderer.Render(os.Stderr, diag.FromError(err))
+			}
+			status = 1
+			continue
+		}
+
+		issues = append(issues, lint.Check(program, selected...)...)
+	}
+
+	if len(issues) != 0 {
+		status = 1
+	}
+
+	if *asJSON {
+		out := make([]jsonIssue, len(issues))
+		for i, issue := range issues {
+			out[i] = jsonIssue{
+				File:    issue.Pos.Filename,
+				Line:    issue.Pos.Line,
+				Column:  issue.Pos.Column,
+				Rule:    issue.Rule,
+				Message: issue.Message,
+			}
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintln(os.Stderr, "monkey lint:", err)
+			return 1
+		}
+		return status
+	}
+
+	renderer := &diag.Renderer{Sources: sources, Color: diag.ColorEnabled(os.Stdout)}
+	for _, issue := range issues {
+		renderer.Render(os.Stdout, issue.Diagnostic())
+	}
+
+	return status
+}
+
+func isRule(name string) bool {
+	for _, rule := range lint.Rules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/lsp"
+	"os"
+)
+
+const lspUsage = `usage: monkey lsp
+
+Serves the Language Server Protocol on stdin and stdout, for editor
+support of monkey source files.
+
+`
+
+func runLSP(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, lspUsage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "monkey lsp:", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/eval"
+	"monkey/object"
+	"monkey/profile"
+	"monkey/stdlib"
+	"monkey/trace"
+	"monkey/vm"
+	"os"
+	"strings"
+)
+
+const runUsage = `usage: monkey run [-engine vm|eval] [-profile file] [-sample interval]
+                 [-trace] [-trace-json] [-trace-func list] file.mk
+
+Compiles a file and runs it on the VM, or evaluates it with the
+interpreter. With -profile, the cost of every function and line is
+measured; a report of the most expensive ones is printed to stderr and a
+pprof profile, for go tool pprof, is written to the file. Programs that
+call exit() are not profiled.
+
+With -trace, every step is logged to stderr: each node the interpreter
+evaluates with its result, or each instruction the VM executes with the
+top of the stack after it.
+
+`
+
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	engine := flags.String("engine", "vm", "run on the `vm` or with the eval interpreter")
+	profilePath := flags.String("profile", "", "write a pprof profile to `file` (vm only)")
+	sample := flags.Duration("sample", 0, "profile by sampling the call stack once every `interval` instead of counting every instruction")
+	traced := flags.Bool("trace", false, "log every evaluation step to stderr")
+	traceJSON := flags.Bool("trace-json", false, "log the trace as JSON Lines; implies -trace")
+	traceFuncs := flags.String("trace-func", "", "only trace calls of the comma-separated functions in `list`, including the calls they make; implies -trace")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, runUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *engine != "vm" && *engine != "eval" {
+		fmt.Fprintf(os.Stderr, "monkey run: unknown engine %q\n", *engine)
+		return 2
+	}
+	if *sample < 0 || *sample > 0 && *profilePath == "" {
+		fmt.Fprintln(os.Stderr, "monkey run: -sample needs -profile and a positive interval")
+		return 2
+	}
+
+	var tw *trace.Writer
+	if *traced || *traceJSON || *traceFuncs != "" {
+		tw = trace.NewWriter(os.Stderr)
+		tw.JSON = *traceJSON
+		if *traceFuncs != "" {
+			tw.Functions = strings.Split(*traceFuncs, ",")
+		}
+	}
+
+	if *profilePath != "" && (*engine != "vm" || tw != nil) {
+		fmt.Fprintln(os.Stderr, "monkey run: -profile needs the vm engine and no trace")
+		return 2
+	}
+
+	if *engine == "eval" {
+		return evalFile(flags.Arg(0), tw)
+	}
+
+	prog, err := compileFile(flags.Arg(0))
+	if err != nil {
+		prog.reportErrors(err)
+		return 1
+	}
+
+	machine := vm.NewWithGlobalStore(prog.bytecode, prog.globals)
+
+	var profiler *profile.Profiler
+	if *profilePath != "" {
+		if *sample > 0 {
+			profiler = profile.NewSampling(*sample)
+		} else {
+			profiler = profile.New()
+		}
+		machine.SetHook(profiler)
+	}
+
+	var tracer *trace.VM
+	if tw != nil {
+		tracer = trace.NewVM(tw)
+		machine.SetHook(tracer)
+	}
+
+	err = machine.Run()
+	if tracer != nil {
+		tracer.Flush(machine)
+	}
+
+	status := 0
+	if err != nil {
+		prog.reportErrors(err)
+		status = 1
+	}
+
+	if tw != nil && tw.Err() != nil {
+		fmt.Fprintf(os.Stderr, "monkey run: %s\n", tw.Err())
+		return 1
+	}
+
+	if profiler != nil {
+		profiler.Stop()
+		if err := writeProfile(profiler, *profilePath); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey run: %s\n", err)
+			return 1
+		}
+	}
+
+	return status
+}
+
+// evalFile runs the named file with the interpreter, traced to tw if it is
+// not nil.
+func evalFile(name string, tw *trace.Writer) int {
+	prog, program, err := parseFile(name)
+	if err != nil {
+		prog.reportErrors(err)
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	if err := stdlib.Eval(env); err != nil {
+		prog.reportErrors(err)
+		return 1
+	}
+
+	if tw != nil {
+		eval.SetTracer(env, trace.NewEval(tw))
+	}
+
+	status := 0
+	if _, err := eval.Run(program, env); err != nil {
+		prog.reportErrors(err)
+		status = 1
+	}
+
+	if tw != nil && tw.Err() != nil {
+		fmt.Fprintf(os.Stderr, "monkey run: %s\n", tw.Err())
+		return 1
+	}
+
+	return status
+}
+
+// profileTop is the number of functions and lines in the report.
+const profileTop = 20
+
+func writeProfile(profiler *profile.Profiler, path string) error {
+	if err := profiler.WriteText(os.Stderr, profileTop); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := profiler.WritePprof(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -23,6 +23,8 @@ const (
 	OpNotEqual
 	OpGreaterEqual
 	OpGreaterThan
+	OpLessEqual
+	OpLessThan
 	OpMinus
 	OpBang
 	OpJumpNotTruthy
@@ -42,8 +44,10 @@ const (
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
-	OpCallLocals
-	OpCallGlobals
+	OpSlice
+	OpTry
+	OpEndTry
+	OpThrow
 )
 
 type Definition struct {
@@ -64,6 +68,8 @@ var definitions = map[Opcode]*Definition{
 	OpNotEqual:       {"OpNotEqual", []int{}},
 	OpGreaterEqual:   {"OpGreaterEqual", []int{}},
 	OpGreaterThan:    {"OpGreaterThan", []int{}},
+	OpLessEqual:      {"OpLessEqual", []int{}},
+	OpLessThan:       {"OpLessThan", []int{}},
 	OpMinus:          {"OpMinus", []int{}},
 	OpBang:           {"OpBang", []int{}},
 	OpJumpNotTruthy:  {"OpJumpNotTruthy", []int{2}},
@@ -83,8 +89,10 @@ var definitions = map[Opcode]*Definition{
 	OpClosure:        {"OpClosure", []int{2, 1}},
 	OpGetFree:        {"OpGetFree", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
-	OpCallLocals:     {"OpCallLocals", []int{}},
-	OpCallGlobals:    {"OpCallGlobals", []int{}},
+	OpSlice:          {"OpSlice", []int{}},
+	OpTry:            {"OpTry", []int{2}},
+	OpEndTry:         {"OpEndTry", []int{}},
+	OpThrow:          {"OpThrow", []int{}},
 }
 
 func (ins Instructions) String() string {
@@ -94,10 +102,16 @@ func (ins Instructions) String() string {
 	for i < len(ins) {
 		def, err := Lookup(ins[i])
 		if err != nil {
-			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			fmt.Fprintf(&out, "%04d ERROR: %s\n", i, err)
+			i++
 			continue
 		}
 
+		if width := operandsWidth(def); i+1+width > len(ins) {
+			fmt.Fprintf(&out, "%04d ERROR: %s is missing %d bytes of operands\n", i, def.Name, i+1+width-len(ins))
+			break
+		}
+
 		operands, read := ReadOperands(def, ins[i+1:])
 
 		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
@@ -183,6 +197,14 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 	return operands, offset
 }
 
+func operandsWidth(def *Definition) int {
+	width := 0
+	for _, w := range def.OperandWidths {
+		width += w
+	}
+	return width
+}
+
 func ReadUint16(ins Instructions) uint16 {
 	return binary.BigEndian.Uint16(ins)
 }
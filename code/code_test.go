@@ -1,6 +1,9 @@
 package code
 
-import "testing"
+import (
+	"monkey/token"
+	"testing"
+)
 
 type makeTestCase struct {
 	op       Opcode
@@ -57,6 +60,21 @@ func TestInstructionString(t *testing.T) {
 	}
 }
 
+func TestMalformedInstructionString(t *testing.T) {
+	ins := Instructions{255}
+	ins = append(ins, Make(OpAdd)...)
+	ins = append(ins, Make(OpConstant, 1)[:2]...)
+
+	expected := `0000 ERROR: opcode 255 undefined
+0001 OpAdd
+0002 ERROR: OpConstant is missing 1 bytes of operands
+`
+
+	if ins.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant = %q\ngot = %q", expected, ins.String())
+	}
+}
+
 func TestReadOperands(t *testing.T) {
 	tests := []struct {
 		op        Opcode
@@ -88,3 +106,41 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceMapLookup(t *testing.T) {
+	sourceMap := SourceMap{
+		{Offset: 0, Pos: token.Position{Line: 1, Column: 1}},
+		{Offset: 3, Pos: token.Position{Line: 2, Column: 5}},
+	}
+
+	tests := []struct {
+		offset   int
+		expected token.Position
+	}{
+		{0, token.Position{Line: 1, Column: 1}},
+		{2, token.Position{Line: 1, Column: 1}},
+		{3, token.Position{Line: 2, Column: 5}},
+		{10, token.Position{Line: 2, Column: 5}},
+	}
+
+	for _, tt := range tests {
+		pos, ok := sourceMap.Lookup(tt.offset)
+		if !ok || pos != tt.expected {
+			t.Errorf("wrong position for %d. want = %s, got = %s", tt.offset, tt.expected, pos)
+		}
+	}
+
+	if _, ok := (SourceMap{}).Lookup(0); ok {
+		t.Errorf("expected no position in an empty source map")
+	}
+}
+
+func FuzzInstructionsString(f *testing.F) {
+	f.Add([]byte(Make(OpConstant, 1)))
+	f.Add(append(Make(OpClosure, 65535, 255), Make(OpAdd)...))
+	f.Add([]byte{255, byte(OpConstant)})
+
+	f.Fuzz(func(t *testing.T, ins []byte) {
+		_ = Instructions(ins).String()
+	})
+}
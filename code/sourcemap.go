@@ -0,0 +1,26 @@
+package code
+
+import (
+	"monkey/token"
+	"sort"
+)
+
+// SourceMap maps instruction offsets to the source positions they were
+// compiled from. Entries are sorted by offset, and each one covers the
+// instructions up to the next.
+type SourceMap []SourceMapEntry
+
+type SourceMapEntry struct {
+	Offset int
+	Pos    token.Position
+}
+
+// Lookup returns the source position of the instruction at offset.
+func (m SourceMap) Lookup(offset int) (token.Position, bool) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}, false
+	}
+
+	return m[i-1].Pos, true
+}
@@ -0,0 +1,52 @@
+package code
+
+import "fmt"
+
+// VerifyError is a problem Verify found with the instruction at Offset.
+type VerifyError struct {
+	Offset  int
+	Message string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%04d: %s", e.Offset, e.Message)
+}
+
+// Verify checks that ins decodes: every opcode is defined, every operand
+// is complete and every jump lands on the start of an instruction or at
+// the end of ins.
+func Verify(ins Instructions) error {
+	starts := make([]bool, len(ins)+1)
+	starts[len(ins)] = true
+
+	var jumps []int
+	for i := 0; i < len(ins); {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			return &VerifyError{Offset: i, Message: err.Error()}
+		}
+
+		width := operandsWidth(def)
+		if i+1+width > len(ins) {
+			return &VerifyError{Offset: i, Message: fmt.Sprintf("%s is missing %d bytes of operands", def.Name, i+1+width-len(ins))}
+		}
+
+		starts[i] = true
+		switch Opcode(ins[i]) {
+		case OpJump, OpJumpNotTruthy, OpTry:
+			jumps = append(jumps, i)
+		}
+
+		i += 1 + width
+	}
+
+	for _, i := range jumps {
+		target := int(ReadUint16(ins[i+1:]))
+		if target >= len(starts) || !starts[target] {
+			def, _ := Lookup(ins[i])
+			return &VerifyError{Offset: i, Message: fmt.Sprintf("%s to %04d, which is not the start of an instruction", def.Name, target)}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package code
+
+import "testing"
+
+func concat(instructions ...[]byte) Instructions {
+	out := Instructions{}
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		ins      Instructions
+		expected string
+	}{
+		{concat(), ""},
+		{concat(Make(OpTrue), Make(OpJumpNotTruthy, 8), Make(OpNull), Make(OpJump, 9), Make(OpTrue)), ""},
+		{concat(Make(OpJump, 3), Make(OpNull)), ""},
+		{concat(Make(OpJump, 4)), "0000: OpJump to 0004, which is not the start of an instruction"},
+		{concat(Make(OpConstant, 0), Make(OpJump, 1)), "0003: OpJump to 0001, which is not the start of an instruction"},
+		{concat(Make(OpTry, 2), Make(OpEndTry)), "0000: OpTry to 0002, which is not the start of an instruction"},
+		{concat(Make(OpPop), Instructions{255}), "0001: opcode 255 undefined"},
+		{concat(Make(OpPop), Make(OpClosure, 1, 2)[:3]), "0001: OpClosure is missing 1 bytes of operands"},
+	}
+
+	for _, tt := range tests {
+		err := Verify(tt.ins)
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.expected {
+			t.Errorf("wrong error for\n%s\nwant = %q, got = %q", tt.ins, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/diag"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/stdlib"
+	"monkey/vm"
+	"os"
+	"strings"
+)
+
+// compiled is a source file compiled on top of the standard library, ready
+// to run with its globals.
+type compiled struct {
+	bytecode *compiler.Bytecode
+	globals  []object.Object
+
+	// libraryGlobals is the number of global slots the standard library
+	// uses; the file's own globals come after them.
+	libraryGlobals int
+
+	// sources holds the text of the file and of the standard library,
+	// keyed by file name.
+	sources map[string]string
+}
+
+// errorList is the parse errors of a file.
+type errorList []error
+
+func (l errorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// parseFile parses the named file and expands its macros. The program it
+// returns holds the sources even when parsing fails, to render the errors
+// with.
+func parseFile(name string) (*compiled, *ast.Program, error) {
+	prog := &compiled{sources: stdlib.Sources()}
+
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return prog, nil, err
+	}
+	prog.sources[name] = string(src)
+
+	p := parser.New(lexer.NewFile(name, string(src)))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		list := make(errorList, len(errs))
+		for i, err := range errs {
+			list[i] = err
+		}
+		return prog, nil, list
+	}
+
+	macros := object.NewEnvironment()
+	eval.DefineMacros(program, macros)
+	program, err = eval.ExpandMacros(program, macros)
+	if err != nil {
+		return prog, nil, err
+	}
+
+	return prog, program, nil
+}
+
+// compileFile parses, expands and compiles the named file, like parseFile.
+func compileFile(name string) (*compiled, error) {
+	prog, program, err := parseFile(name)
+	if err != nil {
+		return prog, err
+	}
+
+	prog.globals = make([]object.Object, vm.GlobalSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	constants, err := stdlib.Compile(symbolTable, prog.globals)
+	if err != nil {
+		return prog, err
+	}
+	prog.libraryGlobals = len(symbolTable.Names())
+
+	comp := compiler.NewWithState(symbolTable, constants)
+	if err := comp.Compile(program); err != nil {
+		return prog, err
+	}
+	prog.bytecode = comp.Bytecode()
+
+	// A compiler bug is better reported than left to crash the VM.
+	if err := vm.Verify(prog.bytecode); err != nil {
+		return prog, err
+	}
+
+	return prog, nil
+}
+
+// renderErrors writes err, or each error of an errorList, as diagnostics
+// pointing into the program's sources.
+func (prog *compiled) renderErrors(w io.Writer, color bool, err error) {
+	renderer := &diag.Renderer{Sources: prog.sources, Color: color}
+
+	if list, ok := err.(errorList); ok {
+		for _, err := range list {
+			renderer.Render(w, diag.FromError(err))
+		}
+		return
+	}
+
+	renderer.Render(w, diag.FromError(err))
+}
+
+// reportErrors renders err to stderr.
+func (prog *compiled) reportErrors(err error) {
+	prog.renderErrors(os.Stderr, diag.ColorEnabled(os.Stderr), err)
+}
@@ -1,10 +1,10 @@
 package compiler
 
 import (
-	"fmt"
 	"monkey/ast"
 	"monkey/code"
 	"monkey/object"
+	"monkey/token"
 	"sort"
 )
 
@@ -20,12 +20,34 @@ type Compiler struct {
 
 	scopes   []CompilationScope
 	scopeIdx int
+
+	// pos is the position of the node being compiled, recorded in the
+	// source map for every instruction emitted.
+	pos token.Position
 }
 
 type CompilationScope struct {
 	instructions    code.Instructions
 	lastInstruction EmittedInstruction
 	prevInstruction EmittedInstruction
+	sourceMap       code.SourceMap
+
+	tries []*tryContext
+}
+
+// tryContext describes a try expression enclosing the code being compiled,
+// so that a return from inside it can remove its handlers and run its
+// finally block first.
+type tryContext struct {
+	handlers int
+	finally  *ast.BlockStatement
+
+	// The finally block is compiled once and copied to every other place
+	// it runs, so that its let statements are only defined once.
+	finallyIns code.Instructions
+	finallyMap code.SourceMap
+	finallyPos int
+	compiled   bool
 }
 
 func New() *Compiler {
@@ -57,6 +79,12 @@ func NewWithState(s *SymbolTable, constansts []object.Object) *Compiler {
 }
 
 func (c *Compiler) Compile(node ast.Node) error {
+	if pos := node.Pos(); pos.IsValid() {
+		outer := c.pos
+		c.pos = pos
+		defer func() { c.pos = outer }()
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, s := range node.Statements {
@@ -79,22 +107,6 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpPop)
 
 	case *ast.InfixExpression:
-		if node.Operator == "<" || node.Operator == "<=" {
-			if err := c.Compile(node.Right); err != nil {
-				return err
-			}
-			if err := c.Compile(node.Left); err != nil {
-				return err
-			}
-
-			if node.Operator == "<" {
-				c.emit(code.OpGreaterThan)
-			} else {
-				c.emit(code.OpGreaterEqual)
-			}
-			return nil
-		}
-
 		if err := c.Compile(node.Left); err != nil {
 			return err
 		}
@@ -114,6 +126,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpDiv)
 		case ">":
 			c.emit(code.OpGreaterThan)
+		case "<":
+			c.emit(code.OpLessThan)
+		case "<=":
+			c.emit(code.OpLessEqual)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
@@ -121,7 +137,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case ">=":
 			c.emit(code.OpGreaterEqual)
 		default:
-			return fmt.Errorf("unknown operator: %s", node.Operator)
+			return c.errorf("unknown operator: %s", node.Operator)
 		}
 
 	case *ast.PrefixExpression:
@@ -135,7 +151,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case "-":
 			c.emit(code.OpMinus)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return c.errorf("unknown operator %s", node.Operator)
 		}
 
 	case *ast.IntegerLiteral:
@@ -156,14 +172,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
 
-		if err := c.Compile(node.Consequence); err != nil {
+		if err := c.compileBlockValue(node.Consequence); err != nil {
 			return err
 		}
 
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
-
 		jumpPos := c.emit(code.OpJump, 9999)
 
 		afterConsequencePos := len(c.currentInstructions())
@@ -172,13 +184,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if node.Alternative == nil {
 			c.emit(code.OpNull)
 		} else {
-			if err := c.Compile(node.Alternative); err != nil {
+			if err := c.compileBlockValue(node.Alternative); err != nil {
 				return err
 			}
-
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
 		}
 
 		afterAlternativePos := len(c.currentInstructions())
@@ -199,8 +207,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.Identifier:
 		sym, ok := c.symbolTable.Resolve(node.Value)
+		if !ok && (node.Value == "quote" || node.Value == "unquote") {
+			return c.errorf("%s can only be used inside macros", node.Value)
+		}
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+			return c.errorf("undefined variable %s", node.Value)
 		}
 
 		c.loadSymbol(sym)
@@ -219,14 +230,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpArray, len(node.Elements))
 
 	case *ast.HashLiteral:
-		keys := []ast.Expression{}
-		for k := range node.Pairs {
-			keys = append(keys, k)
-		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-		for _, k := range keys {
+		for _, k := range node.Keys {
 			if err := c.Compile(k); err != nil {
 				return err
 			}
@@ -248,6 +252,27 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(code.OpIndex)
 
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		for _, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+
+			if err := c.Compile(bound); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpSlice)
+
+	case *ast.MacroLiteral:
+		return c.errorf("macros can only be defined by top-level let statements")
+
 	case *ast.FunctionLiteral:
 		c.enterScope()
 
@@ -273,8 +298,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefs
+		localNames := c.symbolTable.Names()
+		sourceMap := c.scopes[c.scopeIdx].sourceMap
 		instructions := c.leaveScope()
 
+		freeNames := make([]string, len(freeSymbols))
+		for i, s := range freeSymbols {
+			freeNames[i] = s.Name
+		}
+
 		for _, s := range freeSymbols {
 			c.loadSymbol(s)
 		}
@@ -283,6 +315,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions: instructions,
 			NumLocals:    numLocals,
 			NumParams:    len(node.Parameters),
+			Name:         node.Name,
+			SourceMap:    sourceMap,
+			LocalNames:   localNames,
+			FreeNames:    freeNames,
 		}
 
 		fnIdx := c.addConstant(compiledFn)
@@ -293,8 +329,22 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
+		if err := c.leaveTries(); err != nil {
+			return err
+		}
+
 		c.emit(code.OpReturnValue)
 
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		c.emit(code.OpThrow)
+
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+
 	case *ast.CallExpression:
 		if err := c.Compile(node.Function); err != nil {
 			return err
@@ -312,20 +362,214 @@ func (c *Compiler) Compile(node ast.Node) error {
 	return nil
 }
 
+// compileTryExpression guards the try block with a handler for the catch
+// block and, when there is a finally block, an outer handler that runs it
+// and rethrows. The finally block is also inlined on the normal path.
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	ctx := &tryContext{finally: node.Finally}
+	c.scopes[c.scopeIdx].tries = append(c.scopes[c.scopeIdx].tries, ctx)
+
+	var finallyTryPos, catchTryPos int
+	if node.Finally != nil {
+		finallyTryPos = c.emit(code.OpTry, 9999)
+		ctx.handlers++
+	}
+	if node.Catch != nil {
+		catchTryPos = c.emit(code.OpTry, 9999)
+		ctx.handlers++
+	}
+
+	if err := c.compileBlockValue(node.Block); err != nil {
+		return err
+	}
+
+	if node.Catch != nil {
+		c.emit(code.OpEndTry)
+		ctx.handlers--
+
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(catchTryPos, len(c.currentInstructions()))
+
+		sym, restore := c.symbolTable.DefineBlock(node.Param.Value)
+		if sym.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, sym.Index)
+		} else {
+			c.emit(code.OpSetLocal, sym.Index)
+		}
+
+		err := c.compileBlockValue(node.Catch)
+		restore()
+		if err != nil {
+			return err
+		}
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+
+	tries := c.scopes[c.scopeIdx].tries
+	c.scopes[c.scopeIdx].tries = tries[:len(tries)-1]
+
+	if node.Finally != nil {
+		c.emit(code.OpEndTry)
+
+		if err := c.emitFinally(ctx); err != nil {
+			return err
+		}
+
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(finallyTryPos, len(c.currentInstructions()))
+
+		if err := c.emitFinally(ctx); err != nil {
+			return err
+		}
+
+		c.emit(code.OpThrow)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compileBlockValue compiles a block that leaves its value on the stack,
+// pushing null when it does not end in an expression.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+// leaveTries removes the handlers of the try expressions enclosing a return
+// and runs their finally blocks, innermost first.
+func (c *Compiler) leaveTries() error {
+	tries := c.scopes[c.scopeIdx].tries
+
+	for i := len(tries) - 1; i >= 0; i-- {
+		for j := 0; j < tries[i].handlers; j++ {
+			c.emit(code.OpEndTry)
+		}
+
+		if tries[i].finally == nil {
+			continue
+		}
+
+		c.scopes[c.scopeIdx].tries = tries[:i]
+		if err := c.emitFinally(tries[i]); err != nil {
+			return err
+		}
+		c.scopes[c.scopeIdx].tries = tries
+	}
+
+	return nil
+}
+
+func (c *Compiler) emitFinally(ctx *tryContext) error {
+	if !ctx.compiled {
+		start := len(c.currentInstructions())
+		if err := c.Compile(ctx.finally); err != nil {
+			return err
+		}
+
+		ctx.finallyIns = append(code.Instructions{}, c.currentInstructions()[start:]...)
+		ctx.finallyMap = c.sourceMapFrom(start)
+		ctx.finallyPos = start
+		ctx.compiled = true
+		return nil
+	}
+
+	delta := len(c.currentInstructions()) - ctx.finallyPos
+	for _, entry := range ctx.finallyMap {
+		c.addPosition(entry.Offset+delta, entry.Pos)
+	}
+	c.addInstruction(relocate(ctx.finallyIns, delta))
+
+	return nil
+}
+
+// sourceMapFrom returns the source map entries of the current scope that
+// cover the instructions from offset on.
+func (c *Compiler) sourceMapFrom(offset int) code.SourceMap {
+	sourceMap := c.scopes[c.scopeIdx].sourceMap
+
+	i := sort.Search(len(sourceMap), func(i int) bool { return sourceMap[i].Offset >= offset })
+	entries := append(code.SourceMap{}, sourceMap[i:]...)
+
+	if len(entries) == 0 || entries[0].Offset != offset {
+		if pos, ok := sourceMap.Lookup(offset); ok {
+			entries = append(code.SourceMap{{Offset: offset, Pos: pos}}, entries...)
+		}
+	}
+
+	return entries
+}
+
+func (c *Compiler) addPosition(offset int, pos token.Position) {
+	if !pos.IsValid() {
+		return
+	}
+
+	scope := &c.scopes[c.scopeIdx]
+	if n := len(scope.sourceMap); n > 0 && scope.sourceMap[n-1].Pos == pos {
+		return
+	}
+
+	scope.sourceMap = append(scope.sourceMap, code.SourceMapEntry{Offset: offset, Pos: pos})
+}
+
+// relocate returns a copy of ins moved delta bytes forward, adjusting the
+// targets of its jumps.
+func relocate(ins code.Instructions, delta int) code.Instructions {
+	out := append(code.Instructions{}, ins...)
+
+	for i := 0; i < len(out); {
+		op := code.Opcode(out[i])
+		def, err := code.Lookup(out[i])
+		if err != nil {
+			break
+		}
+
+		operands, read := code.ReadOperands(def, out[i+1:])
+
+		switch op {
+		case code.OpJump, code.OpJumpNotTruthy, code.OpTry:
+			copy(out[i:], code.Make(op, operands[0]+delta))
+		}
+
+		i += 1 + read
+	}
+
+	return out
+}
+
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		SourceMap:    c.scopes[c.scopeIdx].sourceMap,
+		GlobalNames:  c.symbolTable.Names(),
 	}
 }
 
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	SourceMap    code.SourceMap
+
+	// GlobalNames holds the name of every global, indexed by its slot,
+	// for debuggers.
+	GlobalNames []string
 }
 
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	ins := code.Make(op, operands...)
+	c.addPosition(len(c.currentInstructions()), c.pos)
 	pos := c.addInstruction(ins)
 
 	c.setLastInstruction(op, pos)
@@ -372,6 +616,12 @@ func (c *Compiler) removeLastPop() {
 
 	c.scopes[c.scopeIdx].instructions = new
 	c.scopes[c.scopeIdx].lastInstruction = previous
+
+	sourceMap := c.scopes[c.scopeIdx].sourceMap
+	for len(sourceMap) > 0 && sourceMap[len(sourceMap)-1].Offset >= last.Position {
+		sourceMap = sourceMap[:len(sourceMap)-1]
+	}
+	c.scopes[c.scopeIdx].sourceMap = sourceMap
 }
 
 func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
@@ -431,36 +681,7 @@ func (c *Compiler) loadSymbol(s Symbol) {
 	case LocalScope:
 		c.emit(code.OpGetLocal, s.Index)
 	case BuiltinScope:
-		if s.Name == "locals" {
-			node := &ast.HashLiteral{}
-			for k, v := range c.symbolTable.store {
-				c.addConstant(&object.String{Value: k})
-				c.addConstant(c.constants[v.Index])
-			}
-
-			keys := []ast.Expression{}
-			for k := range node.Pairs {
-				keys = append(keys, k)
-			}
-			sort.Slice(keys, func(i, j int) bool {
-				return keys[i].String() < keys[j].String()
-			})
-			for _, k := range keys {
-				if err := c.Compile(k); err != nil {
-					panic(err)
-				}
-
-				if err := c.Compile(node.Pairs[k]); err != nil {
-					panic(err)
-				}
-			}
-			c.emit(code.OpHash, len(node.Pairs)*2)
-			c.emit(code.OpCallLocals, s.Index)
-		} else if s.Name == "globals" {
-			c.emit(code.OpCallGlobals, s.Index)
-		} else {
-			c.emit(code.OpGetBuiltin, s.Index)
-		}
+		c.emit(code.OpGetBuiltin, s.Index)
 	case FreeScope:
 		c.emit(code.OpGetFree, s.Index)
 	case FunctionScope:
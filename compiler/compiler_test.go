@@ -226,11 +226,11 @@ func TestBooleanExpressions(t *testing.T) {
 		},
 		{
 			input:             "1 < 2",
-			expectedConstants: []interface{}{2, 1},
+			expectedConstants: []interface{}{1, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpGreaterThan),
+				code.Make(code.OpLessThan),
 				code.Make(code.OpPop),
 			},
 		},
@@ -522,6 +522,31 @@ func TestIndexExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "[1, 2, 3][1:]",
+			expectedConstants: []interface{}{1, 2, 3, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 3),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"abc"[:2]`,
+			expectedConstants: []interface{}{"abc", 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -1185,3 +1210,122 @@ func TestRecursiveFunctions(t *testing.T) {
 
 	runCompilerTests(t, tests)
 }
+
+func TestTryExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `try { 1 } catch (e) { 2 }`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTry, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpEndTry),
+				code.Make(code.OpJump, 16),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `try { 1 } finally { 2 }`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTry, 14),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpEndTry),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 19),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpThrow),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `throw 1`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpThrow),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestSourceMap(t *testing.T) {
+	input := `let f = fn() {
+	try {
+		return 1
+	} finally {
+		2
+	}
+};
+f()`
+
+	comp := New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	pos, ok := bytecode.SourceMap.Lookup(len(bytecode.Instructions) - 1)
+	if !ok || pos.Line != 8 {
+		t.Errorf("wrong position for main program. got = %s", pos)
+	}
+
+	fn := bytecode.Constants[2].(*object.CompiledFunction)
+	if fn.Name != "f" {
+		t.Errorf("wrong function name. got = %q", fn.Name)
+	}
+
+	// Every copy of the finally block maps back to its source line.
+	copies := 0
+	for ip := 0; ip < len(fn.Instructions); {
+		def, _ := code.Lookup(fn.Instructions[ip])
+		_, read := code.ReadOperands(def, fn.Instructions[ip+1:])
+
+		if code.Opcode(fn.Instructions[ip]) == code.OpConstant && code.ReadUint16(fn.Instructions[ip+1:]) == 1 {
+			copies++
+			if pos, _ := fn.SourceMap.Lookup(ip); pos.Line != 5 {
+				t.Errorf("wrong position for finally block at %d. got = %s", ip, pos)
+			}
+		}
+
+		ip += 1 + read
+	}
+	if copies != 3 {
+		t.Errorf("wrong number of finally copies. got = %d", copies)
+	}
+}
+
+func FuzzCompile(f *testing.F) {
+	f.Add(`let add = fn add(x, y) { return x + y; }; add(1, 2 * 3)`)
+	f.Add(`let f = fn(a) { fn(b) { a + b } }; f(1)(2)[0:1]`)
+	f.Add(`try { throw "x" } catch (e) { e.message } finally { puts(locals()) }`)
+	f.Add(`if (!true) { -1 } else { {"a": [1, "b"]}["a"] }`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		l := lexer.New(input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return
+		}
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			return
+		}
+
+		bytecode := compiler.Bytecode()
+		_ = bytecode.Instructions.String()
+		for _, constant := range bytecode.Constants {
+			if fn, ok := constant.(*object.CompiledFunction); ok {
+				_ = fn.Instructions.String()
+			}
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+// Error is an error found while compiling, such as an undefined variable.
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+func (c *Compiler) errorf(format string, a ...interface{}) *Error {
+	return &Error{Pos: c.pos, Message: fmt.Sprintf(format, a...)}
+}
@@ -57,6 +57,22 @@ func (s *SymbolTable) Define(name string) Symbol {
 	return symbol
 }
 
+// DefineBlock defines name in a new slot for a block, such as the parameter
+// of a catch block, and returns the function that makes name resolve as it
+// did before, at the end of the block.
+func (s *SymbolTable) DefineBlock(name string) (Symbol, func()) {
+	prev, ok := s.store[name]
+	symbol := s.Define(name)
+
+	return symbol, func() {
+		if ok {
+			s.store[name] = prev
+		} else {
+			delete(s.store, name)
+		}
+	}
+}
+
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := s.store[name]
 
@@ -100,3 +116,16 @@ func (s *SymbolTable) DefineFunctionName(name string) Symbol {
 	s.store[name] = symbol
 	return symbol
 }
+
+// Names returns the names defined in s, indexed by the slot they are
+// stored in. Slots whose name was defined again later are left empty.
+func (s *SymbolTable) Names() []string {
+	names := make([]string, s.numDefs)
+	for name, symbol := range s.store {
+		if symbol.Scope == GlobalScope || symbol.Scope == LocalScope {
+			names[symbol.Index] = name
+		}
+	}
+
+	return names
+}
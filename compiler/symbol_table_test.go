@@ -323,3 +323,29 @@ func TestShadowingFunctionName(t *testing.T) {
 		t.Errorf("expected %s to resolve to %+v, got=%+v", expected.Name, expected, result)
 	}
 }
+
+func TestDefineBlock(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	inner, restore := global.DefineBlock("a")
+	if expected := (Symbol{Name: "a", Scope: GlobalScope, Index: 1}); inner != expected {
+		t.Errorf("expected the block to define %+v, got=%+v", expected, inner)
+	}
+	if result, _ := global.Resolve("a"); result != inner {
+		t.Errorf("expected a to resolve to %+v in the block, got=%+v", inner, result)
+	}
+
+	restore()
+
+	expected := Symbol{Name: "a", Scope: GlobalScope, Index: 0}
+	if result, _ := global.Resolve("a"); result != expected {
+		t.Errorf("expected a to resolve to %+v after the block, got=%+v", expected, result)
+	}
+
+	_, restore = global.DefineBlock("b")
+	restore()
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("expected b to be unresolvable after the block")
+	}
+}
@@ -0,0 +1,116 @@
+// Package conformance runs programs with both the interpreter and the VM,
+// on top of the standard library, and reports what each engine did, so
+// that tests can check that the two agree.
+package conformance
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/stdlib"
+	"monkey/vm"
+	"strings"
+)
+
+// Result is what running a program did: what it printed, and the error it
+// stopped with, if any.
+type Result struct {
+	Output string
+	Err    string
+}
+
+// String returns the result as it is recorded in expected output files:
+// the output, followed by a line with the error if there is one.
+func (r Result) String() string {
+	if r.Err == "" {
+		return r.Output
+	}
+	return r.Output + "error: " + r.Err + "\n"
+}
+
+// Eval runs the program src with the interpreter.
+func Eval(name, src string) Result {
+	program, err := parse(name, src)
+	if err != nil {
+		return Result{Err: err.Error()}
+	}
+
+	env := object.NewEnvironment()
+	if err := stdlib.Eval(env); err != nil {
+		return Result{Err: err.Error()}
+	}
+
+	return capture(env.Session(), func() error {
+		_, err := eval.Run(program, env)
+		return err
+	})
+}
+
+// VM compiles the program src and runs it on the VM.
+func VM(name, src string) Result {
+	program, err := parse(name, src)
+	if err != nil {
+		return Result{Err: err.Error()}
+	}
+
+	globals := make([]object.Object, vm.GlobalSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	constants, err := stdlib.Compile(symbolTable, globals)
+	if err != nil {
+		return Result{Err: err.Error()}
+	}
+
+	comp := compiler.NewWithState(symbolTable, constants)
+	if err := comp.Compile(program); err != nil {
+		return Result{Err: err.Error()}
+	}
+
+	if err := vm.Verify(comp.Bytecode()); err != nil {
+		return Result{Err: err.Error()}
+	}
+
+	machine := vm.NewWithGlobalStore(comp.Bytecode(), globals)
+	return capture(machine.Session(), machine.Run)
+}
+
+// parse parses src and expands its macros.
+func parse(name, src string) (*ast.Program, error) {
+	p := parser.New(lexer.NewFile(name, src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return nil, fmt.Errorf("%s", strings.Join(msgs, "\n"))
+	}
+
+	macros := object.NewEnvironment()
+	eval.DefineMacros(program, macros)
+	return eval.ExpandMacros(program, macros)
+}
+
+// capture runs f, the run of session, and returns what the program wrote
+// and the error f returned. Calling exit ends the run instead of the
+// process.
+func capture(session *object.Session, f func() error) Result {
+	var out strings.Builder
+	session.Output = &out
+	session.Exit = func(status int) {}
+
+	var result Result
+	if err := f(); err != nil {
+		result.Err = err.Error()
+	}
+	result.Output = out.String()
+
+	return result
+}
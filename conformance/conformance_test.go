@@ -0,0 +1,56 @@
+package conformance
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected output of tests/*.mk from the interpreter")
+
+// Every file in tests/ is a program, and the .out file next to it its
+// expected output, followed by the error it stops with, if any.
+
+func TestCorpus(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("tests", "*.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no programs in tests/")
+	}
+
+	for _, path := range paths {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e := Eval(filepath.Base(path), string(src))
+			v := VM(filepath.Base(path), string(src))
+			if e != v {
+				t.Errorf("engines disagree\neval:\n%s\nvm:\n%s", e, v)
+			}
+
+			outPath := strings.TrimSuffix(path, ".mk") + ".out"
+			if *update {
+				if err := os.WriteFile(outPath, []byte(e.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.String() != string(expected) {
+				t.Errorf("wrong output\nwant:\n%s\ngot:\n%s", expected, e)
+			}
+		})
+	}
+}
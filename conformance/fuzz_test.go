@@ -0,0 +1,151 @@
+package conformance
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// FuzzEngines runs random programs with both engines and fails when they
+// print different things or stop with different errors.
+func FuzzEngines(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte("monkey"))
+	f.Add([]byte{3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5, 8, 9, 7, 9, 3, 2, 3, 8, 4, 6})
+	f.Add([]byte{255, 128, 64, 32, 16, 8, 4, 2, 1, 0, 17, 19, 23, 29, 31, 37})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		src := generate(data)
+
+		e := Eval("fuzz.mk", src)
+		v := VM("fuzz.mk", src)
+		if e != v {
+			t.Errorf("engines disagree on\n%s\neval:\n%s\nvm:\n%s", src, e, v)
+		}
+	})
+}
+
+// generate turns data into a program: a few let statements and a puts of
+// some expressions. Every name it uses is defined, and it never recurses or
+// calls exit, so programs always finish.
+func generate(data []byte) string {
+	g := &generator{data: data}
+
+	var b strings.Builder
+	for i, n := 0, g.intn(4)+1; i < n; i++ {
+		name := fmt.Sprintf("v%d", i)
+		fmt.Fprintf(&b, "let %s = %s;\n", name, g.expr(3))
+		g.vars = append(g.vars, name)
+	}
+
+	args := make([]string, g.intn(3)+1)
+	for i := range args {
+		args[i] = g.expr(3)
+	}
+	fmt.Fprintf(&b, "puts(%s);\n", strings.Join(args, ", "))
+
+	return b.String()
+}
+
+type generator struct {
+	data []byte
+	vars []string
+
+	// params counts the function literals generated, to name their
+	// parameters.
+	params int
+}
+
+// intn returns a number in [0, n) taken from the data, or 0 once it is
+// used up.
+func (g *generator) intn(n int) int {
+	if len(g.data) == 0 {
+		return 0
+	}
+	b := g.data[0]
+	g.data = g.data[1:]
+	return int(b) % n
+}
+
+var (
+	stringValues = []string{`""`, `"a"`, `"monkey"`, `"héllo"`, `"1,2"`}
+	infixes      = []string{"+", "-", "*", "/", "<", ">", "<=", ">=", "==", "!="}
+	unary        = []string{"len", "first", "last", "rest", "keys", "values", "upper", "chars", "toInt", "toBool", "reverse", "sum", "json.encode"}
+	binary       = []string{"push", "contains", "indexOf", "split", "join", "has", "delete", "merge", "repeat", "deepEqual", "take"}
+)
+
+func (g *generator) pick(list []string) string {
+	return list[g.intn(len(list))]
+}
+
+func (g *generator) expr(depth int) string {
+	if depth == 0 {
+		return g.leaf()
+	}
+	d := depth - 1
+
+	switch g.intn(16) {
+	case 0, 1, 2:
+		return g.leaf()
+	case 3:
+		return fmt.Sprintf("(%s%s)", g.pick([]string{"!", "-"}), g.expr(d))
+	case 4, 5, 6:
+		return fmt.Sprintf("(%s %s %s)", g.expr(d), g.pick(infixes), g.expr(d))
+	case 7:
+		return fmt.Sprintf("if (%s) { %s } else { %s }", g.expr(d), g.expr(d), g.expr(d))
+	case 8:
+		return fmt.Sprintf("[%s, %s]", g.expr(d), g.expr(d))
+	case 9:
+		return fmt.Sprintf("{%s: %s, %d: %s}", g.pick(stringValues), g.expr(d), g.intn(3), g.expr(d))
+	case 10:
+		return fmt.Sprintf("%s[%s]", g.expr(d), g.expr(d))
+	case 11:
+		return fmt.Sprintf("%s[%d:%d]", g.expr(d), g.intn(4)-1, g.intn(4))
+	case 12:
+		return fmt.Sprintf("%s(%s)", g.pick(unary), g.expr(d))
+	case 13:
+		return fmt.Sprintf("%s(%s, %s)", g.pick(binary), g.expr(d), g.expr(d))
+	case 14:
+		return g.function(d)
+	default:
+		return fmt.Sprintf("try { %s } catch (err) { [err, %s] }", g.expr(d), g.expr(d))
+	}
+}
+
+// function returns a function literal that is called or passed to a
+// standard library function. Its body sees its parameter and the variables
+// around it.
+func (g *generator) function(depth int) string {
+	param := fmt.Sprintf("p%d", g.params)
+	g.params++
+
+	g.vars = append(g.vars, param)
+	body := g.expr(depth)
+	g.vars = g.vars[:len(g.vars)-1]
+
+	fn := fmt.Sprintf("fn(%s) { %s }", param, body)
+	switch g.intn(3) {
+	case 0:
+		return fmt.Sprintf("%s(%s)", fn, g.expr(depth))
+	case 1:
+		return fmt.Sprintf("map(%s, %s)", g.expr(depth), fn)
+	default:
+		return fmt.Sprintf("filter(%s, %s)", g.expr(depth), fn)
+	}
+}
+
+func (g *generator) leaf() string {
+	switch g.intn(5) {
+	case 0:
+		return fmt.Sprint(g.intn(10))
+	case 1:
+		return g.pick(stringValues)
+	case 2:
+		return g.pick([]string{"true", "false"})
+	default:
+		if len(g.vars) == 0 {
+			return fmt.Sprint(g.intn(10))
+		}
+		return g.vars[g.intn(len(g.vars))]
+	}
+}
@@ -0,0 +1,12 @@
+// Integer arithmetic, comparisons and booleans.
+puts(1 + 2 * 3, (1 + 2) * 3, 10 - 20, -5 * -5);
+puts(7 / 2, -7 / 2, 7 / -2);
+puts(1 < 2, 2 < 1, 1 > 2, 2 >= 2, 3 <= 2, 1 == 1, 1 != 1);
+puts(!true, !false, !!5, !0, -(-3));
+puts(true == true, true != false, (1 < 2) == true);
+puts(9223372036854775807 + 1);
+let left = fn() { puts("left"); 1 };
+let right = fn() { puts("right"); 2 };
+puts(left() < right(), left() <= right(), left() > right());
+puts(try { 1 < "a" } catch (e) { e.message }, try { true > false } catch (e) { e.message });
+puts(try { "a" - "b" } catch (e) { e.message }, try { [1] + [2] } catch (e) { e.message });
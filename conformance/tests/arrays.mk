@@ -0,0 +1,8 @@
+// Arrays, indexing, slices and the array builtins.
+let xs = [1, 2, 3];
+puts(xs, len(xs), xs[0], xs[2], xs[3], xs[-1]);
+puts(first(xs), last(xs), rest(xs), push(xs, 4), xs);
+puts(first([]), last([]), rest([]));
+puts(xs[1:], xs[:1], xs[5:], [] == [], [1, [2]] == [1, [2]], [1] == [2]);
+puts(contains(xs, 2), indexOf(xs, 3), indexOf(xs, 9));
+puts(deepEqual([1, {"a": 2}], [1, {"a": 2}]));
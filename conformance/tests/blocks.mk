@@ -0,0 +1,15 @@
+// Blocks that do not end in an expression are null, and a return outside
+// of functions ends the program.
+puts(if (true) { });
+puts(if (true) { let a = 1; } else { 2 });
+puts([if (false) { 1 } else { }]);
+puts(fn() { }());
+puts(fn() { let b = 1; }());
+puts(try { } catch (e) { 1 });
+let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } };
+puts(count(1000));
+if (count(1) == 1) {
+	puts("returning");
+	return 1;
+}
+puts("not reached");
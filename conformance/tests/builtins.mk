@@ -0,0 +1,12 @@
+// Builtins behave the same, including their errors.
+puts(toInt(true), toInt(false), toBool(0), toBool(""), toBool([]));
+puts(json.encode({"a": [1, true, "x"]}), json.decode("{\"b\": [1, 2]}"));
+puts(json.encode([1, 2], 2));
+puts(keys({}), values({}), len([]), len(""));
+puts(puts());
+puts(try { push(1, 2) } catch (e) { e.message });
+puts(try { first("x") } catch (e) { e.message });
+puts(try { json.decode("{") } catch (e) { e.type });
+let result = puts;
+result("aliased");
+len("too", "many");
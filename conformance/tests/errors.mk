@@ -0,0 +1,25 @@
+// Runtime errors can be caught, and any value can be thrown.
+let safe = fn(f) {
+	try { f() } catch (e) { [e.type, e.message] }
+};
+puts(safe(fn() { 1 + "a" }));
+puts(safe(fn() { len(1, 2) }));
+puts(safe(fn() { fn(a) { a }() }));
+puts(safe(fn() { 5() }));
+puts(safe(fn() { -"x" }));
+puts(safe(fn() { {}[[fn() { 1 }]] }));
+puts(safe(fn() { 1 / 0 }));
+let deep = fn(n) { deep(n + 1) };
+puts(safe(fn() { deep(0) }));
+puts(safe(fn() { let early = early + 1; }));
+puts(try { throw "plain" } catch (e) { e });
+puts(try { throw {"code": 7} } catch (e) { e.code });
+let log = fn() {
+	try { throw 1 } catch (e) { puts("caught", e) } finally { puts("finally") }
+};
+log();
+puts(try { 1 } finally { puts("runs") });
+let nested = try { try { throw "inner" } finally { puts("inner finally") } } catch (e) { "outer caught " + e };
+puts(nested);
+throw "uncaught";
+puts("not reached");
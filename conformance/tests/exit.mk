@@ -0,0 +1,8 @@
+// exit ends the program at once: try expressions neither catch it nor run
+// their finally blocks.
+let stop = fn(status) {
+	try { exit(status) } catch (e) { puts("caught") } finally { puts("finally") }
+};
+puts("before");
+stop(3);
+puts("after");
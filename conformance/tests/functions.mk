@@ -0,0 +1,21 @@
+// Closures, recursion, higher-order functions and the standard library.
+let makeAdder = fn(x) { fn(y) { x + y } };
+let addTwo = makeAdder(2);
+puts(addTwo(3), makeAdder(10)(5));
+
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+puts(fib(15));
+
+let counter = fn(n) {
+	let step = fn(acc, i) { if (i > n) { acc } else { step(acc + i, i + 1) } };
+	step(0, 1)
+};
+puts(counter(100));
+
+puts(map([1, 2, 3], fn(x) { x * x }), filter(range(0, 10), fn(x) { x > 6 }));
+puts(reduce([1, 2, 3, 4], 0, fn(acc, x) { acc + x }), sum([5, 5]));
+puts(compose(fn(x) { x + 1 }, fn(x) { x * 2 })(5));
+puts(fn() { return 1; 2 }(), fn() { if (true) { return "early"; } "late" }());
+let noValue = fn() { let x = 1; };
+puts(noValue());
+puts(addTwo);
@@ -0,0 +1,9 @@
+// Hashes keep insertion order and accept strings, integers, booleans and
+// arrays as keys.
+let h = {"b": 1, "a": 2, 3: "three", true: "yes", [1, 2]: "pair"};
+puts(h, h["a"], h.b, h[3], h[true], h[[1, 2]], h["missing"]);
+puts(keys(h), values(h));
+puts(has(h, "a"), has(h, "z"), delete(h, "a"), h);
+puts(merge({"x": 1}, {"x": 2, "y": 3}), entries({"k": "v"}), fromEntries([["k", 1]]));
+puts({"a": 1} == {"a": 1}, {"a": 1} == {"a": 2});
+puts({}[fn() { 1 }]);
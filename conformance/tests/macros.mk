@@ -0,0 +1,12 @@
+// Macros are expanded before either engine runs the program.
+let unless = macro(cond, cons, alt) {
+	quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) })
+};
+puts(unless(10 > 5, "not greater", "greater"));
+let swap = macro(a, b) { quote([unquote(b), unquote(a)]) };
+let x = 1;
+let y = 2;
+puts(swap(x, y));
+// A variable named quote is called like any other function.
+let twice = fn() { let quote = fn(x) { x * 2 }; quote(21) };
+puts(twice());
@@ -0,0 +1,4 @@
+// quote outside a macro is rejected by both engines before the program
+// runs.
+puts("not printed");
+let f = fn() { quote(1 + 2) };
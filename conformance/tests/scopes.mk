@@ -0,0 +1,38 @@
+// locals() and globals() see the same variables in both engines.
+let a = 1;
+let f = fn(x, y) {
+	let z = x + y;
+	puts(locals());
+	puts(has(globals(), "a"), has(globals(), "f"), has(globals(), "z"));
+	if (false) { let never = 1; }
+	locals()
+};
+puts(keys(f(1, 2)));
+puts(locals()["a"], has(locals(), "later"));
+let later = 2;
+puts(has(globals(), "later"));
+let shadow = fn(a) { let b = a; locals() };
+puts(shadow(5));
+// A catch parameter is bound only in its catch block, while other names
+// the block defines belong to the enclosing scope.
+let e = 1;
+try { throw 2 } catch (e) { puts(e); let caught = e; };
+puts(e, caught);
+let g = fn() {
+	let e = "local";
+	let seen = try { throw "x" } catch (e) { fn() { e } };
+	puts(e, seen(), has(locals(), "e"));
+};
+g();
+// Variables defined again and catch parameters leave no unnamed entries.
+let twice = 1;
+let twice = 2;
+try { throw "top" } catch (e) { e };
+puts(has(globals(), ""), globals()["twice"], has(locals(), ""));
+let redefined = fn() {
+	let y = 1;
+	let y = 2;
+	try { throw "inner" } catch (e) { e };
+	locals()
+};
+puts(redefined());
@@ -0,0 +1,10 @@
+// String values compare by content, not identity.
+let a = "mon" + "key";
+let b = "monkey";
+puts(a == b, a != b, a == "monkey", "a" < "b", "b" <= "a", "abc" > "abd");
+puts(len("héllo"), "héllo"[1], "hello"[-1], "hello"[1:3], "hello"[:-2], "hello"[10:]);
+puts(upper("abc"), lower("ABC"), split("a,b,c", ","), join(["x", "y"], "-"));
+puts(trim("  x  "), replace("aaa", "a", "b", 2), contains("team", "ea"), indexOf("team", "m"));
+puts(substr("monkey", 1, 3), repeat("ab", 3), chars("abc"), padLeft("7", 3, "0"));
+puts("tab\tquote\"backslash\\");
+puts("hello"[5]);
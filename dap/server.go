@@ -0,0 +1,621 @@
+// Package dap implements a Debug Adapter Protocol server, so that editors
+// can debug monkey programs running on the VM. The server speaks the
+// protocol over a pair of streams, usually stdin and stdout, and maps its
+// requests onto a debug.Debugger. The program runs on its own goroutine
+// and has a single thread as far as the client is concerned.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/debug"
+	"monkey/diag"
+	"monkey/object"
+	"monkey/vm"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const threadID = 1
+
+var errNotStopped = errors.New("the program is not stopped")
+
+// Program is a compiled program to debug.
+type Program struct {
+	Bytecode *compiler.Bytecode
+
+	// Globals is the global store to run with, or nil for a new one.
+	Globals []object.Object
+
+	// LibraryGlobals is the number of leading global slots that hold the
+	// standard library, which are not listed among the globals.
+	LibraryGlobals int
+
+	// Sources holds the text of the files the program was compiled from,
+	// keyed by file name.
+	Sources map[string]string
+}
+
+type Server struct {
+	// Load compiles the file named by a launch request. The path is
+	// absolute, and should be used as the file name in positions.
+	Load func(path string) (*Program, error)
+
+	in *bufio.Reader
+
+	// mu guards out and seq, which the program's goroutine uses to send
+	// events.
+	mu  sync.Mutex
+	out io.Writer
+	seq int
+
+	prog        *Program
+	debugger    *debug.Debugger
+	breakpoints map[string][]int
+	codeLines   map[string]map[int]bool
+
+	launched, configured, started bool
+
+	// stopped is set by the program's goroutine when it stops, and
+	// cleared by the server when it resumes the program.
+	stateMu sync.Mutex
+	stopped bool
+
+	resume chan resumption
+	done   chan struct{}
+
+	// refs holds the variables behind each variablesReference handed out
+	// since the program stopped; reference n is refs[n-1].
+	refs []func() []variable
+
+	// sourceRefs holds the names of files that are not on disk, such as
+	// the standard library; sourceReference n is sourceRefs[n-1].
+	sourceRefs []string
+}
+
+type resumption struct {
+	action debug.Action
+	err    error
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:          bufio.NewReader(in),
+		out:         out,
+		breakpoints: make(map[string][]int),
+		resume:      make(chan resumption),
+		done:        make(chan struct{}),
+	}
+}
+
+// Serve handles requests until the client disconnects or the input ends.
+func (s *Server) Serve() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		if s.handle(&req) {
+			return nil
+		}
+
+		if s.launched && s.configured && !s.started {
+			s.start()
+		}
+	}
+}
+
+// handle answers a request and reports whether it ends the session.
+func (s *Server) handle(req *request) bool {
+	var (
+		body interface{}
+		err  error
+	)
+
+	switch req.Command {
+	case "initialize":
+		s.respond(req, capabilities{SupportsConfigurationDoneRequest: true, SupportsEvaluateForHovers: true}, nil)
+		s.event("initialized", nil)
+		return false
+	case "launch":
+		err = s.launch(req.Arguments)
+	case "setBreakpoints":
+		body, err = s.setBreakpoints(req.Arguments)
+	case "configurationDone":
+		s.configured = true
+	case "threads":
+		body = map[string]interface{}{"threads": []thread{{ID: threadID, Name: "main"}}}
+	case "stackTrace":
+		body, err = s.stackTrace(req.Arguments)
+	case "scopes":
+		body, err = s.scopes(req.Arguments)
+	case "variables":
+		body, err = s.variables(req.Arguments)
+	case "evaluate":
+		body, err = s.evaluate(req.Arguments)
+	case "source":
+		body, err = s.source(req.Arguments)
+	case "continue":
+		err = s.resumeWith(debug.Continue)
+		body = map[string]interface{}{"allThreadsContinued": true}
+	case "next":
+		err = s.resumeWith(debug.StepOver)
+	case "stepIn":
+		err = s.resumeWith(debug.StepIn)
+	case "stepOut":
+		err = s.resumeWith(debug.StepOut)
+	case "disconnect", "terminate":
+		s.quit()
+		s.respond(req, nil, nil)
+		return true
+	default:
+		err = fmt.Errorf("unsupported request %q", req.Command)
+	}
+
+	s.respond(req, body, err)
+	return false
+}
+
+func (s *Server) send(msg interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+
+	writeMessage(s.out, msg)
+}
+
+func (s *Server) respond(req *request, body interface{}, err error) {
+	resp := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+		resp.Body = nil
+	}
+
+	s.send(resp)
+}
+
+func (s *Server) event(name string, body interface{}) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+// Output returns a writer whose writes the client shows as program output
+// of the given category, such as "stdout".
+func (s *Server) Output(category string) io.Writer {
+	return &outputWriter{s: s, category: category}
+}
+
+type outputWriter struct {
+	s        *Server
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]interface{}{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
+
+func decode(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func (s *Server) launch(raw json.RawMessage) error {
+	var args launchArguments
+	if err := decode(raw, &args); err != nil {
+		return err
+	}
+
+	if s.launched {
+		return errors.New("the program is already launched")
+	}
+	if s.Load == nil {
+		return errors.New("launching is not supported")
+	}
+	if args.Program == "" {
+		return errors.New("no program to launch")
+	}
+
+	path, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+
+	prog, err := s.Load(path)
+	if err != nil {
+		s.Output("stderr").Write([]byte(err.Error() + "\n"))
+		return fmt.Errorf("%s does not compile", args.Program)
+	}
+
+	s.prog = prog
+	s.codeLines = codeLines(prog.Bytecode)
+
+	s.debugger = debug.New(prog.Bytecode.GlobalNames)
+	s.debugger.Paused = s.paused
+	s.debugger.StopOnEntry = args.StopOnEntry
+	s.debugger.LibraryGlobals = prog.LibraryGlobals
+
+	for file, lines := range s.breakpoints {
+		for _, line := range lines {
+			s.debugger.SetBreakpoint(file, line)
+		}
+	}
+
+	s.launched = true
+	return nil
+}
+
+// codeLines returns the lines of each file that have instructions.
+func codeLines(bytecode *compiler.Bytecode) map[string]map[int]bool {
+	lines := make(map[string]map[int]bool)
+	add := func(m code.SourceMap) {
+		for _, entry := range m {
+			if lines[entry.Pos.Filename] == nil {
+				lines[entry.Pos.Filename] = make(map[int]bool)
+			}
+			lines[entry.Pos.Filename][entry.Pos.Line] = true
+		}
+	}
+
+	add(bytecode.SourceMap)
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			add(fn.SourceMap)
+		}
+	}
+
+	return lines
+}
+
+func (s *Server) setBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var args setBreakpointsArguments
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+
+	file := args.Source.Path
+	if args.Source.SourceReference > 0 && args.Source.SourceReference <= len(s.sourceRefs) {
+		file = s.sourceRefs[args.Source.SourceReference-1]
+	} else if file != "" {
+		file = filepath.Clean(file)
+	}
+
+	lines := make([]int, 0, len(args.Breakpoints))
+	result := make([]breakpoint, 0, len(args.Breakpoints))
+	for _, bp := range args.Breakpoints {
+		verified := s.codeLines == nil || s.codeLines[file][bp.Line]
+		if verified {
+			lines = append(lines, bp.Line)
+			result = append(result, breakpoint{Verified: true, Line: bp.Line})
+		} else {
+			result = append(result, breakpoint{Line: bp.Line, Message: "no code on this line"})
+		}
+	}
+	s.breakpoints[file] = lines
+
+	if s.debugger != nil {
+		s.debugger.ClearBreakpoints(file)
+		for _, line := range lines {
+			s.debugger.SetBreakpoint(file, line)
+		}
+	}
+
+	return map[string]interface{}{"breakpoints": result}, nil
+}
+
+// start runs the program on its own goroutine.
+func (s *Server) start() {
+	s.started = true
+
+	machine := vm.New(s.prog.Bytecode)
+	if s.prog.Globals != nil {
+		machine = vm.NewWithGlobalStore(s.prog.Bytecode, s.prog.Globals)
+	}
+	machine.SetHook(s.debugger)
+
+	// Stdout carries the protocol, so the program's output is sent to the
+	// client as events, and exit must end the run rather than the adapter.
+	exitCode := 0
+	machine.Session().Output = s.Output("stdout")
+	machine.Session().Exit = func(status int) { exitCode = status }
+
+	go func() {
+		defer close(s.done)
+
+		err := machine.Run()
+		var exit *object.ExitError
+		if err != nil && !errors.Is(err, debug.ErrQuit) && !errors.As(err, &exit) {
+			var out strings.Builder
+			renderer := &diag.Renderer{Sources: s.prog.Sources}
+			renderer.Render(&out, diag.FromError(err))
+			s.Output("stderr").Write([]byte(out.String()))
+			exitCode = 1
+		}
+
+		s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// paused is called on the program's goroutine when it stops, and waits
+// for the client to resume it.
+func (s *Server) paused(d *debug.Debugger, stop debug.Stop) (debug.Action, error) {
+	s.stateMu.Lock()
+	s.stopped = true
+	s.stateMu.Unlock()
+
+	s.event("stopped", map[string]interface{}{
+		"reason":            stop.Reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+
+	r := <-s.resume
+	return r.action, r.err
+}
+
+func (s *Server) isStopped() bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	return s.stopped
+}
+
+func (s *Server) resumeWith(action debug.Action) error {
+	if !s.isStopped() {
+		return errNotStopped
+	}
+
+	s.refs = nil
+
+	s.stateMu.Lock()
+	s.stopped = false
+	s.stateMu.Unlock()
+
+	s.resume <- resumption{action: action}
+	return nil
+}
+
+// quit stops a stopped program and waits for it to end. A running program
+// is left to be ended with the server's process.
+func (s *Server) quit() {
+	if !s.isStopped() {
+		return
+	}
+
+	s.stateMu.Lock()
+	s.stopped = false
+	s.stateMu.Unlock()
+
+	s.resume <- resumption{err: debug.ErrQuit}
+	<-s.done
+}
+
+func (s *Server) stackTrace(raw json.RawMessage) (interface{}, error) {
+	var args stackTraceArguments
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if !s.isStopped() {
+		return nil, errNotStopped
+	}
+
+	stack := s.debugger.Stack()
+
+	frames := make([]stackFrame, 0, len(stack))
+	for i, frame := range stack {
+		frames = append(frames, stackFrame{
+			ID:     i + 1,
+			Name:   frame.Name,
+			Source: s.sourceOf(frame.Pos.Filename),
+			Line:   frame.Pos.Line,
+			Column: frame.Pos.Column,
+		})
+	}
+
+	total := len(frames)
+	if args.StartFrame > 0 && args.StartFrame < len(frames) {
+		frames = frames[args.StartFrame:]
+	} else if args.StartFrame >= len(frames) {
+		frames = frames[:0]
+	}
+	if args.Levels > 0 && args.Levels < len(frames) {
+		frames = frames[:args.Levels]
+	}
+
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": total}, nil
+}
+
+// sourceOf describes a file for the client. Files that are not on disk are
+// given a reference the client can fetch them with.
+func (s *Server) sourceOf(file string) *source {
+	if file == "" {
+		return nil
+	}
+	if filepath.IsAbs(file) {
+		return &source{Name: filepath.Base(file), Path: file}
+	}
+
+	for i, name := range s.sourceRefs {
+		if name == file {
+			return &source{Name: file, SourceReference: i + 1}
+		}
+	}
+
+	s.sourceRefs = append(s.sourceRefs, file)
+	return &source{Name: file, SourceReference: len(s.sourceRefs)}
+}
+
+func (s *Server) source(raw json.RawMessage) (interface{}, error) {
+	var args sourceArguments
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+
+	ref := args.SourceReference
+	if args.Source != nil && args.Source.SourceReference > 0 {
+		ref = args.Source.SourceReference
+	}
+	if ref < 1 || ref > len(s.sourceRefs) || s.prog == nil {
+		return nil, fmt.Errorf("unknown source reference %d", ref)
+	}
+
+	return map[string]interface{}{"content": s.prog.Sources[s.sourceRefs[ref-1]]}, nil
+}
+
+// frame turns a frame id, which counts from 1 at the innermost call, into
+// the number of calls up from the innermost one.
+func (s *Server) frame(id int) (int, error) {
+	if !s.isStopped() {
+		return 0, errNotStopped
+	}
+
+	n := id - 1
+	if n < 0 || n >= len(s.debugger.Stack()) {
+		return 0, fmt.Errorf("unknown frame %d", id)
+	}
+
+	return n, nil
+}
+
+func (s *Server) scopes(raw json.RawMessage) (interface{}, error) {
+	var args scopesArguments
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+
+	n, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []scope{
+		{Name: "Locals", VariablesReference: s.ref(func() []variable { return s.toVariables(s.debugger.Locals(n)) })},
+		{Name: "Globals", VariablesReference: s.ref(func() []variable { return s.toVariables(s.debugger.Globals()) })},
+	}
+
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *Server) ref(f func() []variable) int {
+	s.refs = append(s.refs, f)
+	return len(s.refs)
+}
+
+func (s *Server) variables(raw json.RawMessage) (interface{}, error) {
+	var args variablesArguments
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+	if !s.isStopped() {
+		return nil, errNotStopped
+	}
+
+	ref := args.VariablesReference
+	if ref < 1 || ref > len(s.refs) {
+		return nil, fmt.Errorf("unknown variables reference %d", ref)
+	}
+
+	return map[string]interface{}{"variables": s.refs[ref-1]()}, nil
+}
+
+func (s *Server) toVariables(vars []debug.Variable) []variable {
+	out := make([]variable, 0, len(vars))
+	for _, v := range vars {
+		out = append(out, s.variable(v.Name, v.Value))
+	}
+
+	return out
+}
+
+// variable describes a value for the client. Arrays and hashes get a
+// reference to their elements.
+func (s *Server) variable(name string, value object.Object) variable {
+	v := variable{Name: name, Value: display(value), Type: string(value.Type())}
+
+	switch value := value.(type) {
+	case *object.Array:
+		if len(value.Elements) != 0 {
+			v.VariablesReference = s.ref(func() []variable {
+				elements := make([]variable, len(value.Elements))
+				for i, elem := range value.Elements {
+					elements[i] = s.variable(fmt.Sprintf("[%d]", i), elem)
+				}
+				return elements
+			})
+		}
+	case *object.Hash:
+		if pairs := value.Pairs(); len(pairs) != 0 {
+			v.VariablesReference = s.ref(func() []variable {
+				elements := make([]variable, len(pairs))
+				for i, pair := range pairs {
+					elements[i] = s.variable(display(pair.Key), pair.Value)
+				}
+				return elements
+			})
+		}
+	}
+
+	return v
+}
+
+// display formats a value as source code would show it.
+func display(value object.Object) string {
+	if str, ok := value.(*object.String); ok {
+		return strconv.Quote(str.Value)
+	}
+	return value.Inspect()
+}
+
+func (s *Server) evaluate(raw json.RawMessage) (interface{}, error) {
+	var args evaluateArguments
+	if err := decode(raw, &args); err != nil {
+		return nil, err
+	}
+
+	n := 0
+	if args.FrameID != 0 {
+		var err error
+		if n, err = s.frame(args.FrameID); err != nil {
+			return nil, err
+		}
+	} else if !s.isStopped() {
+		return nil, errNotStopped
+	}
+
+	name := strings.TrimSpace(args.Expression)
+	value, ok := s.debugger.Lookup(name, n)
+	if !ok {
+		return nil, fmt.Errorf("no variable %s", name)
+	}
+
+	v := s.variable(name, value)
+	return map[string]interface{}{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+}
@@ -0,0 +1,291 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+	"time"
+)
+
+const program = `let add = fn(a, b) {
+	let sum = a + b;
+	sum
+};
+let list = [1, "two"];
+let result = add(1, 2);
+puts(result);
+`
+
+// client drives a server the way an editor does.
+type client struct {
+	t      *testing.T
+	in     io.WriteCloser
+	msgs   chan map[string]interface{}
+	seq    int
+	events []map[string]interface{}
+}
+
+// newClient starts a server that launches src.
+func newClient(t *testing.T, src string) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	server := NewServer(serverIn, serverOut)
+	server.Load = func(path string) (*Program, error) {
+		p := parser.New(lexer.NewFile(path, src))
+		comp := compiler.New()
+		if err := comp.Compile(p.ParseProgram()); err != nil {
+			return nil, err
+		}
+
+		return &Program{Bytecode: comp.Bytecode(), Sources: map[string]string{path: src}}, nil
+	}
+	go func() {
+		server.Serve()
+		serverOut.Close()
+	}()
+
+	c := &client{t: t, in: clientOut, msgs: make(chan map[string]interface{}, 100)}
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			body, err := readMessage(r)
+			if err != nil {
+				close(c.msgs)
+				return
+			}
+
+			var msg map[string]interface{}
+			json.Unmarshal(body, &msg)
+			c.msgs <- msg
+		}
+	}()
+
+	return c
+}
+
+func (c *client) next() map[string]interface{} {
+	c.t.Helper()
+
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatalf("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+	}
+	return nil
+}
+
+// request sends a request and returns the body of its response, failing
+// the test unless it succeeds. Events that arrive meanwhile are kept.
+func (c *client) request(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+
+	resp := c.send(command, args)
+	if resp["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, resp["message"])
+	}
+
+	body, _ := resp["body"].(map[string]interface{})
+	return body
+}
+
+func (c *client) send(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+
+	c.seq++
+	raw, _ := json.Marshal(args)
+	writeMessage(c.in, request{Seq: c.seq, Type: "request", Command: command, Arguments: raw})
+
+	for {
+		msg := c.next()
+		if msg["type"] == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg["request_seq"] != float64(c.seq) {
+			c.t.Fatalf("response to the wrong request: %v", msg)
+		}
+		return msg
+	}
+}
+
+// event waits for the named event and returns its body.
+func (c *client) event(name string) map[string]interface{} {
+	c.t.Helper()
+
+	for {
+		var msg map[string]interface{}
+		if len(c.events) != 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+		}
+
+		if msg["type"] == "event" && msg["event"] == name {
+			body, _ := msg["body"].(map[string]interface{})
+			return body
+		}
+	}
+}
+
+// list returns the named array of a body.
+func list(body map[string]interface{}, name string) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, elem := range body[name].([]interface{}) {
+		out = append(out, elem.(map[string]interface{}))
+	}
+	return out
+}
+
+func TestSession(t *testing.T) {
+	c := newClient(t, program)
+
+	caps := c.request("initialize", map[string]interface{}{"adapterID": "monkey"})
+	if caps["supportsConfigurationDoneRequest"] != true {
+		t.Errorf("configurationDone not supported: %v", caps)
+	}
+	c.event("initialized")
+
+	c.request("launch", map[string]interface{}{"program": "/src/main.mk"})
+
+	bps := list(c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": "/src/main.mk"},
+		"breakpoints": []map[string]interface{}{{"line": 2}, {"line": 4}},
+	}), "breakpoints")
+	if len(bps) != 2 || bps[0]["verified"] != true || bps[1]["verified"] != false {
+		t.Errorf("wrong breakpoints: %v", bps)
+	}
+
+	c.request("configurationDone", nil)
+
+	stopped := c.event("stopped")
+	if stopped["reason"] != "breakpoint" || stopped["threadId"] != float64(1) {
+		t.Errorf("wrong stopped event: %v", stopped)
+	}
+
+	threads := list(c.request("threads", nil), "threads")
+	if len(threads) != 1 || threads[0]["id"] != float64(1) {
+		t.Errorf("wrong threads: %v", threads)
+	}
+
+	frames := list(c.request("stackTrace", map[string]interface{}{"threadId": 1}), "stackFrames")
+	if len(frames) != 2 {
+		t.Fatalf("wrong number of frames: %v", frames)
+	}
+	if frames[0]["name"] != "add" || frames[0]["line"] != float64(2) || frames[1]["name"] != "<main>" || frames[1]["line"] != float64(6) {
+		t.Errorf("wrong frames: %v", frames)
+	}
+	if src := frames[0]["source"].(map[string]interface{}); src["path"] != "/src/main.mk" || src["name"] != "main.mk" {
+		t.Errorf("wrong source: %v", src)
+	}
+
+	scopes := list(c.request("scopes", map[string]interface{}{"frameId": frames[0]["id"]}), "scopes")
+	if len(scopes) != 2 || scopes[0]["name"] != "Locals" || scopes[1]["name"] != "Globals" {
+		t.Fatalf("wrong scopes: %v", scopes)
+	}
+
+	locals := variables(c, scopes[0]["variablesReference"])
+	if locals["a"] != "1" || locals["b"] != "2" || len(locals) != 2 {
+		t.Errorf("wrong locals: %v", locals)
+	}
+
+	globals := list(c.request("variables", map[string]interface{}{"variablesReference": scopes[1]["variablesReference"]}), "variables")
+	var listRef interface{}
+	for _, v := range globals {
+		if v["name"] == "list" {
+			listRef = v["variablesReference"]
+		}
+	}
+	if elements := variables(c, listRef); elements["[0]"] != "1" || elements["[1]"] != `"two"` {
+		t.Errorf("wrong list elements: %v", elements)
+	}
+
+	c.request("next", map[string]interface{}{"threadId": 1})
+	if stopped := c.event("stopped"); stopped["reason"] != "step" {
+		t.Errorf("wrong stopped event: %v", stopped)
+	}
+
+	frames = list(c.request("stackTrace", map[string]interface{}{"threadId": 1}), "stackFrames")
+	if frames[0]["line"] != float64(3) {
+		t.Errorf("next stopped on line %v, want 3", frames[0]["line"])
+	}
+
+	value := c.request("evaluate", map[string]interface{}{"expression": "sum", "frameId": frames[0]["id"]})
+	if value["result"] != "3" {
+		t.Errorf("wrong value of sum: %v", value)
+	}
+
+	if resp := c.send("evaluate", map[string]interface{}{"expression": "missing"}); resp["success"] != false {
+		t.Errorf("evaluating an unknown name succeeded: %v", resp)
+	}
+
+	c.request("stepIn", map[string]interface{}{"threadId": 1})
+	c.event("stopped")
+	frames = list(c.request("stackTrace", map[string]interface{}{"threadId": 1}), "stackFrames")
+	if len(frames) != 1 || frames[0]["line"] != float64(6) {
+		t.Errorf("wrong frames after returning: %v", frames)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	if exited := c.event("exited"); exited["exitCode"] != float64(0) {
+		t.Errorf("wrong exit code: %v", exited)
+	}
+	c.event("terminated")
+
+	if resp := c.send("stackTrace", map[string]interface{}{"threadId": 1}); resp["success"] != false {
+		t.Errorf("stackTrace succeeded after the program ended")
+	}
+
+	c.request("disconnect", nil)
+}
+
+func TestStopOnEntryAndQuit(t *testing.T) {
+	c := newClient(t, program)
+
+	c.request("initialize", nil)
+	c.request("launch", map[string]interface{}{"program": "/src/main.mk", "stopOnEntry": true})
+	c.request("configurationDone", nil)
+
+	if stopped := c.event("stopped"); stopped["reason"] != "entry" {
+		t.Errorf("wrong stopped event: %v", stopped)
+	}
+
+	c.request("disconnect", nil)
+	c.event("terminated")
+}
+
+func TestExit(t *testing.T) {
+	c := newClient(t, `puts("bye"); exit(4); puts("after");`)
+
+	c.request("initialize", nil)
+	c.request("launch", map[string]interface{}{"program": "/src/main.mk"})
+	c.request("configurationDone", nil)
+
+	if output := c.event("output"); output["category"] != "stdout" || output["output"] != "bye " {
+		t.Errorf("wrong output event: %v", output)
+	}
+	if exited := c.event("exited"); exited["exitCode"] != float64(4) {
+		t.Errorf("wrong exit code: %v", exited)
+	}
+	c.event("terminated")
+
+	c.request("disconnect", nil)
+}
+
+func variables(c *client, ref interface{}) map[string]string {
+	c.t.Helper()
+
+	out := map[string]string{}
+	for _, v := range list(c.request("variables", map[string]interface{}{"variablesReference": ref}), "variables") {
+		out[v["name"].(string)] = v["value"].(string)
+	}
+	return out
+}
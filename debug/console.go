@@ -0,0 +1,200 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const consolePrompt = "(mdb) "
+
+const consoleHelp = `commands:
+  break [file:]line   b   set a breakpoint
+  clear [file:]line       remove a breakpoint
+  breakpoints             list the breakpoints
+  continue            c   run until the next breakpoint
+  step                s   run to the next line, entering calls
+  next                n   run to the next line, stepping over calls
+  out                 o   run until the current function returns
+  print name          p   print a variable
+  locals                  list the variables of the selected frame
+  globals                 list the global variables
+  backtrace           bt  print the call stack
+  frame n             f   select frame n of the backtrace
+  quit                q   stop the program
+An empty line repeats the last command.
+`
+
+// Console is a line-oriented front end for a Debugger, in the style of
+// gdb. Set its Paused method as the debugger's Paused function.
+type Console struct {
+	in  *bufio.Scanner
+	out io.Writer
+
+	// File is the file breakpoints without a file name are set in.
+	File string
+
+	// Sources holds the text of the files the program was compiled from,
+	// keyed by file name, to show the line the program stopped at.
+	Sources map[string]string
+
+	frame int
+	last  string
+}
+
+func NewConsole(in io.Reader, out io.Writer, file string, sources map[string]string) *Console {
+	return &Console{in: bufio.NewScanner(in), out: out, File: file, Sources: sources}
+}
+
+// Paused shows where the program stopped and runs commands until one of
+// them resumes it. The end of the input quits.
+func (c *Console) Paused(d *Debugger, stop Stop) (Action, error) {
+	c.frame = 0
+
+	switch stop.Reason {
+	case Breakpoint:
+		fmt.Fprintf(c.out, "breakpoint at %s\n", stop.Pos)
+	case Entry, Step:
+		fmt.Fprintf(c.out, "stopped at %s\n", stop.Pos)
+	}
+	c.showLine(stop.Pos.Filename, stop.Pos.Line)
+
+	for {
+		fmt.Fprint(c.out, consolePrompt)
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return Continue, ErrQuit
+		}
+
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.last
+		}
+		c.last = line
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if action, resume, err := c.command(d, fields[0], fields[1:]); resume || err != nil {
+			return action, err
+		}
+	}
+}
+
+// command runs a command, reporting whether it resumes the program.
+func (c *Console) command(d *Debugger, name string, args []string) (Action, bool, error) {
+	switch name {
+	case "continue", "c":
+		return Continue, true, nil
+	case "step", "s":
+		return StepIn, true, nil
+	case "next", "n":
+		return StepOver, true, nil
+	case "out", "o":
+		return StepOut, true, nil
+	case "quit", "q":
+		return Continue, false, ErrQuit
+
+	case "break", "b":
+		if file, line, ok := c.location(args); ok {
+			d.SetBreakpoint(file, line)
+			fmt.Fprintf(c.out, "breakpoint set at %s:%d\n", file, line)
+		}
+	case "clear":
+		if file, line, ok := c.location(args); ok {
+			if d.ClearBreakpoint(file, line) {
+				fmt.Fprintf(c.out, "breakpoint cleared at %s:%d\n", file, line)
+			} else {
+				fmt.Fprintf(c.out, "no breakpoint at %s:%d\n", file, line)
+			}
+		}
+	case "breakpoints":
+		for _, bp := range d.Breakpoints() {
+			fmt.Fprintf(c.out, "%s:%d\n", bp.Filename, bp.Line)
+		}
+
+	case "print", "p":
+		if len(args) != 1 {
+			fmt.Fprintln(c.out, "usage: print name")
+			break
+		}
+		if value, ok := d.Lookup(args[0], c.frame); ok {
+			fmt.Fprintf(c.out, "%s = %s\n", args[0], value.Inspect())
+		} else {
+			fmt.Fprintf(c.out, "no variable %s\n", args[0])
+		}
+	case "locals":
+		for _, v := range d.Locals(c.frame) {
+			fmt.Fprintf(c.out, "%s = %s\n", v.Name, v.Value.Inspect())
+		}
+	case "globals":
+		for _, v := range d.Globals() {
+			fmt.Fprintf(c.out, "%s = %s\n", v.Name, v.Value.Inspect())
+		}
+
+	case "backtrace", "bt":
+		for i, frame := range d.Stack() {
+			marker := " "
+			if i == c.frame {
+				marker = "*"
+			}
+			fmt.Fprintf(c.out, "%s#%d %s at %s\n", marker, i, frame.Name, frame.Pos)
+		}
+	case "frame", "f":
+		stack := d.Stack()
+		n, err := strconv.Atoi(strings.Join(args, ""))
+		if err != nil || n < 0 || n >= len(stack) {
+			fmt.Fprintf(c.out, "usage: frame n, with n from 0 to %d\n", len(stack)-1)
+			break
+		}
+		c.frame = n
+		fmt.Fprintf(c.out, "#%d %s at %s\n", n, stack[n].Name, stack[n].Pos)
+		c.showLine(stack[n].Pos.Filename, stack[n].Pos.Line)
+
+	case "help", "h":
+		fmt.Fprint(c.out, consoleHelp)
+	default:
+		fmt.Fprintf(c.out, "unknown command %q, try help\n", name)
+	}
+
+	return Continue, false, nil
+}
+
+// location parses the argument of break and clear.
+func (c *Console) location(args []string) (string, int, bool) {
+	if len(args) != 1 {
+		fmt.Fprintln(c.out, "usage: break [file:]line")
+		return "", 0, false
+	}
+
+	file, lineText := c.File, args[0]
+	if i := strings.LastIndex(args[0], ":"); i >= 0 {
+		file, lineText = args[0][:i], args[0][i+1:]
+	}
+
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		fmt.Fprintf(c.out, "invalid line %q\n", lineText)
+		return "", 0, false
+	}
+
+	return file, line, true
+}
+
+func (c *Console) showLine(file string, line int) {
+	src, ok := c.Sources[file]
+	if !ok {
+		return
+	}
+
+	lines := strings.Split(src, "\n")
+	if line < 1 || line > len(lines) {
+		return
+	}
+
+	fmt.Fprintf(c.out, "%4d | %s\n", line, strings.TrimRight(lines[line-1], "\r"))
+}
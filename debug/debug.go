@@ -0,0 +1,287 @@
+// Package debug implements a source-level debugger for programs running on
+// the VM. A Debugger is installed as the VM's hook; it stops the program at
+// breakpoints and after steps, and hands control to a front end, such as
+// the Console, which inspects the stopped program and decides how to
+// resume it.
+package debug
+
+import (
+	"errors"
+	"monkey/object"
+	"monkey/token"
+	"monkey/vm"
+	"sort"
+	"sync"
+)
+
+// Action tells the debugger how to resume a stopped program.
+type Action int
+
+const (
+	// Continue runs until the next breakpoint.
+	Continue Action = iota
+	// StepIn runs to the next line, entering calls.
+	StepIn
+	// StepOver runs to the next line of the current function.
+	StepOver
+	// StepOut runs until the current function returns.
+	StepOut
+)
+
+// Reasons for stopping.
+const (
+	Entry      = "entry"
+	Breakpoint = "breakpoint"
+	Step       = "step"
+)
+
+// ErrQuit is returned by front ends to stop the program.
+var ErrQuit = errors.New("debugger quit")
+
+// Stop describes where and why the program stopped.
+type Stop struct {
+	Reason string
+	Pos    token.Position
+}
+
+// Debugger is a vm.Hook. The program stops when it starts executing a
+// line that has a breakpoint, and wherever the last action asked it to.
+type Debugger struct {
+	// Paused is called when the program stops. The inspection methods of
+	// the debugger may be used until it returns the action to resume
+	// with. An error stops the program, and the VM returns it.
+	Paused func(d *Debugger, stop Stop) (Action, error)
+
+	// StopOnEntry stops the program before its first line.
+	StopOnEntry bool
+
+	// LibraryGlobals is the number of leading global slots that hold the
+	// standard library. Globals leaves them out.
+	LibraryGlobals int
+
+	globalNames []string
+
+	// Breakpoints may be changed while the program runs.
+	mu          sync.Mutex
+	breakpoints map[string]map[int]bool
+
+	vm      *vm.VM
+	stopped bool
+	action  Action
+	depth   int
+
+	// lines holds the line each active frame last executed, to tell
+	// when a frame starts a new line.
+	lines []int
+}
+
+// New returns a debugger for a program whose globals have the given names,
+// indexed by slot.
+func New(globalNames []string) *Debugger {
+	return &Debugger{
+		globalNames: globalNames,
+		breakpoints: make(map[string]map[int]bool),
+	}
+}
+
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.breakpoints[file] == nil {
+		d.breakpoints[file] = make(map[int]bool)
+	}
+	d.breakpoints[file][line] = true
+}
+
+// ClearBreakpoint removes a breakpoint and reports whether it was set.
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.breakpoints[file][line] {
+		return false
+	}
+
+	delete(d.breakpoints[file], line)
+	return true
+}
+
+// ClearBreakpoints removes every breakpoint in file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.breakpoints, file)
+}
+
+// Breakpoints returns the breakpoints, sorted by file and line.
+func (d *Debugger) Breakpoints() []token.Position {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var out []token.Position
+	for file, lines := range d.breakpoints {
+		for line := range lines {
+			out = append(out, token.Position{Filename: file, Line: line})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+
+	return out
+}
+
+// Before implements vm.Hook.
+func (d *Debugger) Before(machine *vm.VM, frame *vm.Frame) error {
+	d.vm = machine
+
+	depth := len(machine.Frames())
+	for len(d.lines) < depth {
+		d.lines = append(d.lines, 0)
+	}
+	d.lines = d.lines[:depth]
+	if frame.IP() == 0 {
+		d.lines[depth-1] = 0
+	}
+
+	pos, ok := frame.Pos()
+	newLine := ok && pos.Line != d.lines[depth-1]
+	if ok {
+		d.lines[depth-1] = pos.Line
+	}
+
+	var reason string
+	switch {
+	case newLine && d.hasBreakpoint(pos):
+		reason = Breakpoint
+	case !d.stopped && d.StopOnEntry:
+		if !newLine {
+			return nil
+		}
+		reason = Entry
+	case d.stopped && d.action == StepIn && (newLine || depth < d.depth),
+		d.stopped && d.action == StepOver && (newLine && depth <= d.depth || depth < d.depth),
+		d.stopped && d.action == StepOut && depth < d.depth:
+		reason = Step
+	default:
+		return nil
+	}
+
+	d.stopped = true
+
+	action, err := d.Paused(d, Stop{Reason: reason, Pos: pos})
+	d.action, d.depth = action, depth
+
+	return err
+}
+
+func (d *Debugger) hasBreakpoint(pos token.Position) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.breakpoints[pos.Filename][pos.Line]
+}
+
+// StackFrame is a function call in progress.
+type StackFrame struct {
+	Name string
+	Pos  token.Position
+}
+
+// Stack returns the calls in progress, innermost first. The outermost one
+// is the program itself, named "<main>".
+func (d *Debugger) Stack() []StackFrame {
+	frames := d.vm.Frames()
+	stack := make([]StackFrame, 0, len(frames))
+
+	for i := len(frames) - 1; i >= 0; i-- {
+		pos, _ := frames[i].Pos()
+		stack = append(stack, StackFrame{Name: frameName(frames, i), Pos: pos})
+	}
+
+	return stack
+}
+
+func frameName(frames []*vm.Frame, i int) string {
+	if i == 0 {
+		return "<main>"
+	}
+
+	if name := frames[i].Closure().Fn.Name; name != "" {
+		return name
+	}
+	return "<anonymous>"
+}
+
+// Variable is a named value.
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Locals returns the variables visible in the frame n calls up from the
+// innermost one: its locals, in the order they were declared, then the
+// variables its closure captured. Locals that have not been assigned yet
+// are left out.
+func (d *Debugger) Locals(n int) []Variable {
+	frames := d.vm.Frames()
+	if n < 0 || n >= len(frames) {
+		return nil
+	}
+
+	frame := frames[len(frames)-1-n]
+	fn := frame.Closure().Fn
+
+	var vars []Variable
+	for i, value := range d.vm.Locals(frame) {
+		if i < len(fn.LocalNames) && fn.LocalNames[i] != "" && value != nil {
+			vars = append(vars, Variable{Name: fn.LocalNames[i], Value: value})
+		}
+	}
+
+	for i, value := range frame.Closure().Free {
+		if i < len(fn.FreeNames) {
+			vars = append(vars, Variable{Name: fn.FreeNames[i], Value: value})
+		}
+	}
+
+	return vars
+}
+
+// Globals returns the globals of the program that have been assigned,
+// sorted by name.
+func (d *Debugger) Globals() []Variable {
+	var vars []Variable
+
+	store := d.vm.Globals()
+	for i := d.LibraryGlobals; i < len(d.globalNames) && i < len(store); i++ {
+		if d.globalNames[i] != "" && store[i] != nil {
+			vars = append(vars, Variable{Name: d.globalNames[i], Value: store[i]})
+		}
+	}
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+
+	return vars
+}
+
+// Lookup finds the value of name as seen from the frame n calls up from
+// the innermost one: a local, a captured variable, or a global, including
+// those of the standard library.
+func (d *Debugger) Lookup(name string, n int) (object.Object, bool) {
+	for _, v := range d.Locals(n) {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+
+	store := d.vm.Globals()
+	for i := len(d.globalNames) - 1; i >= 0; i-- {
+		if d.globalNames[i] == name && i < len(store) && store[i] != nil {
+			return store[i], true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,161 @@
+package debug
+
+import (
+	"errors"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/vm"
+	"strings"
+	"testing"
+)
+
+const program = `let add = fn(a, b) {
+	let sum = a + b;
+	sum
+};
+let base = 10;
+let twice = fn(x) {
+	add(x, x) + base
+};
+let result = twice(4);
+result
+`
+
+// session runs program under a debugger driven by a console reading
+// commands, and returns the console output.
+func session(t *testing.T, commands string) (string, error) {
+	t.Helper()
+
+	p := parser.New(lexer.NewFile("main.mk", program))
+	parsed := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(parsed); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	var out strings.Builder
+	console := NewConsole(strings.NewReader(commands), &out, "main.mk", map[string]string{"main.mk": program})
+
+	d := New(bytecode.GlobalNames)
+	d.Paused = console.Paused
+	d.StopOnEntry = true
+
+	machine := vm.New(bytecode)
+	machine.SetHook(d)
+	err := machine.Run()
+
+	return out.String(), err
+}
+
+// stops returns the positions the program stopped at, in order.
+func stops(out string) []string {
+	var positions []string
+	for _, line := range strings.Split(out, "\n") {
+		for _, prefix := range []string{"stopped at ", "breakpoint at "} {
+			if i := strings.Index(line, prefix); i >= 0 {
+				positions = append(positions, line[i+len(prefix):])
+			}
+		}
+	}
+	return positions
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		commands string
+		expected []string
+	}{
+		{
+			"c\n",
+			[]string{"main.mk:1:11"},
+		},
+		{
+			"n\nn\nn\nn\nn\n",
+			[]string{"main.mk:1:11", "main.mk:5:12", "main.mk:6:13", "main.mk:9:14", "main.mk:10:1"},
+		},
+		{
+			"n\nn\nn\ns\ns\ns\ns\n",
+			[]string{"main.mk:1:11", "main.mk:5:12", "main.mk:6:13", "main.mk:9:14", "main.mk:7:2", "main.mk:2:12", "main.mk:3:2", "main.mk:7:14"},
+		},
+		{
+			"b 2\nc\no\no\nc\n",
+			[]string{"main.mk:1:11", "main.mk:2:12", "main.mk:7:14", "main.mk:9:1"},
+		},
+		{
+			"b 3\nb main.mk:2\nclear 3\nc\nn\nn\n",
+			[]string{"main.mk:1:11", "main.mk:2:12", "main.mk:3:2", "main.mk:7:14"},
+		},
+		{
+			"\n",
+			[]string{"main.mk:1:11"},
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := session(t, tt.commands)
+		if err != nil && !errors.Is(err, ErrQuit) {
+			t.Fatalf("run failed: %s", err)
+		}
+
+		actual := stops(out)
+		if strings.Join(actual, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("wrong stops for %q.\nwant = %v\ngot  = %v", tt.commands, tt.expected, actual)
+		}
+	}
+}
+
+func TestInspection(t *testing.T) {
+	out, err := session(t, "b 3\nc\nlocals\nbt\np base\np missing\nf 1\nlocals\np x\nglobals\nq\n")
+	if !errors.Is(err, ErrQuit) {
+		t.Fatalf("expected ErrQuit, got %v", err)
+	}
+
+	for _, expected := range []string{
+		"   3 | \tsum\n",
+		"(mdb) a = 4\nb = 4\nsum = 8\n",
+		"(mdb) *#0 add at main.mk:3:2\n #1 twice at main.mk:7:5\n #2 <main> at main.mk:9:19\n",
+		"(mdb) base = 10\n",
+		"(mdb) no variable missing\n",
+		"(mdb) #1 twice at main.mk:7:5\n   7 | \tadd(x, x) + base\n",
+		"(mdb) x = 4\n",
+		"(mdb) x = 4\n(mdb) add = fn add(a, b) { ... }",
+		"base = 10\ntwice = fn twice(x) { ... }",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("output does not contain %q:\n%s", expected, out)
+		}
+	}
+
+	if strings.Contains(out, "result = ") {
+		t.Errorf("unassigned global listed:\n%s", out)
+	}
+}
+
+func TestTryCannotCatchQuit(t *testing.T) {
+	p := parser.New(lexer.New("try {\n\t1\n} catch (e) {\n\t2\n}"))
+	comp := compiler.New()
+	if err := comp.Compile(p.ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	d := New(nil)
+	d.StopOnEntry = true
+	d.Paused = func(d *Debugger, stop Stop) (Action, error) {
+		if stop.Pos.Line == 1 {
+			return StepIn, nil
+		}
+		return Continue, ErrQuit
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.SetHook(d)
+	if err := machine.Run(); !errors.Is(err, ErrQuit) {
+		t.Fatalf("expected ErrQuit, got %v", err)
+	}
+}
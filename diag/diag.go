@@ -0,0 +1,211 @@
+// Package diag renders errors with the source line they point at, in the
+// style of:
+//
+//	error: expected next token to be ), got ; instead
+//	 --> main.mk:3:17
+//	  |
+//	3 | let x = add(1, 2;
+//	  |                 ^
+//	  = hint: a closing `)` may be missing
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"monkey/compiler"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	Error   = "error"
+	Warning = "warning"
+)
+
+// Diagnostic is a message about a position in the source. Length is the
+// number of characters to underline; when it is zero the word at Pos is
+// underlined.
+type Diagnostic struct {
+	Severity string
+	Code     string
+	Message  string
+	Pos      token.Position
+	Length   int
+	Notes    []string
+	Hint     string
+}
+
+// FromError turns the errors of the parser, the compiler and both engines
+// into a diagnostic. Any other error gives a diagnostic without a position.
+func FromError(err error) Diagnostic {
+	var (
+		parseErr   *parser.Error
+		compileErr *compiler.Error
+		runtimeErr *object.Error
+	)
+
+	switch {
+	case errors.As(err, &parseErr):
+		d := Diagnostic{Severity: Error, Message: parseErr.Message, Pos: parseErr.Pos, Hint: parseErr.Hint}
+		if parseErr.Found.Pos == parseErr.Pos && parseErr.Found.Type != token.EOF {
+			d.Length = utf8.RuneCountInString(parseErr.Found.Literal)
+		}
+		return d
+	case errors.As(err, &compileErr):
+		return Diagnostic{Severity: Error, Message: compileErr.Message, Pos: compileErr.Pos}
+	case errors.As(err, &runtimeErr):
+		d := Diagnostic{Severity: Error, Message: runtimeErr.Message, Pos: runtimeErr.Pos}
+		if runtimeErr.Kind != "" && runtimeErr.Kind != object.GENERIC_ERROR {
+			d.Code = runtimeErr.Kind
+		}
+		return d
+	default:
+		return Diagnostic{Severity: Error, Message: err.Error()}
+	}
+}
+
+// Renderer writes diagnostics. Sources holds the text of every file a
+// diagnostic may point into, keyed by file name; input without a name is
+// stored under "".
+type Renderer struct {
+	Sources map[string]string
+	Color   bool
+}
+
+const (
+	bold   = "\x1b[1m"
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	blue   = "\x1b[1;34m"
+	cyan   = "\x1b[1;36m"
+	reset  = "\x1b[0m"
+)
+
+func (r *Renderer) paint(color, s string) string {
+	if !r.Color {
+		return s
+	}
+	return color + s + reset
+}
+
+func (r *Renderer) Render(w io.Writer, d Diagnostic) {
+	severity := d.Severity
+	if severity == "" {
+		severity = Error
+	}
+	if d.Code != "" {
+		severity = fmt.Sprintf("%s[%s]", severity, d.Code)
+	}
+
+	color := red
+	if d.Severity == Warning {
+		color = yellow
+	}
+
+	fmt.Fprintf(w, "%s%s\n", r.paint(color, severity), r.paint(bold, ": "+d.Message))
+
+	line, ok := r.sourceLine(d.Pos)
+	gutter := strings.Repeat(" ", len(fmt.Sprint(d.Pos.Line)))
+
+	if d.Pos.IsValid() {
+		fmt.Fprintf(w, "%s%s %s\n", gutter, r.paint(blue, "-->"), d.Pos)
+	}
+
+	if ok {
+		bar := r.paint(blue, "|")
+		fmt.Fprintf(w, "%s %s\n", gutter, bar)
+		fmt.Fprintf(w, "%s %s %s\n", r.paint(blue, fmt.Sprint(d.Pos.Line)), bar, line)
+		fmt.Fprintf(w, "%s %s %s\n", gutter, bar, r.paint(color, underline(line, d.Pos.Column, d.Length)))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s %s note: %s\n", gutter, r.paint(blue, "="), note)
+	}
+	if d.Hint != "" {
+		fmt.Fprintf(w, "%s %s %s %s\n", gutter, r.paint(blue, "="), r.paint(cyan, "hint:"), d.Hint)
+	}
+}
+
+func (r *Renderer) sourceLine(pos token.Position) (string, bool) {
+	if !pos.IsValid() {
+		return "", false
+	}
+
+	src, ok := r.Sources[pos.Filename]
+	if !ok {
+		return "", false
+	}
+
+	lines := strings.Split(src, "\n")
+	if pos.Line > len(lines) {
+		return "", false
+	}
+
+	return strings.TrimRight(lines[pos.Line-1], "\r"), true
+}
+
+// underline returns the marker line for the characters of line starting at
+// column. Tabs before the column are kept so that the carets line up.
+func underline(line string, column, length int) string {
+	runes := []rune(line)
+	start := column - 1
+	if start < 0 {
+		start = 0
+	}
+	if start > len(runes) {
+		start = len(runes)
+	}
+
+	var out strings.Builder
+	for _, r := range runes[:start] {
+		if r == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+
+	if length == 0 {
+		length = wordLength(runes[start:])
+	}
+	out.WriteString(strings.Repeat("^", length))
+
+	return out.String()
+}
+
+func wordLength(runes []rune) int {
+	n := 0
+	for n < len(runes) && (runes[n] == '_' || unicode.IsLetter(runes[n]) || unicode.IsDigit(runes[n])) {
+		n++
+	}
+	if n == 0 {
+		return 1
+	}
+
+	return n
+}
+
+// ColorEnabled reports whether diagnostics written to w should be colored:
+// w must be a terminal and NO_COLOR must not be set.
+func ColorEnabled(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if _, set := os.LookupEnv("NO_COLOR"); set || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,129 @@
+package diag
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"monkey/vm"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		diagnostic Diagnostic
+		expected   string
+	}{
+		{
+			Diagnostic{Severity: Error, Message: "identifier not found: foo", Code: "NameError",
+				Pos: token.Position{Filename: "main.mk", Line: 2, Column: 9}},
+			`error[NameError]: identifier not found: foo
+ --> main.mk:2:9
+  |
+2 | let x = foo + 1;
+  |         ^^^
+`,
+		},
+		{
+			Diagnostic{Severity: Warning, Message: "unused variable", Length: 5,
+				Pos:   token.Position{Filename: "main.mk", Line: 1, Column: 2},
+				Notes: []string{"declared here"}, Hint: "remove it"},
+			"warning: unused variable\n --> main.mk:1:2\n  |\n1 | \tlet y = 1;\n  | \t^^^^^\n  = note: declared here\n  = hint: remove it\n",
+		},
+		{
+			Diagnostic{Severity: Error, Message: "unexpected ;",
+				Pos: token.Position{Filename: "main.mk", Line: 2, Column: 16}},
+			`error: unexpected ;
+ --> main.mk:2:16
+  |
+2 | let x = foo + 1;
+  |                ^
+`,
+		},
+		{
+			Diagnostic{Severity: Error, Message: "somewhere else",
+				Pos: token.Position{Filename: "other.mk", Line: 4, Column: 1}},
+			"error: somewhere else\n --> other.mk:4:1\n",
+		},
+		{
+			Diagnostic{Severity: Error, Message: "no position"},
+			"error: no position\n",
+		},
+	}
+
+	r := &Renderer{Sources: map[string]string{"main.mk": "\tlet y = 1;\nlet x = foo + 1;\n"}}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		r.Render(&out, tt.diagnostic)
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong output.\nwant=\n%s\ngot=\n%s", tt.expected, out.String())
+		}
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	r := &Renderer{Color: true}
+
+	var out bytes.Buffer
+	r.Render(&out, Diagnostic{Severity: Error, Message: "boom"})
+
+	if !strings.HasPrefix(out.String(), red+"error"+reset) {
+		t.Errorf("expected colored severity, got %q", out.String())
+	}
+}
+
+func TestFromError(t *testing.T) {
+	input := "let a = 1;\nlet b = a + true;"
+
+	p := parser.New(lexer.NewFile("main.mk", "let x = add(1, 2;"))
+	p.ParseProgram()
+	d := FromError(p.Errors()[0])
+	if d.Pos.String() != "main.mk:1:17" || d.Length != 1 || d.Hint == "" {
+		t.Errorf("wrong parse diagnostic: %+v", d)
+	}
+
+	comp := compiler.New()
+	err := comp.Compile(parse(t, "let a = 1;\nb"))
+	if err == nil {
+		t.Fatalf("expected compile error")
+	}
+	d = FromError(err)
+	if d.Pos.String() != "main.mk:2:1" || d.Message != "undefined variable b" {
+		t.Errorf("wrong compile diagnostic: %+v", d)
+	}
+
+	comp = compiler.New()
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine := vm.New(comp.Bytecode())
+	d = FromError(machine.Run())
+	if d.Pos.String() != "main.mk:2:11" || d.Code != object.TYPE_ERROR {
+		t.Errorf("wrong vm diagnostic: %+v", d)
+	}
+
+	_, err = eval.Run(parse(t, input), object.NewEnvironment())
+	d = FromError(err)
+	if d.Pos.String() != "main.mk:2:11" || d.Code != object.TYPE_ERROR {
+		t.Errorf("wrong eval diagnostic: %+v", d)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.NewFile("main.mk", input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
@@ -1,66 +1,43 @@
 package eval
 
-import (
-	"monkey/object"
-)
-
-var builtins = map[string]*object.Builtin{
-	"len":     object.GetBuiltinByName("len"),
-	"exit":    object.GetBuiltinByName("exit"),
-	"push":    object.GetBuiltinByName("push"),
-	"last":    object.GetBuiltinByName("last"),
-	"rest":    object.GetBuiltinByName("rest"),
-	"puts":    object.GetBuiltinByName("puts"),
-	"keys":    object.GetBuiltinByName("keys"),
-	"first":   object.GetBuiltinByName("first"),
-	"toInt":   object.GetBuiltinByName("toInt"),
-	"values":  object.GetBuiltinByName("values"),
-	"toBool":  object.GetBuiltinByName("toBool"),
-	"locals":  {Fn: bltnLocals},
-	"globals": {Fn: bltnGlobals},
-}
-
-func bltnGlobals(env *object.Environment, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newError("the function globals doesnot take arguments. got = %d", len(args))
-	}
-
-	var findGlobal func(*object.Environment) *object.Environment
-	findGlobal = func(env *object.Environment) *object.Environment {
-		if env.Outer() != nil {
-			return findGlobal(env.Outer())
-		} else {
-			return env
-		}
-	}
-
-	globalEnv := findGlobal(env)
-
-	pairs := make(map[object.HashKey]object.HashPair)
-	for key, value := range globalEnv.Store() {
-		pairKey := &object.String{Value: key}
-		pairValue := value
-		pairs[pairKey.HashKey()] = object.HashPair{
-			Key:   pairKey,
-			Value: pairValue,
-		}
-	}
-	return &object.Hash{Pairs: pairs}
-}
-
-func bltnLocals(env *object.Environment, args ...object.Object) object.Object {
-	if len(args) != 0 {
-		return newError("the function globals doesnot take arguments. got = %d", len(args))
-	}
-
-	pairs := make(map[object.HashKey]object.HashPair)
-	for key, value := range env.Store() {
-		pairKey := &object.String{Value: key}
-		pairValue := value
-		pairs[pairKey.HashKey()] = object.HashPair{
-			Key:   pairKey,
-			Value: pairValue,
-		}
-	}
-	return &object.Hash{Pairs: pairs}
+import "monkey/object"
+
+var builtins = map[string]object.Object{
+	"len":         object.GetBuiltinByName("len"),
+	"exit":        object.GetBuiltinByName("exit"),
+	"push":        object.GetBuiltinByName("push"),
+	"last":        object.GetBuiltinByName("last"),
+	"rest":        object.GetBuiltinByName("rest"),
+	"puts":        object.GetBuiltinByName("puts"),
+	"keys":        object.GetBuiltinByName("keys"),
+	"first":       object.GetBuiltinByName("first"),
+	"toInt":       object.GetBuiltinByName("toInt"),
+	"values":      object.GetBuiltinByName("values"),
+	"toBool":      object.GetBuiltinByName("toBool"),
+	"split":       object.GetBuiltinByName("split"),
+	"join":        object.GetBuiltinByName("join"),
+	"trim":        object.GetBuiltinByName("trim"),
+	"trimLeft":    object.GetBuiltinByName("trimLeft"),
+	"trimRight":   object.GetBuiltinByName("trimRight"),
+	"upper":       object.GetBuiltinByName("upper"),
+	"lower":       object.GetBuiltinByName("lower"),
+	"replace":     object.GetBuiltinByName("replace"),
+	"contains":    object.GetBuiltinByName("contains"),
+	"startsWith":  object.GetBuiltinByName("startsWith"),
+	"endsWith":    object.GetBuiltinByName("endsWith"),
+	"indexOf":     object.GetBuiltinByName("indexOf"),
+	"substr":      object.GetBuiltinByName("substr"),
+	"repeat":      object.GetBuiltinByName("repeat"),
+	"chars":       object.GetBuiltinByName("chars"),
+	"padLeft":     object.GetBuiltinByName("padLeft"),
+	"padRight":    object.GetBuiltinByName("padRight"),
+	"deepEqual":   object.GetBuiltinByName("deepEqual"),
+	"has":         object.GetBuiltinByName("has"),
+	"delete":      object.GetBuiltinByName("delete"),
+	"merge":       object.GetBuiltinByName("merge"),
+	"entries":     object.GetBuiltinByName("entries"),
+	"fromEntries": object.GetBuiltinByName("fromEntries"),
+	"json":        object.GetBuiltinByName("json"),
+	"locals":      object.GetBuiltinByName("locals"),
+	"globals":     object.GetBuiltinByName("globals"),
 }
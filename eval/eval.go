@@ -7,6 +7,39 @@ import (
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := evalNode(node, env)
+
+	if tracer := env.Session().Tracer; tracer != nil {
+		tracer.Trace(node, result)
+	}
+
+	// The innermost node a thrown value passes through is where it was
+	// thrown, or where the error was raised.
+	if thrown, ok := result.(*object.ThrownValue); ok && !thrown.Pos.IsValid() {
+		thrown.Pos = node.Pos()
+		if err, ok := thrown.Value.(*object.Error); ok && !err.Pos.IsValid() {
+			err.Pos = thrown.Pos
+		}
+	}
+
+	return result
+}
+
+// Run evaluates program like Eval, but returns an uncaught error as a Go
+// error instead of as the result.
+func Run(program *ast.Program, env *object.Environment) (object.Object, error) {
+	result, err := runProgram(program.Statements, env)
+	if exited := env.Session().Exited(); exited != nil {
+		return nil, exited
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node.Statements, env)
@@ -18,18 +51,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isThrown(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isThrown(left) {
 			return left
 		}
 
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isThrown(right) {
 			return right
 		}
 
@@ -40,13 +73,21 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIfExpression(node, env)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if isThrown(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isThrown(val) {
+			return val
+		}
+		return &object.ThrownValue{Value: val}
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isThrown(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
@@ -55,14 +96,22 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Env: env, Body: body, Name: node.Name}
+	case *ast.MacroLiteral:
+		return newError(object.GENERIC_ERROR, "macros can only be defined by top-level let statements")
 	case *ast.CallExpression:
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			if _, bound := env.Get("quote"); !bound {
+				return quote(node, env)
+			}
+		}
+
 		function := Eval(node.Function, env)
-		if isError(function) {
+		if isThrown(function) {
 			return function
 		}
 		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		if len(args) == 1 && isThrown(args[0]) {
 			return args[0]
 		}
 		return applyFunction(function, env, args)
@@ -70,22 +119,24 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
 		elems := evalExpressions(node.Elements, env)
-		if len(elems) == 1 && isError(elems[0]) {
+		if len(elems) == 1 && isThrown(elems[0]) {
 			return elems[0]
 		}
 		return &object.Array{Elements: elems}
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isThrown(left) {
 			return left
 		}
 
 		idx := Eval(node.Index, env)
-		if isError(idx) {
+		if isThrown(idx) {
 			return idx
 		}
 
 		return evalIndexExpression(left, idx)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 	}
@@ -99,18 +150,31 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return object.FALSE
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+// newError throws a runtime error of the given kind.
+func newError(kind, format string, a ...interface{}) *object.ThrownValue {
+	err := &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+	return &object.ThrownValue{Value: err}
 }
 
-func isError(obj object.Object) bool {
+// isThrown reports whether obj is a value being thrown, which every step
+// passes on until a try expression catches it.
+func isThrown(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+		return obj.Type() == object.THROWN_VALUE_OBJ
 	}
 	return false
 }
 
 func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+	result, err := runProgram(stmts, env)
+	if err != nil {
+		return err
+	}
+
+	return result
+}
+
+func runProgram(stmts []ast.Statement, env *object.Environment) (object.Object, *object.Error) {
 	var result object.Object
 
 	for _, stmt := range stmts {
@@ -118,13 +182,13 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
-			return result.Value
-		case *object.Error:
-			return result
+			return result.Value, nil
+		case *object.ThrownValue:
+			return nil, object.Uncaught(result.Value, result.Pos)
 		}
 	}
 
-	return result
+	return result, nil
 }
 
 func evalPrefixExpression(operator string, right object.Object) object.Object {
@@ -134,7 +198,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -153,7 +217,7 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", right.Type())
 	}
 	value := right.(*object.Integer).Value
 	return &object.Integer{Value: -value}
@@ -166,13 +230,13 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -188,6 +252,9 @@ func evalIntegerInfixOperation(operator string, left, right object.Object) objec
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError(object.GENERIC_ERROR, "division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -202,25 +269,62 @@ func evalIntegerInfixOperation(operator string, left, right object.Object) objec
 	case ">=":
 		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	cond := Eval(ie.Condition, env)
-	if isError(cond) {
+	if isThrown(cond) {
 		return cond
 	}
 
 	if isTruthy(cond) {
-		return Eval(ie.Consequence, env)
+		return evalBlockValue(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return evalBlockValue(ie.Alternative, env)
 	} else {
 		return object.NULL
 	}
 }
 
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := evalBlockValue(te.Block, env)
+	if env.Session().Exited() != nil {
+		return result
+	}
+
+	if thrown, ok := result.(*object.ThrownValue); ok && te.Catch != nil {
+		catchEnv := object.NewCatchEnvironment(env, te.Param.Value, thrown.Value)
+		result = evalBlockValue(te.Catch, catchEnv)
+		if env.Session().Exited() != nil {
+			return result
+		}
+	}
+
+	if te.Finally != nil {
+		final := Eval(te.Finally, env)
+		if final != nil {
+			ft := final.Type()
+			if ft == object.RETURN_VALUE_OBJ || ft == object.THROWN_VALUE_OBJ {
+				return final
+			}
+		}
+	}
+
+	return result
+}
+
+// evalBlockValue evaluates a block used as a value, which is NULL when the
+// block does not end in an expression.
+func evalBlockValue(block *ast.BlockStatement, env *object.Environment) object.Object {
+	if result := Eval(block, env); result != nil {
+		return result
+	}
+
+	return object.NULL
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case object.NULL:
@@ -242,7 +346,7 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.THROWN_VALUE_OBJ {
 				return result
 			}
 		}
@@ -260,7 +364,7 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return bltin
 	}
 
-	return newError("identifier not found: %s", node.Value)
+	return newError(object.NAME_ERROR, "identifier not found: %s", node.Value)
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -268,7 +372,7 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 
 	for _, exp := range exps {
 		evaluated := Eval(exp, env)
-		if isError(evaluated) {
+		if isThrown(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -277,21 +381,55 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
+// maxDepth is how many calls can be in progress, as many as the VM has
+// frames for besides the main one.
+const maxDepth = 1023
+
 func applyFunction(fn object.Object, env *object.Environment, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments: want = %d, got = %d", len(fn.Parameters), len(args))
+		}
+
+		session := env.Session()
+		if session.Depth >= maxDepth {
+			return newError(object.GENERIC_ERROR, "stack overflow")
+		}
+
 		extendedEnv := extendFunctionEnv(fn, args)
+		if session.Tracer != nil {
+			session.Tracer.Call(fn)
+		}
+		session.Depth++
 		evaluated := Eval(fn.Body, extendedEnv)
+		session.Depth--
+		if session.Tracer != nil {
+			session.Tracer.Return(fn)
+		}
+		if evaluated == nil {
+			// The body was empty or ended with a let statement.
+			return object.NULL
+		}
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		if res := fn.Fn(env, args...); res != nil {
+		res := fn.Fn(env, args...)
+		if env.Session().Exited() != nil {
+			// The program called exit: unwind to Run, past every try
+			// expression.
+			return &object.ThrownValue{Value: object.NULL}
+		}
+		if err, ok := res.(*object.Error); ok {
+			return &object.ThrownValue{Value: err}
+		}
+		if res != nil {
 			return res
 		}
 
 		return object.NULL
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError(object.TYPE_ERROR, "not a function: %s", fn.Type())
 	}
 }
 
@@ -314,25 +452,41 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s",
-			left.Type(), operator, right.Type())
-	}
-
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
 
-	return &object.String{Value: leftVal + rightVal}
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.ERROR_OBJ:
+		return left.(*object.Error).Field(index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", left.Type())
 	}
 }
 
@@ -348,41 +502,125 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+
+	idx := index.(*object.Integer).Value
+	max := int64(len(runes) - 1)
+
+	if idx < 0 || idx > max {
+		return object.NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isThrown(left) {
+		return left
+	}
+
+	var length int
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		length = len([]rune(left.Value))
+	default:
+		return newError(object.TYPE_ERROR, "slice operator not supported: %s", left.Type())
+	}
+
+	start, end := 0, length
+	for _, bound := range []struct {
+		node ast.Expression
+		dest *int
+	}{{node.Start, &start}, {node.End, &end}} {
+		if bound.node == nil {
+			continue
+		}
+
+		val := Eval(bound.node, env)
+		if isThrown(val) {
+			return val
+		}
+
+		if val == object.NULL {
+			continue
+		}
+
+		integer, ok := val.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "slice bound must be INTEGER, got %s", val.Type())
+		}
+
+		*bound.dest = clampSliceBound(integer.Value, length)
+	}
+
+	if start > end {
+		start = end
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elems := make([]object.Object, end-start)
+		copy(elems, left.Elements[start:end])
+		return &object.Array{Elements: elems}
+	default:
+		runes := []rune(left.(*object.String).Value)
+		return &object.String{Value: string(runes[start:end])}
+	}
+}
+
+func clampSliceBound(bound int64, length int) int {
+	if bound < 0 {
+		bound += int64(length)
+	}
+
+	if bound < 0 {
+		return 0
+	}
+
+	if bound > int64(length) {
+		return length
+	}
+
+	return int(bound)
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env)
-		if isError(key) {
+		if isThrown(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := Eval(node.Pairs[keyNode], env)
+		if isThrown(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObj := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObj.Pairs[key.HashKey()]
+	pair, ok := hashObj.Get(key)
 	if !ok {
 		return object.NULL
 	}
@@ -1,6 +1,8 @@
 package eval
 
 import (
+	"io"
+	"monkey/ast"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
@@ -511,14 +513,15 @@ func TestHashLiterals(t *testing.T) {
 		object.FALSE.HashKey():                     6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got = %d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got = %d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, pair := range result.Pairs() {
+		expectedValue, ok := expected[pair.Key.(object.Hashable).HashKey()]
 		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+			t.Errorf("unexpected key in Pairs: %s", pair.Key.Inspect())
+			continue
 		}
 
 		testIntegerObject(t, pair.Value, expectedValue)
@@ -571,3 +574,446 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("héllo")`, 5},
+		{`split("a,b,c", ",")`, []string{"a", "b", "c"}},
+		{`split("abc", "")`, []string{"a", "b", "c"}},
+		{`join(["a", "b"], ", ")`, "a, b"},
+		{`join([], "-")`, ""},
+		{`join([1], "-")`, &object.Error{Message: "elements of `join` must be STRING, got INTEGER"}},
+		{`trim("  hi  ")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`trimLeft("  hi  ")`, "hi  "},
+		{`trimRight("  hi  ")`, "  hi"},
+		{`upper("Hello")`, "HELLO"},
+		{`lower("Hello")`, "hello"},
+		{`replace("aaa", "a", "b")`, "bbb"},
+		{`replace("aaa", "a", "b", 2)`, "bba"},
+		{`contains("hello", "ell")`, true},
+		{`contains("hello", "xyz")`, false},
+		{`contains([1, "a"], "a")`, true},
+		{`contains([1, "a"], 2)`, false},
+		{`startsWith("hello", "he")`, true},
+		{`endsWith("hello", "he")`, false},
+		{`indexOf("héllo", "l")`, 2},
+		{`indexOf("hello", "z")`, -1},
+		{`indexOf([3, 4, 5], 5)`, 2},
+		{`substr("héllo", 1, 3)`, "éll"},
+		{`substr("hello", -3)`, "llo"},
+		{`substr("hello", 2, 10)`, "llo"},
+		{`substr("abc", 1, 9223372036854775807)`, "bc"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, &object.Error{Message: "count of `repeat` must not be negative, got -1"}},
+		{`chars("hé")`, []string{"h", "é"}},
+		{`padLeft("7", 3, "0")`, "007"},
+		{`padRight("ab", 5, "xy")`, "abxyx"},
+		{`padLeft("long", 2)`, "long"},
+		{`repeat("ab", 9223372036854775807)`, &object.Error{Message: "result of `repeat` would be longer than 16777216 bytes"}},
+		{`padLeft("a", 99999999999)`, &object.Error{Message: "width of `padLeft` must be at most 16777216, got 99999999999"}},
+		{`upper(1)`, &object.Error{Message: "argument to `upper` must be STRING, got INTEGER"}},
+		{`upper("a", "b")`, &object.Error{Message: "wrong number of arguments. got = 2, want = 1"}},
+		{`trim()`, &object.Error{Message: "wrong number of arguments. got = 0, want = 1 or 2"}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case []string:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got = %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want = %d, got = %d", len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testStringObject(t, array.Elements[i], expectedElem)
+			}
+		case *object.Error:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got = %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected.Message {
+				t.Errorf("wrong error message. expected = %q, got = %q", expected.Message, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestStringIndexAndSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"héllo"[1]`, "é"},
+		{`"hello"[5]`, nil},
+		{`"hello"[-1]`, nil},
+		{`"hello"[1:3]`, "el"},
+		{`"héllo"[-3:]`, "llo"},
+		{`"hello"[:-1]`, "hell"},
+		{`"hello"[3:1]`, ""},
+		{`"hello"[:]`, "hello"},
+		{`let s = "hello"; s[1:][0]`, "e"},
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3][:10]", []int{1, 2, 3}},
+		{"[1, 2, 3][-10:1]", []int{1}},
+		{"[1, 2, 3][2:1]", []int{}},
+		{`[1, 2, 3]["a":]`, &object.Error{Message: "slice bound must be INTEGER, got STRING"}},
+		{`1[1:]`, &object.Error{Message: "slice operator not supported: INTEGER"}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			testStringObject(t, evaluated, expected)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got = %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want = %d, got = %d", len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		case *object.Error:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got = %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected.Message {
+				t.Errorf("wrong error message. expected = %q, got = %q", expected.Message, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"ab" > "a"`, true},
+		{`"a" <= "a"`, true},
+		{`"a" >= "b"`, false},
+		{`let a = "x"; let b = "x"; a == b`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] != [1, 2, 3]", true},
+		{`[[1, "a"], []] == [[1, "a"], []]`, true},
+		{`{"a": [1], 2: true} == {2: true, "a": [1]}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} != {"b": 1}`, true},
+		{"[1] == 1", false},
+		{`deepEqual([1, {"a": [2]}], [1, {"a": [2]}])`, true},
+		{`deepEqual("a", "b")`, false},
+		{"let f = fn() { 1 }; f == f", true},
+		{"fn() { 1 } == fn() { 1 }", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestArrayHashKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{[1, 2]: 5}[[1, 2]]`, 5},
+		{`{[1, 2]: 5}[[2, 1]]`, nil},
+		{`let k = [1, ["a", true]]; {k: 5}[[1, ["a", true]]]`, 5},
+		{`{[{}]: 1}`, "unusable as hash key: ARRAY"},
+		{`{"a": 1}[[{}]]`, "unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got = %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected = %q, got = %q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashOrdering(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, "{b: 1, a: 2, 3: 3, true: 4}"},
+		{`keys({"b": 1, "a": 2, 3: 3})`, "[b, a, 3]"},
+		{`values({"b": [1], "a": 2})`, "[[1], 2]"},
+		{`keys({[1, 2]: 1})[0][1]`, "2"},
+		{`keys({1: 1})[0] + 1`, "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want = %s, got = %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`has({"a": 1}, "a")`, true},
+		{`has({"a": 1}, "b")`, false},
+		{`has({[1]: 1}, [1])`, true},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, "{a: 1, c: 3}"},
+		{`delete({"a": 1}, "z")`, "{a: 1}"},
+		{`let h = {"a": 1}; delete(h, "a"); h`, "{a: 1}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`entries({"a": 1, 2: "b"})`, "[[a, 1], [2, b]]"},
+		{`fromEntries([["a", 1], [2, "b"]])`, "{a: 1, 2: b}"},
+		{`fromEntries(entries({"x": [1], "y": {}}))`, "{x: [1], y: {}}"},
+		{`let h = {"name": "monkey", "inner": {"x": 5}}; h.name`, "monkey"},
+		{`let h = {"inner": {"x": 5}}; h.inner.x`, "5"},
+		{`let h = {"f": fn(x) { x * 2 }}; h.f(4)`, "8"},
+		{`let h = {"a": [1, 2]}; h.a[1]`, "2"},
+		{`{"a": 1}.b`, "null"},
+		{`has(1, "a")`, "ERROR: argument to `has` must be HASH, got INTEGER"},
+		{`has({}, {})`, "ERROR: unusable as hash key: HASH"},
+		{`fromEntries([1])`, "ERROR: entries of `fromEntries` must be [key, value] pairs, got 1"},
+		{`merge({}, [])`, "ERROR: argument to `merge` must be HASH, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. want = %s, got = %s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json.encode({"b": 1, "a": [true, first([]), "x"]})`, `{"b":1,"a":[true,null,"x"]}`},
+		{`json.encode({1: "one", true: "yes"})`, `{"1":"one","true":"yes"}`},
+		{`json.encode("<a href=\"\">")`, `"<a href=\"\">"`},
+		{`json.encode([1, {"a": []}], 2)`, "[\n  1,\n  {\n    \"a\": []\n  }\n]"},
+		{`json.encode({"a": 1}, "\t")`, "{\n\t\"a\": 1\n}"},
+		{`json.encode(1, -1)`, "ERROR: json.encode: indent must be between 0 and 16, got -1"},
+		{`json.encode(1, 9223372036854775807)`, "ERROR: json.encode: indent must be between 0 and 16, got 9223372036854775807"},
+		{`json.encode(fn(x) { x })`, "ERROR: json.encode: cannot encode FUNCTION"},
+		{`json.encode({[1]: 1})`, "ERROR: json.encode: unsupported hash key ARRAY"},
+		{`json.encode(len)`, "ERROR: json.encode: cannot encode BUILTIN"},
+		{`json.decode("{\"z\": 1, \"a\": [true, null, \"s\"], \"n\": {}}")`, "{z: 1, a: [true, null, s], n: {}}"},
+		{`json.decode("[1, 2, 3]")[2]`, "3"},
+		{`json.decode("1.5")`, "ERROR: json.decode: number 1.5 is not an integer"},
+		{`json.decode("[1,")`, "ERROR: json.decode: unexpected end of JSON input"},
+		{`json.decode("1 2")`, "ERROR: json.decode: unexpected data after top-level value"},
+		{`json.decode(json.encode({"k": ["v", -3]})).k[1]`, "-3"},
+		{`json.decode(1)`, "ERROR: argument to `json.decode` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want = %q, got = %q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { throw "boom"; 1 } catch (e) { e }`, "boom"},
+		{`try { 1 } catch (e) { 2 }`, "1"},
+		{`try { 1 + true } catch (e) { e.type }`, "TypeError"},
+		{`try { -true } catch (e) { [e.type, e.message] }`, "[TypeError, unknown operator: -BOOLEAN]"},
+		{`try { len(1) } catch (e) { e.message }`, "argument to `len` not supported, got INTEGER"},
+		{`try { len(1) } catch (e) { e.type }`, "Error"},
+		{`try { fn(x) { x }() } catch (e) { e.type }`, "ArgumentError"},
+		{`try { missing } catch (e) { [e.type, e.message] }`, "[NameError, identifier not found: missing]"},
+		{`let f = fn() { throw {"code": 42}; 1 }; try { f() } catch (e) { e.code }`, "42"},
+		{`let f = fn(x) { if (x == 0) { throw "bottom" } f(x - 1) }; try { f(5) } catch (e) { e }`, "bottom"},
+		{`let r = try { throw 1 } catch (e) { 5 }; r * 2`, "10"},
+		{`1 + try { throw 1 } catch (e) { 2 }`, "3"},
+		{`try { 1 } finally { let done = true; }; done`, "true"},
+		{`try { throw 1 } catch (e) { e + 1 } finally { let done = 5; }`, "2"},
+		{`try { try { throw 1 } finally { let x = 0; } } catch (e) { e + 10 }`, "11"},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, "2"},
+		{`let f = fn() { try { throw 1 } catch (e) { return e + 1 } }; f() + 1`, "3"},
+		{`let f = fn() { try { return 1 } catch (e) { 100 } }; f(); throw 5`, "ERROR: uncaught exception: 5"},
+		{`try { throw 1 } catch (e) { throw e + 1 }`, "ERROR: uncaught exception: 2"},
+		{`try { 1 } catch (e) { 2 } finally { throw "late" }`, "ERROR: uncaught exception: late"},
+		{`throw "x"`, "ERROR: uncaught exception: x"},
+		{`try { {}[fn() {}] } catch (e) { e }`, "ERROR: unusable as hash key: FUNCTION"},
+		{`try { 1 / 0 } catch (e) { [e.type, e.message] }`, "[Error, division by zero]"},
+		{`let f = fn(x) { f(x + 1) }; try { f(0) } catch (e) { [e.type, e.message] }`, "[Error, stack overflow]"},
+		{`try { let x = x; } catch (e) { [e.type, e.message] }`, "[NameError, identifier not found: x]"},
+		{`let e = 1; try { throw 2 } catch (e) { e }; e`, "1"},
+		{`try { throw 2 } catch (e) { let y = e + 1 }; y`, "3"},
+		{`let f = fn() { let y = y + 1; }; try { f() } catch (e) { e.message }`, "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want = %s, got = %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// stepLimit stops programs that run for too long by panicking with itself
+// once it has traced its number of nodes.
+type callCounter int
+
+func (n *callCounter) Call(fn *object.Function)                  { *n++ }
+func (n *callCounter) Return(fn *object.Function)                {}
+func (n *callCounter) Trace(node ast.Node, result object.Object) {}
+
+func TestTracerPerRun(t *testing.T) {
+	program := parser.New(lexer.New(`let f = fn(x) { x }; f(1); f(2)`)).ParseProgram()
+
+	var calls callCounter
+	traced := object.NewEnvironment()
+	SetTracer(traced, &calls)
+
+	Eval(program, object.NewEnvironment())
+	if calls != 0 {
+		t.Fatalf("tracer saw %d calls of another run", calls)
+	}
+
+	Eval(program, traced)
+	if calls != 2 {
+		t.Fatalf("tracer saw %d calls, want 2", calls)
+	}
+}
+
+type stepLimit int
+
+func (n *stepLimit) Call(fn *object.Function)   {}
+func (n *stepLimit) Return(fn *object.Function) {}
+func (n *stepLimit) Trace(node ast.Node, result object.Object) {
+	if *n--; *n < 0 {
+		panic(n)
+	}
+}
+
+func FuzzRun(f *testing.F) {
+	f.Add(`let add = fn add(x, y) { return x + y; }; add(1, 2 * 3)`)
+	f.Add(`let f = fn(a) { fn(b) { a / b } }; f(1)(0)`)
+	f.Add(`let f = fn(x) { f(x + 1) }; try { f(0) } catch (e) { e.message }`)
+	f.Add(`first([]); last([]); rest([]); [1, 2][-1:5]; "abc"[9]; {"a": 1}["b"]`)
+	f.Add(`let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(1 > 2, 3, 4)`)
+	f.Add(`substr("abc", 1, 9223372036854775807); repeat("ab", 9223372036854775807); padLeft("a", 99999999999); json.encode(1, 9223372036854775807)`)
+	f.Add(`let f = fn() { try { exit(2) } catch (e) { e } finally { puts(1) } }; f(); puts(2)`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return
+		}
+
+		limit := stepLimit(100000)
+		defer func() {
+			if r := recover(); r != nil && r != &limit {
+				panic(r)
+			}
+		}()
+
+		macros := object.NewEnvironment()
+		discard(macros.Session())
+		SetTracer(macros, &limit)
+		DefineMacros(program, macros)
+		expanded, err := ExpandMacros(program, macros)
+		if err != nil {
+			return
+		}
+
+		env := object.NewEnvironment()
+		discard(env.Session())
+		SetTracer(env, &limit)
+		Run(expanded, env)
+	})
+}
+
+// discard makes the output of session go nowhere, and exit end only the
+// run.
+func discard(session *object.Session) {
+	session.Output = io.Discard
+	session.Exit = func(status int) {}
+}
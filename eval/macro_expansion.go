@@ -0,0 +1,172 @@
+package eval
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/resolve"
+)
+
+// maxExpansions bounds how often ExpandMacros rescans a program whose
+// macros expand into further macro calls.
+const maxExpansions = 100
+
+// DefineMacros binds the macros defined by top-level `let name = macro(...)`
+// statements of program in env, and removes those statements.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := program.Statements[:0]
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		literal, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		env.Set(let.Name.Value, &object.Macro{
+			Parameters: literal.Parameters,
+			Body:       literal.Body,
+			Env:        env,
+		})
+	}
+
+	program.Statements = statements
+}
+
+// ExpandMacros replaces every call of a macro defined in env with the
+// quoted code the macro returns. The macro is evaluated with its arguments
+// bound to their unevaluated, quoted syntax. Expansion repeats while the
+// generated code calls macros itself. Calls of quote and unquote that are
+// left outside macros are an error, as the compiler cannot compile them.
+func ExpandMacros(program *ast.Program, env *object.Environment) (*ast.Program, error) {
+	for i := 0; i < maxExpansions; i++ {
+		expanded := false
+		var err *object.Error
+
+		program = ast.Modify(program, func(node ast.Node) ast.Node {
+			call, ok := node.(*ast.CallExpression)
+			if !ok || err != nil {
+				return node
+			}
+
+			macro, ok := macroOf(call, env)
+			if !ok {
+				return node
+			}
+
+			var result ast.Node
+			result, err = expandMacro(macro, call)
+			if err != nil {
+				return node
+			}
+
+			expanded = true
+			return result
+		}).(*ast.Program)
+
+		if err != nil {
+			return nil, err
+		}
+		if !expanded {
+			if err := checkQuotes(program); err != nil {
+				return nil, err
+			}
+			return program, nil
+		}
+	}
+
+	return nil, fmt.Errorf("macro expansion did not finish after %d passes", maxExpansions)
+}
+
+// checkQuotes reports the first call of quote or unquote in program that
+// is not inside a macro literal and does not refer to a variable.
+func checkQuotes(program *ast.Program) *object.Error {
+	names := resolve.Program(program)
+
+	var err *object.Error
+	ast.Inspect(program, func(node ast.Node) bool {
+		if _, ok := node.(*ast.MacroLiteral); ok || err != nil {
+			return false
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok || (ident.Value != "quote" && ident.Value != "unquote") {
+			return true
+		}
+		if _, ok := names.Lookup(ident); ok {
+			return true
+		}
+
+		err = &object.Error{
+			Message: fmt.Sprintf("%s can only be used inside macros", ident.Value),
+			Kind:    object.NAME_ERROR,
+			Pos:     ident.Pos(),
+		}
+		return false
+	})
+
+	return err
+}
+
+func macroOf(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func expandMacro(macro *object.Macro, call *ast.CallExpression) (ast.Node, *object.Error) {
+	if len(call.Arguments) != len(macro.Parameters) {
+		return nil, &object.Error{
+			Message: fmt.Sprintf("wrong number of arguments to macro: want = %d, got = %d", len(macro.Parameters), len(call.Arguments)),
+			Kind:    object.ARGUMENT_ERROR,
+			Pos:     call.Pos(),
+		}
+	}
+
+	env := object.NewLocalEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+
+	result := unwrapReturnValue(Eval(macro.Body, env))
+	if thrown, ok := result.(*object.ThrownValue); ok {
+		return nil, object.Uncaught(thrown.Value, thrown.Pos)
+	}
+
+	quote, ok := result.(*object.Quote)
+	if !ok {
+		return nil, &object.Error{
+			Message: fmt.Sprintf("macro must return a quoted expression, got %s", typeOf(result)),
+			Kind:    object.TYPE_ERROR,
+			Pos:     call.Pos(),
+		}
+	}
+
+	return quote.Node, nil
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
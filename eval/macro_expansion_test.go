@@ -0,0 +1,219 @@
+package eval
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got = %d", len(program.Statements))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got = %T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of macro parameters. got = %d", len(macro.Parameters))
+	}
+
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("wrong parameters. got = %s, %s", macro.Parameters[0], macro.Parameters[1])
+	}
+
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("body is not %q. got = %q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+			let infixExpression = macro() { quote(1 + 2); };
+
+			infixExpression();
+			`,
+			`(1 + 2)`,
+		},
+		{
+			`
+			let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+			reverse(2 + 2, 10 - 5);
+			`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+
+			unless(10 > 5, puts("not greater"), puts("greater"));
+			`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`
+			let twice = macro(x) { quote(unquote(x) + unquote(x)); };
+			let four = macro(x) { quote(twice(twice(unquote(x)))); };
+
+			four(a);
+			`,
+			`((a + a) + (a + a))`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("expansion failed: %s", err)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want = %q, got = %q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestMacroExpansionIsHygienic(t *testing.T) {
+	input := `
+	let swap = macro(a, b) {
+		quote(fn(tmp) { [unquote(b), tmp] }(unquote(a)));
+	};
+
+	let tmp = 1;
+	let other = 2;
+	swap(tmp, other);
+	`
+
+	program := testParseProgram(input)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+
+	expanded, err := ExpandMacros(program, env)
+	if err != nil {
+		t.Fatalf("expansion failed: %s", err)
+	}
+
+	result, err := Run(expanded, object.NewEnvironment())
+	if err != nil {
+		t.Fatalf("evaluation failed: %s", err)
+	}
+
+	array, ok := result.(*object.Array)
+	if !ok || len(array.Elements) != 2 {
+		t.Fatalf("expected array of 2 elements. got = %s", result.Inspect())
+	}
+
+	testIntegerObject(t, array.Elements[0], 2)
+	testIntegerObject(t, array.Elements[1], 1)
+}
+
+func TestMacroExpansionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let m = macro(x) { quote(unquote(x)) }; m(1, 2)`,
+			"wrong number of arguments to macro: want = 1, got = 2",
+		},
+		{
+			`let m = macro() { 1 }; m()`,
+			"macro must return a quoted expression, got INTEGER",
+		},
+		{
+			`let m = macro() { throw "boom" }; m()`,
+			"uncaught exception: boom",
+		},
+		{
+			`let m = macro() { quote(m()) }; m()`,
+			"macro expansion did not finish after 100 passes",
+		},
+		{
+			`quote(1 + 2)`,
+			"quote can only be used inside macros",
+		},
+		{
+			`let m = macro(x) { quote(unquote(x)) }; let f = fn() { m(unquote(1)) }`,
+			"unquote can only be used inside macros",
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected error for %q", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want = %q, got = %q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestMacroLiteralOutsideLet(t *testing.T) {
+	err, ok := testEval(`let m = [macro(x) { x }]`).(*object.Error)
+	if !ok {
+		t.Fatalf("expected error")
+	}
+
+	expected := "macros can only be defined by top-level let statements"
+	if err.Message != expected {
+		t.Errorf("wrong error message. want = %q, got = %q", expected, err.Message)
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
@@ -0,0 +1,190 @@
+package eval
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/resolve"
+	"monkey/token"
+	"sync/atomic"
+)
+
+// quote returns its argument unevaluated, except for the `unquote(...)`
+// calls inside it, which are evaluated and replaced by their results.
+//
+// Quoting is hygienic: names bound by let, fn and catch inside the quoted
+// code are renamed to fresh ones, so that code a macro generates can
+// neither capture nor clobber the variables of the code it is expanded
+// into. Code inserted by unquote keeps its names.
+func quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments to quote: want = 1, got = %d", len(call.Arguments))
+	}
+
+	node := ast.Copy(call.Arguments[0])
+	renameBindings(node)
+
+	var thrown object.Object
+	node = ast.Modify(node, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || !isUnquoteCall(call) || thrown != nil {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			thrown = newError(object.ARGUMENT_ERROR, "wrong number of arguments to unquote: want = 1, got = %d", len(call.Arguments))
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if isThrown(unquoted) {
+			thrown = unquoted
+			return node
+		}
+
+		converted, ok := objectToNode(unquoted, call.Pos())
+		if !ok {
+			thrown = newError(object.TYPE_ERROR, "cannot unquote %s", unquoted.Type())
+			return node
+		}
+
+		return converted
+	})
+
+	if thrown != nil {
+		return thrown
+	}
+
+	return &object.Quote{Node: node}
+}
+
+func isUnquoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
+
+// objectToNode turns the result of unquote back into syntax, using pos for
+// the tokens it makes up.
+func objectToNode(obj object.Object, pos token.Position) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprint(obj.Value), Pos: pos}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+		}
+		return &ast.BooleanLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Array:
+		array := &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "[", Pos: pos}, Rbrack: pos}
+		for _, elem := range obj.Elements {
+			node, ok := objectToNode(elem, pos)
+			if !ok {
+				return nil, false
+			}
+			array.Elements = append(array.Elements, node.(ast.Expression))
+		}
+		return array, true
+
+	case *object.Hash:
+		hash := &ast.HashLiteral{
+			Token:  token.Token{Type: token.LBRACE, Literal: "{", Pos: pos},
+			Pairs:  make(map[ast.Expression]ast.Expression),
+			Rbrace: pos,
+		}
+		for _, pair := range obj.Pairs() {
+			key, ok := objectToNode(pair.Key, pos)
+			if !ok {
+				return nil, false
+			}
+			value, ok := objectToNode(pair.Value, pos)
+			if !ok {
+				return nil, false
+			}
+			hash.Keys = append(hash.Keys, key.(ast.Expression))
+			hash.Pairs[key.(ast.Expression)] = value.(ast.Expression)
+		}
+		return hash, true
+
+	case *object.Quote:
+		return obj.Node, true
+	}
+
+	return nil, false
+}
+
+var gensymCounter int64
+
+// gensym returns a fresh name based on name. Identifiers can only hold
+// letters and underscores, so the counter is spelled in letters.
+func gensym(name string) string {
+	n := atomic.AddInt64(&gensymCounter, 1)
+
+	suffix := ""
+	for ; n > 0; n = (n - 1) / 26 {
+		suffix = string(rune('a'+(n-1)%26)) + suffix
+	}
+
+	return name + "__" + suffix
+}
+
+// renameBindings gives every name bound inside node a fresh name, and
+// renames the identifiers that refer to it. Free names, and the arguments
+// of unquote calls, are left alone.
+func renameBindings(node ast.Node) {
+	expr, ok := node.(ast.Expression)
+	if !ok {
+		return
+	}
+
+	unquoted := map[*ast.Identifier]bool{}
+	ast.Inspect(node, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok && isUnquoteCall(call) {
+			for _, arg := range call.Arguments {
+				ast.Inspect(arg, func(node ast.Node) bool {
+					if ident, ok := node.(*ast.Identifier); ok {
+						unquoted[ident] = true
+					}
+					return true
+				})
+			}
+			return false
+		}
+		return true
+	})
+
+	program := &ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: expr}}}
+	resolved := resolve.Program(program)
+
+	names := map[*resolve.Definition]string{}
+	for _, def := range resolved.Defs {
+		if unquoted[def.Name] {
+			continue
+		}
+
+		name := gensym(def.Name.Value)
+		names[def] = name
+		if fn, ok := def.Value.(*ast.FunctionLiteral); ok && fn.Name == def.Name.Value {
+			fn.Name = name
+		}
+		rename(def.Name, name)
+	}
+
+	for _, use := range resolved.Uses {
+		if name, ok := names[use.Def]; ok && !unquoted[use.Ident] {
+			rename(use.Ident, name)
+		}
+	}
+}
+
+func rename(ident *ast.Identifier, name string) {
+	ident.Value = name
+	ident.Token.Literal = name
+}
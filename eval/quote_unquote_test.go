@@ -0,0 +1,141 @@
+package eval
+
+import (
+	"monkey/object"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, tt := range tests {
+		testQuote(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))`, `(8 + (4 + 4))`},
+		{`quote(unquote("a" + "b"))`, `ab`},
+		{`quote(unquote([1, 2]))`, `[1, 2]`},
+		{`quote(unquote({"a": 1}))`, `{a:1}`},
+	}
+
+	for _, tt := range tests {
+		testQuote(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1, 2)`, "wrong number of arguments to quote: want = 1, got = 2"},
+		{`quote(unquote(1, 2))`, "wrong number of arguments to unquote: want = 1, got = 2"},
+		{`quote(unquote(fn(x) { x }))`, "cannot unquote FUNCTION"},
+		{`quote(unquote(missing))`, "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("expected error for %q", tt.input)
+			continue
+		}
+
+		if err.Message != tt.expected {
+			t.Errorf("wrong error message. want = %q, got = %q", tt.expected, err.Message)
+		}
+	}
+}
+
+func TestQuoteIsHygienic(t *testing.T) {
+	quote, ok := testEval(`quote(fn(x) { let y = x; y + unquote(quote(x)) })`).(*object.Quote)
+	if !ok {
+		t.Fatalf("expected *object.Quote")
+	}
+
+	fn := quote.Node.String()
+	x, y := gensymOf(fn, "x"), gensymOf(fn, "y")
+	if x == "" || y == "" {
+		t.Fatalf("bindings were not renamed: %s", fn)
+	}
+
+	// The x inserted by unquote refers to the caller's x.
+	expected := "fn(" + x + ") let " + y + " = " + x + ";(" + y + " + x)"
+	if fn != expected {
+		t.Errorf("wrong quote. want = %q, got = %q", expected, fn)
+	}
+}
+
+func TestQuoteLeavesFreeNames(t *testing.T) {
+	quote, ok := testEval(`quote(fn(x) { x }(x))`).(*object.Quote)
+	if !ok {
+		t.Fatalf("expected *object.Quote")
+	}
+
+	call := quote.Node.String()
+	x := gensymOf(call, "x")
+	if x == "" {
+		t.Fatalf("parameter was not renamed: %s", call)
+	}
+
+	// The argument is outside the function, so it is not the parameter.
+	expected := "fn(" + x + ") " + x + "(x)"
+	if call != expected {
+		t.Errorf("wrong quote. want = %q, got = %q", expected, call)
+	}
+}
+
+// gensymOf finds the fresh name generated for name in s.
+func gensymOf(s, name string) string {
+	for i := 0; i+len(name)+2 <= len(s); i++ {
+		if s[i:i+len(name)+2] != name+"__" {
+			continue
+		}
+		j := i + len(name) + 2
+		for j < len(s) && 'a' <= s[j] && s[j] <= 'z' {
+			j++
+		}
+		return s[i:j]
+	}
+	return ""
+}
+
+func testQuote(t *testing.T, evaluated object.Object, expected string) {
+	t.Helper()
+
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		t.Fatalf("expected *object.Quote. got = %T (%+v)", evaluated, evaluated)
+	}
+
+	if quote.Node == nil {
+		t.Fatalf("quote.Node is nil")
+	}
+
+	if quote.Node.String() != expected {
+		t.Errorf("not equal. got = %q, want = %q", quote.Node.String(), expected)
+	}
+}
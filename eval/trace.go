@@ -0,0 +1,12 @@
+package eval
+
+import "monkey/object"
+
+// Tracer follows evaluation; see object.Tracer.
+type Tracer = object.Tracer
+
+// SetTracer installs t for the run env belongs to, or removes the tracer
+// if t is nil.
+func SetTracer(env *object.Environment, t Tracer) {
+	env.Session().Tracer = t
+}
@@ -0,0 +1,525 @@
+// Package format prints monkey programs in a canonical layout: tab
+// indentation, single spaces around binary operators, only the parentheses
+// precedence requires, and a semicolon after every statement that is not
+// the last of its block. Array, hash and argument lists that do not fit in
+// the line width are broken one element per line, and comments are kept.
+//
+// Where the layout is a matter of taste the source decides: a block or list
+// stays on one line only if it was written on one line, and single blank
+// lines between statements are preserved.
+package format
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	width    = 80
+	tabWidth = 4
+)
+
+// Source formats the program in src. If src does not parse, the first
+// syntax error is returned.
+func Source(src string) (string, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		return "", errs[0]
+	}
+
+	return Program(program, l.Comments(), src), nil
+}
+
+// Program formats program, which was parsed from src. The comments are
+// the ones the lexer skipped while reading src.
+func Program(program *ast.Program, comments []token.Comment, src string) string {
+	p := &printer{lines: strings.Split(src, "\n"), comments: comments}
+
+	p.stmtList(program.Statements, token.Position{Line: len(p.lines) + 1}, true)
+	if p.out.Len() == 0 {
+		return ""
+	}
+
+	p.out.WriteString("\n")
+	return p.out.String()
+}
+
+type printer struct {
+	out    strings.Builder
+	indent int
+	col    int
+
+	// pending is set after a newline; the indentation is written with the
+	// next text so that blank lines have no trailing whitespace.
+	pending bool
+
+	lines    []string
+	comments []token.Comment
+	next     int
+
+	// srcLine is the source line of the last thing printed, used to keep
+	// trailing comments on the line they were written on.
+	srcLine int
+
+	// In flat mode the printer fails instead of breaking a line.
+	flat   bool
+	failed bool
+}
+
+func (p *printer) write(s string) {
+	if p.pending {
+		p.out.WriteString(strings.Repeat("\t", p.indent))
+		p.col = p.indent * tabWidth
+		p.pending = false
+	}
+
+	p.out.WriteString(s)
+	p.col += utf8.RuneCountInString(s)
+}
+
+func (p *printer) newline() {
+	if p.flat {
+		p.failed = true
+		return
+	}
+
+	p.out.WriteString("\n")
+	p.pending = true
+	p.col = 0
+}
+
+// flatten renders fn on a single line. It reports false if that is not
+// possible, because a block or list has to span several lines.
+func (p *printer) flatten(fn func(q *printer)) (string, bool) {
+	q := &printer{lines: p.lines, flat: true}
+	fn(q)
+
+	return q.out.String(), !q.failed
+}
+
+func (p *printer) fits(s string) bool {
+	return p.col+utf8.RuneCountInString(s) <= width
+}
+
+// blankBefore reports whether the source line before line is empty.
+func (p *printer) blankBefore(line int) bool {
+	return line >= 2 && line-2 < len(p.lines) && strings.TrimSpace(p.lines[line-2]) == ""
+}
+
+// trailing reports whether code precedes c on its line.
+func (p *printer) trailing(c token.Comment) bool {
+	if c.Pos.Line > len(p.lines) {
+		return false
+	}
+
+	line := []rune(p.lines[c.Pos.Line-1])
+	if c.Pos.Column-1 > len(line) {
+		return false
+	}
+
+	return strings.TrimSpace(string(line[:c.Pos.Column-1])) != ""
+}
+
+// hasComments reports whether a comment is waiting to be printed before
+// pos.
+func (p *printer) hasComments(pos token.Position) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Pos.Before(pos)
+}
+
+// flushComments prints the comments before pos. A comment that trailed
+// code on the line last printed stays there; the others are placed on
+// lines of their own, each preceded by sep.
+func (p *printer) flushComments(pos token.Position, sep func(line int)) {
+	for p.hasComments(pos) {
+		c := p.comments[p.next]
+		p.next++
+
+		if p.trailing(c) && c.Pos.Line == p.srcLine && p.out.Len() > 0 {
+			p.write(" " + c.Text)
+			continue
+		}
+
+		sep(c.Pos.Line)
+		p.write(c.Text)
+		p.srcLine = c.Pos.Line
+	}
+}
+
+// stmtList prints the statements of a block or, if top is set, of the
+// program, with the comments that come before end.
+func (p *printer) stmtList(stmts []ast.Statement, end token.Position, top bool) {
+	first := true
+	sep := func(line int) {
+		if !first || !top {
+			p.newline()
+		}
+		if !first && p.blankBefore(line) {
+			p.newline()
+		}
+		first = false
+	}
+
+	for i, stmt := range stmts {
+		pos := start(stmt)
+		p.flushComments(pos, sep)
+		sep(pos.Line)
+
+		var next ast.Statement
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+		}
+		p.stmt(stmt, next)
+	}
+
+	p.flushComments(end, sep)
+}
+
+func (p *printer) stmt(stmt ast.Statement, next ast.Statement) {
+	p.srcLine = stmt.Pos().Line
+
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.Value + " = ")
+		p.expr(stmt.Value)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expr(stmt.ReturnValue)
+		p.write(";")
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expr(stmt.Value)
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expr(stmt.Expression)
+		if next != nil && needsSemicolon(stmt, next) {
+			p.write(";")
+		}
+	}
+}
+
+// needsSemicolon reports whether the expression statement stmt must be
+// terminated before next. Statements ending in a block read better without
+// one, unless next would then continue them as a call, index or
+// subtraction.
+func needsSemicolon(stmt *ast.ExpressionStatement, next ast.Statement) bool {
+	switch stmt.Expression.(type) {
+	case *ast.IfExpression, *ast.TryExpression:
+	default:
+		return true
+	}
+
+	es, ok := next.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	switch es.Token.Type {
+	case token.LPAREN, token.LBRACKET, token.MINUS:
+		return true
+	}
+	return false
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+	if len(b.Statements) == 0 && !p.hasComments(b.Rbrace) {
+		p.write("{}")
+		return
+	}
+
+	if s, ok := p.inlineBlock(b); ok && (p.flat || p.fits(s)) {
+		p.write(s)
+		p.srcLine = b.Rbrace.Line
+		return
+	}
+
+	if p.flat {
+		p.failed = true
+		return
+	}
+
+	p.write("{")
+	p.srcLine = b.Token.Pos.Line
+	p.indent++
+	p.stmtList(b.Statements, b.Rbrace, false)
+	p.indent--
+	p.newline()
+	p.write("}")
+	p.srcLine = b.Rbrace.Line
+}
+
+// inlineBlock renders b as `{ expr }` if it holds a single expression that
+// was written on the same line as the opening brace.
+func (p *printer) inlineBlock(b *ast.BlockStatement) (string, bool) {
+	if len(b.Statements) != 1 || b.Statements[0].Pos().Line != b.Token.Pos.Line {
+		return "", false
+	}
+
+	stmt, ok := b.Statements[0].(*ast.ExpressionStatement)
+	if !ok || p.hasComments(b.Rbrace) {
+		return "", false
+	}
+
+	s, ok := p.flatten(func(q *printer) { q.expr(stmt.Expression) })
+	return "{ " + s + " }", ok
+}
+
+// list prints the elements of an array, hash or argument list between
+// open and close.
+func (p *printer) list(open, close string, openPos, closePos token.Position, starts []token.Position, elem func(q *printer, i int)) {
+	inline := func(q *printer) {
+		q.write(open)
+		for i := range starts {
+			if i > 0 {
+				q.write(", ")
+			}
+			elem(q, i)
+		}
+		q.write(close)
+	}
+
+	broken := len(starts) > 0 && starts[0].Line > openPos.Line
+	if !broken {
+		s, ok := p.flatten(inline)
+		if ok && (p.flat || p.fits(s)) {
+			p.write(s)
+			return
+		}
+		if p.flat {
+			p.failed = true
+			return
+		}
+		if !ok {
+			// An element spans several lines anyway, such as a function
+			// literal passed as an argument; let it do so in place.
+			inline(p)
+			return
+		}
+	}
+
+	if p.flat {
+		p.failed = true
+		return
+	}
+
+	sep := func(int) { p.newline() }
+
+	p.write(open)
+	p.indent++
+	for i, pos := range starts {
+		p.flushComments(pos, sep)
+		p.newline()
+		p.srcLine = pos.Line
+		elem(p, i)
+		if i < len(starts)-1 {
+			p.write(",")
+		}
+	}
+	p.flushComments(closePos, sep)
+	p.indent--
+	p.newline()
+	p.write(close)
+	p.srcLine = closePos.Line
+}
+
+func (p *printer) exprs(open, close string, openPos, closePos token.Position, list []ast.Expression) {
+	starts := make([]token.Position, len(list))
+	for i, e := range list {
+		starts[i] = start(e)
+	}
+
+	p.list(open, close, openPos, closePos, starts, func(q *printer, i int) { q.expr(list[i]) })
+}
+
+func (p *printer) expr(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.write(e.Token.Literal)
+	case *ast.BooleanLiteral:
+		p.write(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(quote(e.Value))
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.operand(e.Right, precedence(e.Right) < precPrefix)
+	case *ast.InfixExpression:
+		prec := infixPrecedence[e.Operator]
+		p.operand(e.Left, precedence(e.Left) < prec)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, precedence(e.Right) <= prec)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expr(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.write("fn(" + paramList(e.Parameters) + ") ")
+		p.block(e.Body)
+	case *ast.MacroLiteral:
+		p.write("macro(" + paramList(e.Parameters) + ") ")
+		p.block(e.Body)
+	case *ast.CallExpression:
+		p.operand(e.Function, precedence(e.Function) < precCall)
+		p.exprs("(", ")", e.Token.Pos, e.Rparen, e.Arguments)
+	case *ast.ArrayLiteral:
+		p.exprs("[", "]", e.Token.Pos, e.Rbrack, e.Elements)
+	case *ast.HashLiteral:
+		starts := make([]token.Position, len(e.Keys))
+		for i, key := range e.Keys {
+			starts[i] = start(key)
+		}
+		p.list("{", "}", e.Token.Pos, e.Rbrace, starts, func(q *printer, i int) {
+			q.expr(e.Keys[i])
+			q.write(": ")
+			q.expr(e.Pairs[e.Keys[i]])
+		})
+	case *ast.IndexExpression:
+		p.operand(e.Left, precedence(e.Left) < precCall)
+		if e.Token.Type == token.DOT {
+			p.write("." + e.Index.(*ast.StringLiteral).Value)
+			return
+		}
+		p.write("[")
+		p.expr(e.Index)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.operand(e.Left, precedence(e.Left) < precCall)
+		p.write("[")
+		if e.Start != nil {
+			p.expr(e.Start)
+		}
+		p.write(":")
+		if e.End != nil {
+			p.expr(e.End)
+		}
+		p.write("]")
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(e.Block)
+		if e.Catch != nil {
+			p.write(" catch (" + e.Param.Value + ") ")
+			p.block(e.Catch)
+		}
+		if e.Finally != nil {
+			p.write(" finally ")
+			p.block(e.Finally)
+		}
+	}
+}
+
+func paramList(params []*ast.Identifier) string {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.Value
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func (p *printer) operand(e ast.Expression, parens bool) {
+	if parens {
+		p.write("(")
+	}
+	p.expr(e)
+	if parens {
+		p.write(")")
+	}
+}
+
+// Binding strengths, matching the parser's precedences.
+const (
+	_ int = iota
+	precLowest
+	precEquals
+	precLessGreater
+	precSum
+	precProduct
+	precPrefix
+	precCall
+	precAtom
+)
+
+var infixPrecedence = map[string]int{
+	"==": precEquals,
+	"!=": precEquals,
+	"<=": precEquals,
+	">=": precEquals,
+	"<":  precLessGreater,
+	">":  precLessGreater,
+	"+":  precSum,
+	"-":  precSum,
+	"*":  precProduct,
+	"/":  precProduct,
+}
+
+// precedence returns how tightly e binds. Calls, index and slice
+// expressions chain freely, so they share a level.
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return infixPrecedence[e.Operator]
+	case *ast.PrefixExpression:
+		return precPrefix
+	case *ast.CallExpression, *ast.IndexExpression, *ast.SliceExpression:
+		return precCall
+	default:
+		return precAtom
+	}
+}
+
+// start returns the position of the first token of node, which for infix
+// and postfix expressions is not the token the node keeps.
+func start(node ast.Node) token.Position {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return start(node.Expression)
+	case *ast.InfixExpression:
+		return start(node.Left)
+	case *ast.CallExpression:
+		return start(node.Function)
+	case *ast.IndexExpression:
+		return start(node.Left)
+	case *ast.SliceExpression:
+		return start(node.Left)
+	default:
+		return node.Pos()
+	}
+}
+
+func quote(s string) string {
+	var out strings.Builder
+
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
@@ -0,0 +1,96 @@
+package format
+
+import (
+	"monkey/stdlib"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"(1 + 2) * 3; 1 - (2 - 3); (1 - 2) - 3", "(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3\n"},
+		{"-(1 + 2); !(!true); (-a)[0]; -a[0]", "-(1 + 2);\n!!true;\n(-a)[0];\n-a[0]\n"},
+		{"f(1)(2)[0][1:]; h.name", "f(1)(2)[0][1:];\nh.name\n"},
+		{`"a \"b\" \\ \n"`, `"a \"b\" \\ \n"` + "\n"},
+		{"let f = fn(a,b) { a + b };", "let f = fn(a, b) { a + b };\n"},
+		{"let f = fn() {};", "let f = fn() {};\n"},
+		{"let f = fn(a) {\na };", "let f = fn(a) {\n\ta\n};\n"},
+		{"let f = fn(a) { return a; };", "let f = fn(a) {\n\treturn a;\n};\n"},
+		{
+			"if (a) { 1 } else { 2 }\nif (b) {\nthrow 1;\n}\nputs(1)",
+			"if (a) { 1 } else { 2 }\nif (b) {\n\tthrow 1;\n}\nputs(1)\n",
+		},
+		{"if (a) { 1 }; [1]", "if (a) { 1 };\n[1]\n"},
+		{
+			"try { 1 } catch (e) { 2 } finally { 3 }",
+			"try { 1 } catch (e) { 2 } finally { 3 }\n",
+		},
+		{`{"a":1, "b": [1,2]}`, `{"a": 1, "b": [1, 2]}` + "\n"},
+		{
+			"let xs = [\n1, 2];",
+			"let xs = [\n\t1,\n\t2\n];\n",
+		},
+		{
+			`let h = {"first": "aaaaaaaaaaaaaaaa", "second": "bbbbbbbbbbbbbbbb", "third": "cccc"};`,
+			"let h = {\n\t\"first\": \"aaaaaaaaaaaaaaaa\",\n\t\"second\": \"bbbbbbbbbbbbbbbb\",\n\t\"third\": \"cccc\"\n};\n",
+		},
+		{
+			"map(xs, fn(x) {\nx * 2\n})",
+			"map(xs, fn(x) {\n\tx * 2\n})\n",
+		},
+		{
+			"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			"// head\n\nlet a = 1; // one\n// before b\nlet b = fn() {\n  // inside\n  2 // two\n  // last\n};\n// tail",
+			"// head\n\nlet a = 1; // one\n// before b\nlet b = fn() {\n\t// inside\n\t2 // two\n\t// last\n};\n// tail\n",
+		},
+		{
+			"let xs = [\n  1, // one\n  // two\n  2\n];",
+			"let xs = [\n\t1, // one\n\t// two\n\t2\n];\n",
+		},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		actual, err := Source(tt.input)
+		if err != nil {
+			t.Fatalf("Source(%q) failed: %s", tt.input, err)
+		}
+
+		if actual != tt.expected {
+			t.Errorf("wrong output for %q.\nwant=\n%s\ngot=\n%s", tt.input, tt.expected, actual)
+		}
+
+		again, err := Source(actual)
+		if err != nil {
+			t.Fatalf("formatted output does not parse: %s\n%s", err, actual)
+		}
+		if again != actual {
+			t.Errorf("formatting is not idempotent.\nfirst=\n%s\nsecond=\n%s", actual, again)
+		}
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	if _, err := Source("let = 1;"); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
+
+func TestStdlibIsFormatted(t *testing.T) {
+	for name, src := range stdlib.Sources() {
+		actual, err := Source(src)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if actual != src {
+			t.Errorf("%s is not formatted. got=\n%s", name, actual)
+		}
+	}
+}
@@ -32,6 +32,7 @@ if (5 < 10) {
 "foo bar"
 [1, 2];
 {"foo": "bar", "baz": 10}
+h.a
 `
 
 	tests := []struct {
@@ -130,6 +131,9 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.INT, "10"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "h"},
+		{token.DOT, "."},
+		{token.IDENT, "a"},
 		{token.EOF, ""},
 	}
 
@@ -147,3 +151,141 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+// another one
+x / 2
+//`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected = %q, got = %q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected = %q, got = %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	expectedComments := []token.Comment{
+		{Pos: token.Position{Line: 1, Column: 1}, Text: "// leading comment"},
+		{Pos: token.Position{Line: 2, Column: 12}, Text: "// trailing comment"},
+		{Pos: token.Position{Line: 3, Column: 1}, Text: "// another one"},
+		{Pos: token.Position{Line: 5, Column: 1}, Text: "//"},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected = %d, got = %d", len(expectedComments), len(comments))
+	}
+
+	for i, c := range expectedComments {
+		if comments[i] != c {
+			t.Errorf("comments[%d] wrong. expected = %+v, got = %+v", i, c, comments[i])
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"a\nb\tc\rd"`, "a\nb\tc\rd"},
+		{`"back\\slash"`, `back\slash`},
+		{`"unknown \q"`, `unknown \q`},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("tests[%d] - tokentype wrong. expected = %q, got = %q", i, token.STRING, tok.Type)
+		}
+
+		if tok.Literal != tt.expected {
+			t.Fatalf("tests[%d] - literal wrong. expected = %q, got = %q", i, tt.expected, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+// comment
+  "héllo" + ab
+`
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+	}{
+		{token.LET, token.Position{Line: 1, Column: 1}},
+		{token.IDENT, token.Position{Line: 1, Column: 5}},
+		{token.ASSIGN, token.Position{Line: 1, Column: 7}},
+		{token.INT, token.Position{Line: 1, Column: 9}},
+		{token.SEMICOLON, token.Position{Line: 1, Column: 10}},
+		{token.STRING, token.Position{Line: 3, Column: 3}},
+		{token.PLUS, token.Position{Line: 3, Column: 11}},
+		{token.IDENT, token.Position{Line: 3, Column: 13}},
+		{token.EOF, token.Position{Line: 4, Column: 1}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - position wrong. expected=%s, got=%s", i, tt.expectedPos, tok.Pos)
+		}
+	}
+}
+
+func FuzzNextToken(f *testing.F) {
+	f.Add(`let add = fn(x, y) { x + y; }; add(1, 2)`)
+	f.Add(`"unterminated \"string`)
+	f.Add(`// comment` + "\n" + `[1, 2][0:1] {"a": 1} <= >= != == @`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		l := New(input)
+
+		// Every token but EOF takes at least a byte of input.
+		for i := 0; ; i++ {
+			if i > len(input) {
+				t.Fatalf("no EOF after %d tokens of %q", i, input)
+			}
+
+			tok := l.NextToken()
+			if tok.Type == token.EOF {
+				break
+			}
+		}
+	})
+}
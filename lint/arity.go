@@ -0,0 +1,76 @@
+package lint
+
+import "fmt"
+
+// arity is the number of arguments a builtin accepts. A negative max means
+// any number from min on.
+type arity struct {
+	min, max int
+}
+
+func (a arity) accepts(n int) bool {
+	return n >= a.min && (a.max < 0 || n <= a.max)
+}
+
+func (a arity) String() string {
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 argument"
+		}
+		return fmt.Sprintf("%d arguments", n)
+	}
+
+	switch {
+	case a.max < 0:
+		return "at least " + plural(a.min)
+	case a.min == a.max:
+		return plural(a.min)
+	case a.max == a.min+1:
+		return fmt.Sprintf("%d or %s", a.min, plural(a.max))
+	default:
+		return fmt.Sprintf("%d to %s", a.min, plural(a.max))
+	}
+}
+
+// arities lists the arguments accepted by every builtin in object.Builtins,
+// with module functions under `module.name`.
+var arities = map[string]arity{
+	"len":         {1, 1},
+	"exit":        {0, 1},
+	"push":        {2, 2},
+	"last":        {1, 1},
+	"rest":        {1, 1},
+	"puts":        {0, -1},
+	"keys":        {1, 1},
+	"first":       {1, 1},
+	"toInt":       {1, 1},
+	"values":      {1, 1},
+	"toBool":      {1, 1},
+	"split":       {2, 2},
+	"join":        {2, 2},
+	"trim":        {1, 2},
+	"trimLeft":    {1, 2},
+	"trimRight":   {1, 2},
+	"upper":       {1, 1},
+	"lower":       {1, 1},
+	"replace":     {3, 4},
+	"contains":    {2, 2},
+	"startsWith":  {2, 2},
+	"endsWith":    {2, 2},
+	"indexOf":     {2, 2},
+	"substr":      {2, 3},
+	"repeat":      {2, 2},
+	"chars":       {1, 1},
+	"padLeft":     {2, 3},
+	"padRight":    {2, 3},
+	"deepEqual":   {2, 2},
+	"has":         {2, 2},
+	"delete":      {2, 2},
+	"merge":       {2, 2},
+	"entries":     {1, 1},
+	"fromEntries": {1, 1},
+	"json.encode": {1, 2},
+	"json.decode": {1, 1},
+	"locals":      {0, 0},
+	"globals":     {0, 0},
+}
@@ -0,0 +1,188 @@
+// Package lint finds common mistakes in monkey programs: unused local
+// bindings, parameters shadowed by a let, builtins called with the wrong
+// number of arguments, and statements that can never run. Names are
+// resolved by the resolve package, so the linter sees the same scopes as
+// compiled code.
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/diag"
+	"monkey/resolve"
+	"monkey/token"
+	"sort"
+)
+
+// Rules.
+const (
+	Unused      = "unused"
+	Shadow      = "shadow"
+	Arity       = "arity"
+	Unreachable = "unreachable"
+)
+
+// Rules lists every rule, in the order they are documented.
+var Rules = []string{Unused, Shadow, Arity, Unreachable}
+
+// Issue is a problem found by a rule.
+type Issue struct {
+	Rule    string
+	Pos     token.Position
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", i.Pos, i.Message, i.Rule)
+}
+
+// Diagnostic returns the issue as a warning for the diag renderer.
+func (i Issue) Diagnostic() diag.Diagnostic {
+	return diag.Diagnostic{Severity: diag.Warning, Code: i.Rule, Message: i.Message, Pos: i.Pos}
+}
+
+// Check runs the given rules over program, or all of them if rules is
+// empty, and returns the issues sorted by position.
+func Check(program *ast.Program, rules ...string) []Issue {
+	if len(rules) == 0 {
+		rules = Rules
+	}
+
+	c := &checker{enabled: make(map[string]bool), names: resolve.Program(program)}
+	for _, rule := range rules {
+		c.enabled[rule] = true
+	}
+
+	c.checkDefinitions()
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			c.checkReachable(node.Statements)
+		case *ast.BlockStatement:
+			c.checkReachable(node.Statements)
+		case *ast.CallExpression:
+			c.checkArity(node)
+		}
+		return true
+	})
+
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		return c.issues[i].Pos.Before(c.issues[j].Pos)
+	})
+
+	return c.issues
+}
+
+type checker struct {
+	issues  []Issue
+	enabled map[string]bool
+	names   *resolve.Result
+}
+
+func (c *checker) report(rule string, pos token.Position, format string, a ...interface{}) {
+	if !c.enabled[rule] {
+		return
+	}
+
+	c.issues = append(c.issues, Issue{Rule: rule, Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+// checkDefinitions reports let statements inside functions that are never
+// used, and parameters shadowed by a let or by another parameter. Globals
+// are not reported when unused, since code loaded later may use them.
+func (c *checker) checkDefinitions() {
+	used := make(map[*resolve.Definition]bool)
+	for _, use := range c.names.Uses {
+		// A function referring to itself by name does not use the let
+		// statement it is bound to.
+		if use.Symbol.Scope != compiler.FunctionScope {
+			used[use.Def] = true
+		}
+	}
+
+	type param struct {
+		fn   ast.Node
+		name string
+	}
+	params := make(map[param]bool)
+
+	for _, def := range c.names.Defs {
+		p := param{def.Func, def.Name.Value}
+
+		switch def.Kind {
+		case resolve.Param:
+			if params[p] {
+				c.report(Shadow, def.Name.Pos(), "parameter `%s` is declared more than once", def.Name.Value)
+			}
+			params[p] = true
+
+		case resolve.Let:
+			if params[p] {
+				c.report(Shadow, def.Name.Pos(), "`%s` shadows the parameter of the same name", def.Name.Value)
+			}
+			if def.Func != nil && !used[def] {
+				c.report(Unused, def.Name.Pos(), "`%s` is declared but never used", def.Name.Value)
+			}
+		}
+	}
+}
+
+// checkReachable reports the first statement after a return or throw.
+func (c *checker) checkReachable(stmts []ast.Statement) {
+	var exit ast.Statement
+
+	for _, stmt := range stmts {
+		if exit != nil {
+			c.report(Unreachable, stmt.Pos(), "unreachable code after %s", exit.TokenLiteral())
+			exit = nil
+		}
+
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			exit = stmt
+		}
+	}
+}
+
+func (c *checker) checkArity(call *ast.CallExpression) {
+	name, pos, ok := builtinName(call.Function)
+	if !ok {
+		return
+	}
+
+	if use, ok := c.names.Lookup(rootIdent(call.Function)); !ok || use.Symbol.Scope != compiler.BuiltinScope {
+		return
+	}
+
+	a, ok := arities[name]
+	if !ok || a.accepts(len(call.Arguments)) {
+		return
+	}
+
+	c.report(Arity, pos, "`%s` takes %s, got %d", name, a, len(call.Arguments))
+}
+
+// builtinName returns the name a call refers to if it could be a builtin:
+// either `name` or `module.name`.
+func builtinName(e ast.Expression) (string, token.Position, bool) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		return e.Value, e.Pos(), true
+	case *ast.IndexExpression:
+		left, ok := e.Left.(*ast.Identifier)
+		if !ok || e.Token.Type != token.DOT {
+			return "", token.Position{}, false
+		}
+		return left.Value + "." + e.Index.(*ast.StringLiteral).Value, left.Pos(), true
+	}
+
+	return "", token.Position{}, false
+}
+
+func rootIdent(e ast.Expression) *ast.Identifier {
+	if index, ok := e.(*ast.IndexExpression); ok {
+		return index.Left.(*ast.Identifier)
+	}
+	return e.(*ast.Identifier)
+}
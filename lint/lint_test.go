@@ -0,0 +1,151 @@
+package lint
+
+import (
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		rules    []string
+		expected []string
+	}{
+		{
+			`let f = fn(x) { let unused = 1; let y = 2; x + y };`,
+			nil,
+			[]string{"1:21: `unused` is declared but never used (unused)"},
+		},
+		{
+			// Globals may be used by code loaded later, and recursion
+			// alone does not count as a use.
+			`let g = 1; let f = fn() { let loop = fn() { loop() }; 1 };`,
+			nil,
+			[]string{"1:31: `loop` is declared but never used (unused)"},
+		},
+		{
+			`let f = fn() { let a = 1; let b = fn() { a }; b() };`,
+			nil,
+			nil,
+		},
+		{
+			`let f = fn() { let a = 1; let a = 2; a };`,
+			nil,
+			[]string{"1:20: `a` is declared but never used (unused)"},
+		},
+		{
+			`let f = fn(x, y, x) { let y = 1; x + y };`,
+			nil,
+			[]string{
+				"1:18: parameter `x` is declared more than once (shadow)",
+				"1:27: `y` shadows the parameter of the same name (shadow)",
+			},
+		},
+		{
+			`let f = fn(arr) { let g = fn(arr) { arr }; g(arr) };`,
+			nil,
+			nil,
+		},
+		{
+			`len(1, 2); len("a"); puts(); exit(1, 2); json.encode(); json.decode("1")`,
+			nil,
+			[]string{
+				"1:1: `len` takes 1 argument, got 2 (arity)",
+				"1:30: `exit` takes 0 or 1 argument, got 2 (arity)",
+				"1:42: `json.encode` takes 1 or 2 arguments, got 0 (arity)",
+			},
+		},
+		{
+			`let len = fn(a, b) { a }; len(1, 2)`,
+			nil,
+			nil,
+		},
+		{
+			`let f = fn() { return 1; puts(2); puts(3) }; let g = fn() { if (true) { throw 1; 2 } };`,
+			nil,
+			[]string{
+				"1:26: unreachable code after return (unreachable)",
+				"1:82: unreachable code after throw (unreachable)",
+			},
+		},
+		{
+			`let f = fn(x) { let x = 1; let y = len(); return x; 1 };`,
+			[]string{Arity, Unreachable},
+			[]string{
+				"1:36: `len` takes 1 argument, got 0 (arity)",
+				"1:53: unreachable code after return (unreachable)",
+			},
+		},
+		{
+			`try { 1 } catch (e) { 2 }`,
+			nil,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		issues := Check(program, tt.rules...)
+
+		actual := make([]string, len(issues))
+		for i, issue := range issues {
+			actual[i] = issue.String()
+		}
+
+		if strings.Join(actual, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong issues for %q.\nwant=\n%s\ngot=\n%s", tt.input,
+				strings.Join(tt.expected, "\n"), strings.Join(actual, "\n"))
+		}
+	}
+}
+
+// TestArities checks the arity table against the builtins themselves.
+func TestArities(t *testing.T) {
+	check := func(name string, builtin *object.Builtin) {
+		a, ok := arities[name]
+		if !ok {
+			t.Errorf("no arity for builtin %s", name)
+			return
+		}
+
+		invalid := []int{a.min - 1}
+		if a.max >= 0 {
+			invalid = append(invalid, a.max+1)
+		}
+
+		for _, n := range invalid {
+			if n < 0 {
+				continue
+			}
+
+			args := make([]object.Object, n)
+			for i := range args {
+				args[i] = object.NULL
+			}
+
+			err, ok := builtin.Fn(nil, args...).(*object.Error)
+			if !ok || !strings.HasPrefix(err.Message, "wrong number of arguments") {
+				t.Errorf("%s accepted %d arguments, want %s", name, n, a)
+			}
+		}
+	}
+
+	for _, def := range object.Builtins {
+		switch b := def.Builtin.(type) {
+		case *object.Builtin:
+			check(def.Name, b)
+		case *object.Hash:
+			for _, pair := range b.Pairs() {
+				check(def.Name+"."+pair.Key.Inspect(), pair.Value.(*object.Builtin))
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/resolve"
+	"monkey/stdlib"
+	"monkey/token"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// span is an identifier in the source and what it refers to: a definition,
+// or a builtin or standard library function by name.
+type span struct {
+	pos    token.Position
+	length int
+	def    *resolve.Definition
+	name   string
+}
+
+// analysis is the result of resolving the names of a program the way the
+// compiler does.
+type analysis struct {
+	defs  []*resolve.Definition
+	spans []span
+}
+
+// analyze resolves every identifier in program, with the globals of the
+// standard library defined.
+func analyze(program *ast.Program) *analysis {
+	var globals []string
+	for name := range library() {
+		globals = append(globals, name)
+	}
+	names := resolve.Program(program, globals...)
+
+	a := &analysis{defs: names.Defs}
+	for _, def := range names.Defs {
+		a.spans = append(a.spans, span{pos: def.Name.Pos(), length: identLength(def.Name), def: def})
+	}
+
+	for _, use := range names.Uses {
+		s := span{pos: use.Ident.Pos(), length: identLength(use.Ident)}
+		switch {
+		case use.Def != nil:
+			s.def = use.Def
+		case use.Symbol.Scope == compiler.BuiltinScope:
+			s.name = use.Ident.Value
+		case use.Symbol.Scope == compiler.GlobalScope:
+			if _, ok := library()[use.Ident.Value]; !ok {
+				continue
+			}
+			s.name = use.Ident.Value
+		default:
+			continue
+		}
+		a.spans = append(a.spans, s)
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if e, ok := node.(*ast.IndexExpression); ok {
+			a.member(e, names)
+		}
+		return true
+	})
+
+	return a
+}
+
+// at returns the span under the cursor, which may be just after its last
+// character.
+func (a *analysis) at(pos token.Position) (span, bool) {
+	for _, s := range a.spans {
+		if s.pos.Line == pos.Line && s.pos.Column <= pos.Column && pos.Column <= s.pos.Column+s.length {
+			return s, true
+		}
+	}
+
+	return span{}, false
+}
+
+// visible returns the definitions that can be referred to at pos: the
+// globals and the locals of the enclosing functions defined before it, the
+// parameters of the enclosing functions and of the enclosing catch blocks.
+func (a *analysis) visible(pos token.Position) []*resolve.Definition {
+	seen := make(map[string]bool)
+	var out []*resolve.Definition
+
+	for i := len(a.defs) - 1; i >= 0; i-- {
+		def := a.defs[i]
+		if seen[def.Name.Value] {
+			continue
+		}
+		if def.Func != nil && !contains(def.Func, pos) {
+			continue
+		}
+		if def.Block != nil && !(def.Block.Pos().Before(pos) && pos.Before(def.Block.Rbrace)) {
+			continue
+		}
+		if def.Kind != resolve.Param && !def.Name.Pos().Before(pos) {
+			continue
+		}
+
+		seen[def.Name.Value] = true
+		out = append(out, def)
+	}
+
+	return out
+}
+
+// contains reports whether pos lies inside the function or macro fn.
+func contains(fn ast.Node, pos token.Position) bool {
+	var body *ast.BlockStatement
+	switch fn := fn.(type) {
+	case *ast.FunctionLiteral:
+		body = fn.Body
+	case *ast.MacroLiteral:
+		body = fn.Body
+	}
+
+	return fn.Pos().Before(pos) && pos.Before(body.Rbrace)
+}
+
+func identLength(ident *ast.Identifier) int {
+	return utf8.RuneCountInString(ident.Value)
+}
+
+// member records `module.name` when module is a builtin module.
+func (a *analysis) member(e *ast.IndexExpression, names *resolve.Result) {
+	left, ok := e.Left.(*ast.Identifier)
+	if !ok || e.Token.Type != token.DOT {
+		return
+	}
+
+	use, ok := names.Lookup(left)
+	if !ok || use.Symbol.Scope != compiler.BuiltinScope {
+		return
+	}
+
+	index := e.Index.(*ast.StringLiteral)
+	a.spans = append(a.spans, span{
+		pos:    index.Pos(),
+		length: utf8.RuneCountInString(index.Value),
+		name:   left.Value + "." + index.Value,
+	})
+}
+
+// libraryFunction is a global defined by the standard library.
+type libraryFunction struct {
+	signature string
+	file      string
+}
+
+var (
+	libraryOnce  sync.Once
+	libraryNames map[string]libraryFunction
+)
+
+// library returns the globals of the standard library.
+func library() map[string]libraryFunction {
+	libraryOnce.Do(func() {
+		libraryNames = make(map[string]libraryFunction)
+
+		program, err := stdlib.Program()
+		if err != nil {
+			return
+		}
+
+		for _, stmt := range program.Statements {
+			let, ok := stmt.(*ast.LetStatement)
+			if !ok {
+				continue
+			}
+
+			f := libraryFunction{signature: let.Name.Value, file: let.Name.Pos().Filename}
+			if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+				f.signature = let.Name.Value + "(" + paramNames(fn.Parameters) + ")"
+			}
+			libraryNames[let.Name.Value] = f
+		}
+	})
+
+	return libraryNames
+}
+
+func paramNames(params []*ast.Identifier) string {
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.Value
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,50 @@
+package lsp
+
+// builtin documents a function in object.Builtins, with module functions
+// under `module.name`.
+type builtin struct {
+	signature string
+	doc       string
+}
+
+var builtins = map[string]builtin{
+	"len":         {"len(value)", "Returns the number of characters of a string or elements of an array."},
+	"exit":        {"exit(code?)", "Exits the process with the given status, 0 by default."},
+	"push":        {"push(array, value)", "Returns a new array with value appended."},
+	"last":        {"last(array)", "Returns the last element of an array, or null if it is empty."},
+	"rest":        {"rest(array)", "Returns a new array without the first element, or null if it is empty."},
+	"puts":        {"puts(values...)", "Prints the values separated by spaces, followed by a newline."},
+	"keys":        {"keys(hash)", "Returns the keys of a hash in insertion order."},
+	"first":       {"first(array)", "Returns the first element of an array, or null if it is empty."},
+	"toInt":       {"toInt(value)", "Converts a boolean to 1 or 0; null gives 0."},
+	"values":      {"values(hash)", "Returns the values of a hash in insertion order."},
+	"toBool":      {"toBool(value)", "Returns whether value is truthy."},
+	"split":       {"split(string, separator)", "Splits a string around every separator."},
+	"join":        {"join(strings, separator)", "Joins an array of strings with separator between them."},
+	"trim":        {"trim(string, cutset?)", "Removes leading and trailing characters in cutset, or whitespace."},
+	"trimLeft":    {"trimLeft(string, cutset?)", "Removes leading characters in cutset, or whitespace."},
+	"trimRight":   {"trimRight(string, cutset?)", "Removes trailing characters in cutset, or whitespace."},
+	"upper":       {"upper(string)", "Returns the string in upper case."},
+	"lower":       {"lower(string)", "Returns the string in lower case."},
+	"replace":     {"replace(string, old, new, n?)", "Replaces the first n occurrences of old with new, or all of them."},
+	"contains":    {"contains(container, value)", "Reports whether a string contains a substring or an array contains a value."},
+	"startsWith":  {"startsWith(string, prefix)", "Reports whether the string begins with prefix."},
+	"endsWith":    {"endsWith(string, suffix)", "Reports whether the string ends with suffix."},
+	"indexOf":     {"indexOf(container, value)", "Returns the index of a substring in a string or a value in an array, or -1."},
+	"substr":      {"substr(string, start, length?)", "Returns the characters from start, which may count from the end if negative."},
+	"repeat":      {"repeat(string, count)", "Returns count copies of the string."},
+	"chars":       {"chars(string)", "Splits a string into its characters."},
+	"padLeft":     {"padLeft(string, width, pad?)", "Pads the string on the left to width characters, with spaces by default."},
+	"padRight":    {"padRight(string, width, pad?)", "Pads the string on the right to width characters, with spaces by default."},
+	"deepEqual":   {"deepEqual(a, b)", "Reports whether two values are equal, comparing arrays and hashes element by element."},
+	"has":         {"has(hash, key)", "Reports whether the hash has key."},
+	"delete":      {"delete(hash, key)", "Returns a new hash without key."},
+	"merge":       {"merge(a, b)", "Returns a new hash with the pairs of a and b; b wins on duplicate keys."},
+	"entries":     {"entries(hash)", "Returns the [key, value] pairs of a hash."},
+	"fromEntries": {"fromEntries(pairs)", "Builds a hash from an array of [key, value] pairs."},
+	"json":        {"json", "Encoding and decoding of JSON."},
+	"json.encode": {"json.encode(value, indent?)", "Encodes a value as JSON, indented by a number of spaces or a string if given."},
+	"json.decode": {"json.decode(string)", "Decodes JSON into a value."},
+	"locals":      {"locals()", "Returns the variables of the current function, or the globals at the top level, as a hash sorted by name."},
+	"globals":     {"globals()", "Returns the global variables as a hash sorted by name."},
+}
@@ -0,0 +1,561 @@
+// Package lsp implements a Language Server Protocol server for monkey
+// source files. It provides diagnostics as documents change, go to
+// definition, hover, document symbols, completion and formatting, using
+// the lexer, the parser and the compiler's symbol tables.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/diag"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolve"
+	"monkey/token"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	docs map[string]*document
+}
+
+// document is an open file and the result of analyzing it.
+type document struct {
+	uri     string
+	text    string
+	lines   []string
+	program *ast.Program
+	errs    []*parser.Error
+
+	analysis *analysis
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*document),
+	}
+}
+
+// Serve handles messages until the client sends exit or the input ends.
+func (s *Server) Serve() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		if msg.ID == nil {
+			s.notification(&msg)
+			continue
+		}
+
+		result, err := s.request(&msg)
+		if err != nil {
+			code := invalidParams
+			var rpcErr *responseError
+			if errors.As(err, &rpcErr) {
+				code = rpcErr.Code
+			}
+			writeMessage(s.out, &errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: &responseError{Code: code, Message: err.Error()}})
+			continue
+		}
+
+		writeMessage(s.out, &response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+	}
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+func (s *Server) notification(msg *message) {
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) != 0 {
+			// The server asks for full document sync, so the last change
+			// holds the whole text.
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.docs, params.TextDocument.URI)
+			s.publish(params.TextDocument.URI, []diagnostic{})
+		}
+	}
+}
+
+func (s *Server) request(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":           1,
+				"definitionProvider":         true,
+				"hoverProvider":              true,
+				"documentSymbolProvider":     true,
+				"documentFormattingProvider": true,
+				"completionProvider":         map[string]interface{}{"triggerCharacters": []string{"."}},
+			},
+			"serverInfo": map[string]string{"name": "monkey"},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/definition":
+		return s.withPosition(msg.Params, s.definition)
+	case "textDocument/hover":
+		return s.withPosition(msg.Params, s.hover)
+	case "textDocument/completion":
+		return s.withPosition(msg.Params, s.completion)
+	case "textDocument/documentSymbol":
+		return s.withDocument(msg.Params, s.symbols)
+	case "textDocument/formatting":
+		return s.withDocument(msg.Params, s.formatting)
+	}
+
+	return nil, &responseError{Code: methodNotFound, Message: fmt.Sprintf("method not found: %s", msg.Method)}
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", uri)
+	}
+	return doc, nil
+}
+
+func (s *Server) withDocument(raw json.RawMessage, f func(*document) interface{}) (interface{}, error) {
+	var params documentParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return f(doc), nil
+}
+
+func (s *Server) withPosition(raw json.RawMessage, f func(*document, token.Position) interface{}) (interface{}, error) {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return f(doc, doc.fromLSP(params.Position)), nil
+}
+
+// update parses and analyzes a new version of a document and publishes
+// its diagnostics.
+func (s *Server) update(uri, text string) {
+	p := parser.New(lexer.NewFile(uri, text))
+	program := p.ParseProgram()
+
+	doc := &document{
+		uri:     uri,
+		text:    text,
+		lines:   strings.Split(text, "\n"),
+		program: program,
+		errs:    p.Errors(),
+	}
+	doc.analysis = analyze(program)
+	s.docs[uri] = doc
+
+	s.publish(uri, doc.diagnostics())
+}
+
+func (s *Server) publish(uri string, diagnostics []diagnostic) {
+	writeMessage(s.out, &notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics},
+	})
+}
+
+// diagnostics returns the parse errors of the document or, if it parses,
+// its compile error and lint warnings.
+func (doc *document) diagnostics() []diagnostic {
+	out := []diagnostic{}
+
+	if len(doc.errs) != 0 {
+		for _, err := range doc.errs {
+			out = append(out, doc.diagnostic(diag.FromError(err), severityError))
+		}
+		return out
+	}
+
+	if err := doc.compile(); err != nil {
+		out = append(out, doc.diagnostic(diag.FromError(err), severityError))
+	}
+
+	for _, issue := range lint.Check(doc.program) {
+		out = append(out, doc.diagnostic(issue.Diagnostic(), severityWarning))
+	}
+
+	return out
+}
+
+// compile compiles the document with the standard library's globals
+// defined, to find the errors only the compiler reports. Programs that
+// define macros are skipped, since expanding them runs code.
+func (doc *document) compile() error {
+	for _, stmt := range doc.program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			if _, ok := let.Value.(*ast.MacroLiteral); ok {
+				return nil
+			}
+		}
+	}
+
+	table := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		table.DefineBuiltin(i, v.Name)
+	}
+	for name := range library() {
+		table.Define(name)
+	}
+
+	return compiler.NewWithState(table, []object.Object{}).Compile(doc.program)
+}
+
+func (doc *document) diagnostic(d diag.Diagnostic, severity int) diagnostic {
+	length := d.Length
+	if length == 0 {
+		length = doc.wordLength(d.Pos)
+	}
+
+	end := d.Pos
+	end.Column += length
+
+	return diagnostic{
+		Range:    lspRange{Start: doc.toLSP(d.Pos), End: doc.toLSP(end)},
+		Severity: severity,
+		Code:     d.Code,
+		Source:   "monkey",
+		Message:  d.Message,
+	}
+}
+
+// wordLength returns the length of the word at pos, or 1.
+func (doc *document) wordLength(pos token.Position) int {
+	if pos.Line < 1 || pos.Line > len(doc.lines) {
+		return 1
+	}
+
+	runes := []rune(doc.lines[pos.Line-1])
+	n := 0
+	for i := pos.Column - 1; i >= 0 && i < len(runes) && isIdentRune(runes[i]); i++ {
+		n++
+	}
+	if n == 0 {
+		return 1
+	}
+
+	return n
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// toLSP converts a position to the protocol's zero-based line and UTF-16
+// character offset.
+func (doc *document) toLSP(pos token.Position) position {
+	if pos.Line < 1 {
+		return position{}
+	}
+	if pos.Line > len(doc.lines) {
+		return position{Line: pos.Line - 1}
+	}
+
+	runes := []rune(doc.lines[pos.Line-1])
+	n := pos.Column - 1
+	if n > len(runes) {
+		n = len(runes)
+	}
+	if n < 0 {
+		n = 0
+	}
+
+	return position{Line: pos.Line - 1, Character: len(utf16.Encode(runes[:n]))}
+}
+
+func (doc *document) fromLSP(p position) token.Position {
+	pos := token.Position{Filename: doc.uri, Line: p.Line + 1, Column: p.Character + 1}
+	if p.Line < 0 || p.Line >= len(doc.lines) {
+		return pos
+	}
+
+	units := 0
+	pos.Column = 1
+	for _, r := range doc.lines[p.Line] {
+		if units >= p.Character {
+			break
+		}
+		units += utf16.RuneLen(r)
+		pos.Column++
+	}
+
+	return pos
+}
+
+func (doc *document) spanRange(pos token.Position, length int) lspRange {
+	end := pos
+	end.Column += length
+	return lspRange{Start: doc.toLSP(pos), End: doc.toLSP(end)}
+}
+
+func (s *Server) definition(doc *document, pos token.Position) interface{} {
+	span, ok := doc.analysis.at(pos)
+	if !ok || span.def == nil {
+		return nil
+	}
+
+	name := span.def.Name
+	return location{URI: doc.uri, Range: doc.spanRange(name.Pos(), identLength(name))}
+}
+
+func (s *Server) hover(doc *document, pos token.Position) interface{} {
+	span, ok := doc.analysis.at(pos)
+	if !ok {
+		return nil
+	}
+
+	var text string
+	switch {
+	case span.def != nil:
+		text = codeBlock(describe(span.def))
+	case builtins[span.name].signature != "":
+		b := builtins[span.name]
+		text = codeBlock(b.signature) + "\n" + b.doc
+	default:
+		f := library()[span.name]
+		text = codeBlock(f.signature) + "\nDefined in the standard library, " + f.file + "."
+	}
+
+	r := doc.spanRange(span.pos, span.length)
+	return hover{Contents: markupContent{Kind: "markdown", Value: text}, Range: &r}
+}
+
+func codeBlock(code string) string {
+	return "```monkey\n" + code + "\n```"
+}
+
+// describe returns the declaration of a definition as source code.
+func describe(def *resolve.Definition) string {
+	switch def.Kind {
+	case resolve.Param:
+		return "(parameter) " + def.Name.Value
+	case resolve.Catch:
+		return "(catch) " + def.Name.Value
+	}
+
+	switch value := def.Value.(type) {
+	case *ast.FunctionLiteral:
+		return "let " + def.Name.Value + " = fn(" + paramNames(value.Parameters) + ")"
+	case *ast.MacroLiteral:
+		return "let " + def.Name.Value + " = macro(" + paramNames(value.Parameters) + ")"
+	}
+
+	return "let " + def.Name.Value
+}
+
+func (s *Server) symbols(doc *document) interface{} {
+	return doc.symbols(doc.program.Statements)
+}
+
+// symbols returns the let statements among stmts, with the let statements
+// of the functions they bind as children.
+func (doc *document) symbols(stmts []ast.Statement) []documentSymbol {
+	out := []documentSymbol{}
+
+	for _, stmt := range stmts {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+
+		name := doc.spanRange(let.Name.Pos(), identLength(let.Name))
+		sym := documentSymbol{Name: let.Name.Value, Kind: symbolVariable, Range: name, SelectionRange: name}
+
+		var body *ast.BlockStatement
+		switch value := let.Value.(type) {
+		case *ast.FunctionLiteral:
+			sym.Detail = "fn(" + paramNames(value.Parameters) + ")"
+			body = value.Body
+		case *ast.MacroLiteral:
+			sym.Detail = "macro(" + paramNames(value.Parameters) + ")"
+			body = value.Body
+		}
+
+		if body != nil {
+			sym.Kind = symbolFunction
+			end := body.Rbrace
+			end.Column++
+			sym.Range = lspRange{Start: doc.toLSP(let.Pos()), End: doc.toLSP(end)}
+			sym.Children = doc.symbols(body.Statements)
+		}
+
+		out = append(out, sym)
+	}
+
+	return out
+}
+
+func (s *Server) completion(doc *document, pos token.Position) interface{} {
+	if module, ok := doc.moduleBefore(pos); ok {
+		return moduleCompletions(module)
+	}
+
+	items := []completionItem{}
+
+	for _, def := range doc.analysis.visible(pos) {
+		kind := completionVariable
+		switch def.Value.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			kind = completionFunction
+		}
+		items = append(items, completionItem{Label: def.Name.Value, Kind: kind, Detail: describe(def)})
+	}
+
+	for _, v := range object.Builtins {
+		kind := completionFunction
+		if _, ok := v.Builtin.(*object.Hash); ok {
+			kind = completionModule
+		}
+		items = append(items, completionItem{Label: v.Name, Kind: kind, Detail: builtins[v.Name].signature})
+	}
+
+	names := make([]string, 0, len(library()))
+	for name := range library() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, completionItem{Label: name, Kind: completionFunction, Detail: library()[name].signature})
+	}
+
+	for _, keyword := range keywords {
+		items = append(items, completionItem{Label: keyword, Kind: completionKeyword})
+	}
+
+	return items
+}
+
+var keywords = []string{"let", "fn", "if", "else", "return", "true", "false", "try", "catch", "finally", "throw", "macro"}
+
+// moduleBefore reports whether the cursor follows `module.`, possibly with
+// part of a member name typed, where module is a builtin module.
+func (doc *document) moduleBefore(pos token.Position) (string, bool) {
+	if pos.Line < 1 || pos.Line > len(doc.lines) {
+		return "", false
+	}
+
+	runes := []rune(doc.lines[pos.Line-1])
+	i := pos.Column - 1
+	if i > len(runes) {
+		return "", false
+	}
+
+	for i > 0 && isIdentRune(runes[i-1]) {
+		i--
+	}
+	if i == 0 || runes[i-1] != '.' {
+		return "", false
+	}
+
+	end := i - 1
+	start := end
+	for start > 0 && isIdentRune(runes[start-1]) {
+		start--
+	}
+
+	name := string(runes[start:end])
+	for _, v := range object.Builtins {
+		if _, ok := v.Builtin.(*object.Hash); ok && v.Name == name {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+func moduleCompletions(module string) []completionItem {
+	items := []completionItem{}
+
+	for _, v := range object.Builtins {
+		hash, ok := v.Builtin.(*object.Hash)
+		if !ok || v.Name != module {
+			continue
+		}
+
+		for _, pair := range hash.Pairs() {
+			member := pair.Key.Inspect()
+			items = append(items, completionItem{
+				Label:  member,
+				Kind:   completionFunction,
+				Detail: builtins[module+"."+member].signature,
+			})
+		}
+	}
+
+	return items
+}
+
+// formatting replaces the whole document with its formatted text. A
+// document that does not parse is left alone.
+func (s *Server) formatting(doc *document) interface{} {
+	formatted, err := format.Source(doc.text)
+	if err != nil {
+		return nil
+	}
+	if formatted == doc.text {
+		return []textEdit{}
+	}
+
+	last := len(doc.lines) - 1
+	end := position{Line: last, Character: len(utf16.Encode([]rune(doc.lines[last])))}
+
+	return []textEdit{{Range: lspRange{End: end}, NewText: formatted}}
+}
@@ -0,0 +1,326 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"monkey/object"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///src/main.mk"
+
+const source = `let add = fn(a, b) {
+	let sum = a + b;
+	sum
+};
+let total = add(1, 2);
+puts(len(total));
+json.encode(map([total], fn(x) { x }))
+`
+
+// session sends the messages to a server, after opening the document with
+// text, and returns everything the server sends back.
+func session(t *testing.T, text string, msgs ...map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+
+	var in bytes.Buffer
+	send := func(msg map[string]interface{}) {
+		msg["jsonrpc"] = "2.0"
+		if err := writeMessage(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(map[string]interface{}{"id": 0, "method": "initialize", "params": map[string]interface{}{}})
+	send(map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "monkey", "version": 1, "text": text},
+	}})
+	for _, msg := range msgs {
+		send(msg)
+	}
+	send(map[string]interface{}{"method": "exit"})
+
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Serve(); err != nil {
+		t.Fatalf("serve failed: %s", err)
+	}
+
+	var replies []map[string]interface{}
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readMessage(r)
+		if err != nil {
+			break
+		}
+
+		var msg map[string]interface{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, msg)
+	}
+
+	return replies
+}
+
+// call sends a request at a position in the document and returns its
+// result.
+func call(t *testing.T, method string, line, character int) interface{} {
+	t.Helper()
+
+	replies := session(t, source, map[string]interface{}{"id": 1, "method": method, "params": map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}})
+
+	return result(t, replies, 1)
+}
+
+func result(t *testing.T, replies []map[string]interface{}, id float64) interface{} {
+	t.Helper()
+
+	for _, reply := range replies {
+		if reply["id"] == id {
+			if reply["error"] != nil {
+				t.Fatalf("request failed: %v", reply["error"])
+			}
+			return reply["result"]
+		}
+	}
+
+	t.Fatalf("no reply to request %v", id)
+	return nil
+}
+
+func diagnostics(t *testing.T, text string) []map[string]interface{} {
+	t.Helper()
+
+	for _, reply := range session(t, text) {
+		if reply["method"] == "textDocument/publishDiagnostics" {
+			var out []map[string]interface{}
+			for _, d := range reply["params"].(map[string]interface{})["diagnostics"].([]interface{}) {
+				out = append(out, d.(map[string]interface{}))
+			}
+			return out
+		}
+	}
+
+	t.Fatalf("no diagnostics published")
+	return nil
+}
+
+func rangeOf(v interface{}) [4]float64 {
+	r := v.(map[string]interface{})
+	start := r["start"].(map[string]interface{})
+	end := r["end"].(map[string]interface{})
+	return [4]float64{start["line"].(float64), start["character"].(float64), end["line"].(float64), end["character"].(float64)}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		severity float64
+		message  string
+		rng      [4]float64
+	}{
+		{"let x = ;", severityError, "no prefix parse function for ; found", [4]float64{0, 8, 0, 9}},
+		{"let x = 1;\nputs(y);", severityError, "undefined variable y", [4]float64{1, 5, 1, 6}},
+		{"let f = fn() {\n\tlet unused = 1;\n\t2\n};", severityWarning, "`unused` is declared but never used", [4]float64{1, 5, 1, 11}},
+		{"let s = \"é\"; len(s, s);", severityWarning, "`len` takes 1 argument, got 2", [4]float64{0, 13, 0, 16}},
+	}
+
+	for _, tt := range tests {
+		diags := diagnostics(t, tt.input)
+		if len(diags) != 1 {
+			t.Errorf("wrong number of diagnostics for %q: %v", tt.input, diags)
+			continue
+		}
+
+		d := diags[0]
+		if d["severity"] != tt.severity || d["message"] != tt.message || rangeOf(d["range"]) != tt.rng {
+			t.Errorf("wrong diagnostic for %q: %v", tt.input, d)
+		}
+	}
+
+	if diags := diagnostics(t, source); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        [4]float64
+	}{
+		{2, 2, [4]float64{1, 5, 1, 8}},    // sum
+		{1, 11, [4]float64{0, 13, 0, 14}}, // a
+		{4, 13, [4]float64{0, 4, 0, 7}},   // add
+		{5, 11, [4]float64{4, 4, 4, 9}},   // total, at the end of the word
+		{6, 33, [4]float64{6, 28, 6, 29}}, // x
+	}
+
+	for _, tt := range tests {
+		loc, ok := call(t, "textDocument/definition", tt.line, tt.character).(map[string]interface{})
+		if !ok {
+			t.Errorf("no definition at %d:%d", tt.line, tt.character)
+			continue
+		}
+
+		if loc["uri"] != uri || rangeOf(loc["range"]) != tt.expected {
+			t.Errorf("wrong definition at %d:%d: %v", tt.line, tt.character, loc)
+		}
+	}
+
+	for _, pos := range [][2]int{{5, 1}, {6, 13}, {4, 17}} {
+		if loc := call(t, "textDocument/definition", pos[0], pos[1]); loc != nil {
+			t.Errorf("unexpected definition at %v: %v", pos, loc)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{5, 6, "len(value)"},
+		{6, 7, "json.encode(value, indent?)"},
+		{6, 13, "map(arr, f)"},
+		{4, 13, "let add = fn(a, b)"},
+		{1, 11, "(parameter) a"},
+		{2, 1, "let sum"},
+	}
+
+	for _, tt := range tests {
+		h, ok := call(t, "textDocument/hover", tt.line, tt.character).(map[string]interface{})
+		if !ok {
+			t.Errorf("no hover at %d:%d", tt.line, tt.character)
+			continue
+		}
+
+		value := h["contents"].(map[string]interface{})["value"].(string)
+		if !strings.Contains(value, "```monkey\n"+tt.expected+"\n```") {
+			t.Errorf("wrong hover at %d:%d: %q", tt.line, tt.character, value)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	replies := session(t, source, map[string]interface{}{"id": 1, "method": "textDocument/documentSymbol", "params": map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+	}})
+	symbols := result(t, replies, 1).([]interface{})
+
+	if len(symbols) != 2 {
+		t.Fatalf("wrong number of symbols: %v", symbols)
+	}
+
+	add := symbols[0].(map[string]interface{})
+	if add["name"] != "add" || add["kind"] != float64(symbolFunction) || add["detail"] != "fn(a, b)" {
+		t.Errorf("wrong symbol: %v", add)
+	}
+	if rangeOf(add["range"]) != [4]float64{0, 0, 3, 1} || rangeOf(add["selectionRange"]) != [4]float64{0, 4, 0, 7} {
+		t.Errorf("wrong ranges: %v", add)
+	}
+
+	children := add["children"].([]interface{})
+	if len(children) != 1 || children[0].(map[string]interface{})["name"] != "sum" {
+		t.Errorf("wrong children: %v", children)
+	}
+
+	total := symbols[1].(map[string]interface{})
+	if total["name"] != "total" || total["kind"] != float64(symbolVariable) {
+		t.Errorf("wrong symbol: %v", total)
+	}
+}
+
+func labels(items interface{}) map[string]bool {
+	out := map[string]bool{}
+	for _, item := range items.([]interface{}) {
+		out[item.(map[string]interface{})["label"].(string)] = true
+	}
+	return out
+}
+
+func TestCompletion(t *testing.T) {
+	inside := labels(call(t, "textDocument/completion", 2, 1))
+	for _, name := range []string{"a", "b", "sum", "add", "len", "json", "map", "let", "fn"} {
+		if !inside[name] {
+			t.Errorf("%s not offered inside the function", name)
+		}
+	}
+	for _, name := range []string{"total", "x"} {
+		if inside[name] {
+			t.Errorf("%s offered inside the function", name)
+		}
+	}
+
+	outside := labels(call(t, "textDocument/completion", 6, 0))
+	if !outside["total"] || outside["a"] || !outside["map"] {
+		t.Errorf("wrong completions after the function: %v", outside)
+	}
+
+	members := labels(call(t, "textDocument/completion", 6, 6))
+	if len(members) != 2 || !members["encode"] || !members["decode"] {
+		t.Errorf("wrong completions after json.: %v", members)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	format := func(text string) interface{} {
+		replies := session(t, text, map[string]interface{}{"id": 1, "method": "textDocument/formatting", "params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+			"options":      map[string]interface{}{"tabSize": 4, "insertSpaces": false},
+		}})
+		return result(t, replies, 1)
+	}
+
+	edits := format("let x=[1,2]\nputs( x )").([]interface{})
+	if len(edits) != 1 {
+		t.Fatalf("wrong number of edits: %v", edits)
+	}
+
+	edit := edits[0].(map[string]interface{})
+	if edit["newText"] != "let x = [1, 2];\nputs(x)\n" || rangeOf(edit["range"]) != [4]float64{0, 0, 1, 9} {
+		t.Errorf("wrong edit: %v", edit)
+	}
+
+	if edits := format(source).([]interface{}); len(edits) != 0 {
+		t.Errorf("formatted source was changed: %v", edits)
+	}
+
+	if edits := format("let x = ;"); edits != nil {
+		t.Errorf("source with errors was changed: %v", edits)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	replies := session(t, source, map[string]interface{}{"id": 1, "method": "textDocument/rename", "params": map[string]interface{}{}})
+	for _, reply := range replies {
+		if reply["id"] == float64(1) {
+			if err, ok := reply["error"].(map[string]interface{}); !ok || err["code"] != float64(methodNotFound) {
+				t.Errorf("wrong reply: %v", reply)
+			}
+			return
+		}
+	}
+	t.Errorf("no reply")
+}
+
+func TestBuiltins(t *testing.T) {
+	for _, v := range object.Builtins {
+		if _, ok := builtins[v.Name]; !ok {
+			t.Errorf("no documentation for %s", v.Name)
+		}
+
+		if hash, ok := v.Builtin.(*object.Hash); ok {
+			for _, pair := range hash.Pairs() {
+				if _, ok := builtins[v.Name+"."+pair.Key.Inspect()]; !ok {
+					t.Errorf("no documentation for %s.%s", v.Name, pair.Key.Inspect())
+				}
+			}
+		}
+	}
+}
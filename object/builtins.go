@@ -2,12 +2,15 @@ package object
 
 import (
 	"fmt"
-	"os"
+	"sort"
+	"unicode/utf8"
 )
 
+// Builtins are mostly *Builtin functions, but an entry may also be a module
+// hash of functions such as `json`.
 var Builtins = []struct {
 	Name    string
-	Builtin *Builtin
+	Builtin Object
 }{
 	{"len", &Builtin{Fn: bltnLen}},
 	{"exit", &Builtin{Fn: bltnExit}},
@@ -20,13 +23,43 @@ var Builtins = []struct {
 	{"toInt", &Builtin{Fn: bltnToInt}},
 	{"values", &Builtin{Fn: bltnValues}},
 	{"toBool", &Builtin{Fn: bltnToBool}},
+	{"split", &Builtin{Fn: bltnSplit}},
+	{"join", &Builtin{Fn: bltnJoin}},
+	{"trim", &Builtin{Fn: bltnTrim}},
+	{"trimLeft", &Builtin{Fn: bltnTrimLeft}},
+	{"trimRight", &Builtin{Fn: bltnTrimRight}},
+	{"upper", &Builtin{Fn: bltnUpper}},
+	{"lower", &Builtin{Fn: bltnLower}},
+	{"replace", &Builtin{Fn: bltnReplace}},
+	{"contains", &Builtin{Fn: bltnContains}},
+	{"startsWith", &Builtin{Fn: bltnStartsWith}},
+	{"endsWith", &Builtin{Fn: bltnEndsWith}},
+	{"indexOf", &Builtin{Fn: bltnIndexOf}},
+	{"substr", &Builtin{Fn: bltnSubstr}},
+	{"repeat", &Builtin{Fn: bltnRepeat}},
+	{"chars", &Builtin{Fn: bltnChars}},
+	{"padLeft", &Builtin{Fn: bltnPadLeft}},
+	{"padRight", &Builtin{Fn: bltnPadRight}},
+	{"deepEqual", &Builtin{Fn: bltnDeepEqual}},
+	{"has", &Builtin{Fn: bltnHas}},
+	{"delete", &Builtin{Fn: bltnDelete}},
+	{"merge", &Builtin{Fn: bltnMerge}},
+	{"entries", &Builtin{Fn: bltnEntries}},
+	{"fromEntries", &Builtin{Fn: bltnFromEntries}},
+	{"json", jsonModule},
+	{"locals", &Builtin{Fn: bltnLocals}},
+	{"globals", &Builtin{Fn: bltnGlobals}},
 }
 
 func newError(format string, a ...interface{}) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
 
-func GetBuiltinByName(name string) *Builtin {
+func newArgumentError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: ARGUMENT_ERROR}
+}
+
+func GetBuiltinByName(name string) Object {
 	for _, def := range Builtins {
 		if def.Name == name {
 			return def.Builtin
@@ -43,7 +76,7 @@ func bltnLen(env *Environment, args ...Object) Object {
 	var length int64
 	switch arg := args[0].(type) {
 	case *String:
-		length = int64(len(arg.Value))
+		length = int64(utf8.RuneCountInString(arg.Value))
 	case *Array:
 		length = int64(len(arg.Elements))
 	default:
@@ -86,7 +119,7 @@ func bltnFirst(env *Environment, args ...Object) Object {
 		return arr.Elements[0]
 	}
 
-	return nil
+	return NULL
 }
 
 func bltnLast(env *Environment, args ...Object) Object {
@@ -105,7 +138,7 @@ func bltnLast(env *Environment, args ...Object) Object {
 		return arr.Elements[length-1]
 	}
 
-	return nil
+	return NULL
 }
 
 func bltnRest(env *Environment, args ...Object) Object {
@@ -126,15 +159,16 @@ func bltnRest(env *Environment, args ...Object) Object {
 		return &Array{Elements: newElements}
 	}
 
-	return nil
+	return NULL
 }
 
 func bltnPuts(env *Environment, args ...Object) Object {
+	out := env.Session().output()
 	for _, arg := range args {
-		fmt.Printf("%s ", arg.Inspect())
+		fmt.Fprintf(out, "%s ", arg.Inspect())
 	}
-	fmt.Print("\n")
-	return nil
+	fmt.Fprint(out, "\n")
+	return NULL
 }
 
 func bltnKeys(env *Environment, args ...Object) Object {
@@ -148,8 +182,8 @@ func bltnKeys(env *Environment, args ...Object) Object {
 	hash := args[0].(*Hash)
 
 	keys := []Object{}
-	for _, pair := range hash.Pairs {
-		keys = append(keys, &String{Value: pair.Key.Inspect()})
+	for _, pair := range hash.Pairs() {
+		keys = append(keys, pair.Key)
 	}
 
 	return &Array{Elements: keys}
@@ -166,13 +200,21 @@ func bltnValues(env *Environment, args ...Object) Object {
 	hash := args[0].(*Hash)
 
 	values := []Object{}
-	for _, pair := range hash.Pairs {
-		values = append(values, &String{Value: pair.Value.Inspect()})
+	for _, pair := range hash.Pairs() {
+		values = append(values, pair.Value)
 	}
 
 	return &Array{Elements: values}
 }
 
+func bltnDeepEqual(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	return nativeBool(Equal(args[0], args[1]))
+}
+
 func bltnExit(env *Environment, args ...Object) Object {
 	if len(args) != 0 && len(args) != 1 {
 		return newError("wrong number of arguments. got = %d, want = 0 or 1", len(args))
@@ -188,10 +230,10 @@ func bltnExit(env *Environment, args ...Object) Object {
 			return newError("invalid exit code. should be within %d to %d", 0, 125)
 		}
 	} else {
-		fmt.Println("exit status 0")
+		fmt.Fprintln(env.Session().output(), "exit status 0")
 	}
 
-	os.Exit(exitCode)
+	env.Session().exit(exitCode)
 	return nil
 }
 
@@ -248,7 +290,7 @@ func bltnToBool(env *Environment, args ...Object) Object {
 			out = true
 		}
 	case *Hash:
-		if len(arg.Pairs) == 0 {
+		if arg.Len() == 0 {
 			out = false
 		} else {
 			out = true
@@ -265,3 +307,46 @@ func bltnToBool(env *Environment, args ...Object) Object {
 		return FALSE
 	}
 }
+
+// bltnGlobals returns the variables of the outermost environment, sorted
+// by name.
+func bltnGlobals(env *Environment, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got = %d, want = 0", len(args))
+	}
+
+	for env.Outer() != nil {
+		env = env.Outer()
+	}
+
+	return storeToHash(env.Store())
+}
+
+// bltnLocals returns the variables of the innermost environment, sorted
+// by name. Catch parameters are left out, as the compiler keeps them in
+// slots of their own.
+func bltnLocals(env *Environment, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got = %d, want = 0", len(args))
+	}
+
+	for env.param != "" {
+		env = env.outer
+	}
+
+	return storeToHash(env.Store())
+}
+
+func storeToHash(store map[string]Object) *Hash {
+	names := make([]string, 0, len(store))
+	for name := range store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := NewHash()
+	for _, name := range names {
+		hash.Set(&String{Value: name}, store[name])
+	}
+	return hash
+}
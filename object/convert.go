@@ -0,0 +1,582 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Struct fields are converted under the name given by their `monkey` tag,
+// falling back to the field name. A tag of "-" skips the field, and the
+// "omitempty" option skips it when it holds its zero value:
+//
+//	type User struct {
+//		Name  string `monkey:"name"`
+//		Email string `monkey:"email,omitempty"`
+//		token string
+//	}
+const structTag = "monkey"
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// FromGo converts a Go value into an Object. Booleans, integers, floats
+// with integral values, strings, slices, arrays, maps, structs and pointers
+// to them are converted recursively, nil becomes NULL, Objects are returned
+// unchanged and functions are wrapped as builtins with FromGoFunc. Values
+// that contain themselves are an error.
+func FromGo(v any) (Object, error) {
+	if v == nil {
+		return NULL, nil
+	}
+
+	if obj, ok := v.(Object); ok {
+		return obj, nil
+	}
+
+	return fromValue(reflect.ValueOf(v), visiting{})
+}
+
+// visiting holds the pointers, maps and slices whose conversion is in
+// progress, so that a value that refers back to one of them is reported
+// instead of recursing forever.
+type visiting map[visit]bool
+
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// enter marks v as in progress, and returns the function that unmarks it.
+func (seen visiting) enter(v reflect.Value) (func(), error) {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+
+	if seen[key] {
+		return nil, fmt.Errorf("cycle through %s", v.Type())
+	}
+
+	seen[key] = true
+	return func() { delete(seen, key) }, nil
+}
+
+func fromValue(v reflect.Value, seen visiting) (Object, error) {
+	if v.Type().Implements(objectType) {
+		if v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return NULL, nil
+			}
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return nativeBool(v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("integer %d overflows INTEGER", v.Uint())
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return nil, fmt.Errorf("float %v is not an INTEGER", f)
+		}
+		return &Integer{Value: int64(f)}, nil
+
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromValue(v.Elem(), seen)
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return NULL, nil
+		}
+
+		leave, err := seen.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+
+		return fromValue(v.Elem(), seen)
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return NULL, nil
+			}
+
+			leave, err := seen.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			elem, err := fromValue(v.Index(i), seen)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			elements[i] = elem
+		}
+		return &Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+
+		leave, err := seen.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+
+		return fromMap(v, seen)
+
+	case reflect.Struct:
+		return fromStruct(v, seen)
+
+	case reflect.Func:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return FromGoFunc(v.Interface())
+	}
+
+	return nil, fmt.Errorf("unsupported Go type %s", v.Type())
+}
+
+// fromMap converts a map, sorting its keys so the resulting hash has a
+// deterministic order.
+func fromMap(v reflect.Value, seen visiting) (Object, error) {
+	type entry struct {
+		key   Hashable
+		value Object
+	}
+
+	entries := []entry{}
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := fromValue(iter.Key(), seen)
+		if err != nil {
+			return nil, err
+		}
+
+		hashKey, ok := AsHashable(key)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		value, err := fromValue(iter.Value(), seen)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key.Inspect(), err)
+		}
+
+		entries = append(entries, entry{hashKey, value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return lessKey(entries[i].key, entries[j].key)
+	})
+
+	hash := NewHash()
+	for _, e := range entries {
+		hash.Set(e.key, e.value)
+	}
+
+	return hash, nil
+}
+
+func lessKey(a, b Hashable) bool {
+	if a, ok := a.(*Integer); ok {
+		if b, ok := b.(*Integer); ok {
+			return a.Value < b.Value
+		}
+	}
+
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	return a.Inspect() < b.Inspect()
+}
+
+type structField struct {
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+func structFields(t reflect.Type) []structField {
+	fields := []structField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get(structTag), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fields = append(fields, structField{index: i, name: name, omitEmpty: opts == "omitempty"})
+	}
+
+	return fields
+}
+
+func fromStruct(v reflect.Value, seen visiting) (Object, error) {
+	hash := NewHash()
+
+	for _, f := range structFields(v.Type()) {
+		field := v.Field(f.index)
+		if f.omitEmpty && field.IsZero() {
+			continue
+		}
+
+		value, err := fromValue(field, seen)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+
+		hash.Set(&String{Value: f.name}, value)
+	}
+
+	return hash, nil
+}
+
+// ToGo converts an Object into a plain Go value: int64, string, bool, nil,
+// []any for arrays, and map[string]any for hashes whose keys are all
+// strings or map[any]any otherwise.
+func ToGo(obj Object) (any, error) {
+	switch obj := obj.(type) {
+	case nil, *Null:
+		return nil, nil
+	case *Integer:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Array:
+		out := make([]any, len(obj.Elements))
+		for i, elem := range obj.Elements {
+			v, err := ToGo(elem)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			out[i] = v
+		}
+		return out, nil
+	case *Hash:
+		return hashToGo(obj)
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Go value", obj.Type())
+	}
+}
+
+func hashToGo(hash *Hash) (any, error) {
+	stringKeys := true
+	for _, pair := range hash.Pairs() {
+		if _, ok := pair.Key.(*String); !ok {
+			stringKeys = false
+			break
+		}
+	}
+
+	if stringKeys {
+		out := make(map[string]any, hash.Len())
+		for _, pair := range hash.Pairs() {
+			v, err := ToGo(pair.Value)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			out[pair.Key.(*String).Value] = v
+		}
+		return out, nil
+	}
+
+	out := make(map[any]any, hash.Len())
+	for _, pair := range hash.Pairs() {
+		if _, ok := pair.Key.(*Array); ok {
+			return nil, fmt.Errorf("cannot convert hash key %s to a Go map key", pair.Key.Type())
+		}
+
+		k, err := ToGo(pair.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := ToGo(pair.Value)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// Unmarshal stores obj into the Go value target points to, converting it
+// to the target's type. Struct fields are matched by their `monkey` tag.
+func Unmarshal(obj Object, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("Unmarshal target must be a non-nil pointer, got %T", target)
+	}
+
+	converted, err := toValue(obj, v.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	v.Elem().Set(converted)
+	return nil
+}
+
+func toValue(obj Object, t reflect.Type) (reflect.Value, error) {
+	if obj == nil {
+		return reflect.Value{}, fmt.Errorf("cannot convert nil to %s", t)
+	}
+
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		v, err := ToGo(obj)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if v == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(v), nil
+	}
+
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+	}
+
+	if obj == NULL {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			return reflect.Zero(t), nil
+		}
+		return mismatch()
+	}
+
+	out := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return mismatch()
+		}
+		out.SetBool(b.Value)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch()
+		}
+		if out.OverflowInt(i.Value) {
+			return reflect.Value{}, fmt.Errorf("integer %d overflows %s", i.Value, t)
+		}
+		out.SetInt(i.Value)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch()
+		}
+		if i.Value < 0 || out.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("integer %d overflows %s", i.Value, t)
+		}
+		out.SetUint(uint64(i.Value))
+
+	case reflect.Float32, reflect.Float64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch()
+		}
+		out.SetFloat(float64(i.Value))
+
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch()
+		}
+		out.SetString(s.Value)
+
+	case reflect.Pointer:
+		elem, err := toValue(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out = reflect.New(t.Elem())
+		out.Elem().Set(elem)
+
+	case reflect.Slice, reflect.Array:
+		arr, ok := obj.(*Array)
+		if !ok {
+			return mismatch()
+		}
+
+		if t.Kind() == reflect.Slice {
+			out = reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		} else if t.Len() != len(arr.Elements) {
+			return reflect.Value{}, fmt.Errorf("cannot convert array of length %d to %s", len(arr.Elements), t)
+		}
+
+		for i, elem := range arr.Elements {
+			v, err := toValue(elem, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("index %d: %w", i, err)
+			}
+			out.Index(i).Set(v)
+		}
+
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch()
+		}
+
+		out = reflect.MakeMapWithSize(t, hash.Len())
+		for _, pair := range hash.Pairs() {
+			k, err := toValue(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			v, err := toValue(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			out.SetMapIndex(k, v)
+		}
+
+	case reflect.Struct:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch()
+		}
+
+		for _, f := range structFields(t) {
+			pair, ok := hash.Get(&String{Value: f.name})
+			if !ok {
+				continue
+			}
+
+			v, err := toValue(pair.Value, t.Field(f.index).Type)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			out.Field(f.index).Set(v)
+		}
+
+	default:
+		return mismatch()
+	}
+
+	return out, nil
+}
+
+// FromGoFunc wraps the Go function fn as a builtin. Arguments are converted
+// to the parameter types of fn as by Unmarshal. The results are converted
+// with FromGo: no result becomes NULL, a trailing non-nil error becomes an
+// *Error, and more than one other result becomes an array.
+func FromGoFunc(fn any) (*Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("FromGoFunc needs a function, got %T", fn)
+	}
+
+	t := v.Type()
+
+	builtin := func(env *Environment, args ...Object) Object {
+		numIn := t.NumIn()
+		if t.IsVariadic() {
+			if len(args) < numIn-1 {
+				return newError("wrong number of arguments. got = %d, want at least %d", len(args), numIn-1)
+			}
+		} else if len(args) != numIn {
+			return newError("wrong number of arguments. got = %d, want = %d", len(args), numIn)
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && i >= numIn-1 {
+				paramType = t.In(numIn - 1).Elem()
+			} else {
+				paramType = t.In(i)
+			}
+
+			converted, err := toValue(arg, paramType)
+			if err != nil {
+				return newError("argument %d: %s", i+1, err)
+			}
+			in[i] = converted
+		}
+
+		return fromResults(v.Call(in))
+	}
+
+	return &Builtin{Fn: builtin}, nil
+}
+
+func fromResults(results []reflect.Value) Object {
+	if n := len(results); n > 0 && results[n-1].Type() == errorType {
+		if err := results[n-1].Interface(); err != nil {
+			return newError("%s", err)
+		}
+		results = results[:n-1]
+	}
+
+	objects := make([]Object, len(results))
+	for i, result := range results {
+		obj, err := fromValue(result, visiting{})
+		if err != nil {
+			return newError("result %d: %s", i+1, err)
+		}
+		objects[i] = obj
+	}
+
+	switch len(objects) {
+	case 0:
+		return NULL
+	case 1:
+		return objects[0]
+	default:
+		return &Array{Elements: objects}
+	}
+}
@@ -0,0 +1,233 @@
+package object
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type convertUser struct {
+	Name    string   `monkey:"name"`
+	Age     int      `monkey:"age"`
+	Email   string   `monkey:"email,omitempty"`
+	Tags    []string `monkey:"tags"`
+	Secret  string   `monkey:"-"`
+	private int
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.0, "2"},
+		{float32(-3), "-3"},
+		{"hi", "hi"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{map[int]string{10: "x", 2: "y"}, "{2: y, 10: x}"},
+		{&convertUser{Name: "ann", Age: 30, Tags: []string{"x"}, Secret: "s"}, "{name: ann, age: 30, tags: [x]}"},
+		{convertUser{Name: "bob", Email: "b@c"}, "{name: bob, age: 0, email: b@c, tags: null}"},
+		{[]any{1, "a", nil}, "[1, a, null]"},
+		{&Integer{Value: 5}, "5"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("FromGo(%#v) returned error: %s", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) wrong. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+type convertNode struct {
+	Next *convertNode
+}
+
+func cyclicNode() *convertNode {
+	n := &convertNode{}
+	n.Next = n
+	return n
+}
+
+func cyclicMap() map[string]any {
+	m := map[string]any{}
+	m["self"] = m
+	return m
+}
+
+func cyclicSlice() []any {
+	s := make([]any, 1)
+	s[0] = s
+	return s
+}
+
+func TestFromGoShared(t *testing.T) {
+	shared := &convertUser{Name: "ann"}
+	obj, err := FromGo([]*convertUser{shared, shared})
+	if err != nil {
+		t.Fatalf("FromGo of a shared pointer returned error: %s", err)
+	}
+	if want := "[{name: ann, age: 0, tags: null}, {name: ann, age: 0, tags: null}]"; obj.Inspect() != want {
+		t.Errorf("wrong result. want=%q, got=%q", want, obj.Inspect())
+	}
+}
+
+func TestFromGoErrors(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{1.5, "float 1.5 is not an INTEGER"},
+		{math.NaN(), "float NaN is not an INTEGER"},
+		{1e19, "float 1e+19 is not an INTEGER"},
+		{uint64(1 << 63), "integer 9223372036854775808 overflows INTEGER"},
+		{[]any{1, make(chan int)}, "index 1: unsupported Go type chan int"},
+		{map[string]any{"a": 1.5}, "key a: float 1.5 is not an INTEGER"},
+		{cyclicNode(), "field Next: cycle through *object.convertNode"},
+		{cyclicMap(), "key self: cycle through map[string]interface {}"},
+		{cyclicSlice(), "index 0: cycle through []interface {}"},
+	}
+
+	for _, tt := range tests {
+		_, err := FromGo(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("FromGo(%#v) wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&String{Value: "a"}, &Integer{Value: 1})
+	hash.Set(&String{Value: "b"}, &Array{Elements: []Object{TRUE, NULL}})
+
+	mixed := NewHash()
+	mixed.Set(&Integer{Value: 1}, &String{Value: "one"})
+	mixed.Set(TRUE, &String{Value: "yes"})
+
+	tests := []struct {
+		input    Object
+		expected any
+	}{
+		{NULL, nil},
+		{&Integer{Value: 3}, int64(3)},
+		{&String{Value: "s"}, "s"},
+		{FALSE, false},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}, []any{int64(1), "x"}},
+		{hash, map[string]any{"a": int64(1), "b": []any{true, nil}}},
+		{mixed, map[any]any{int64(1): "one", true: "yes"}},
+	}
+
+	for _, tt := range tests {
+		got, err := ToGo(tt.input)
+		if err != nil {
+			t.Errorf("ToGo(%s) returned error: %s", tt.input.Inspect(), err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ToGo(%s) wrong. want=%#v, got=%#v", tt.input.Inspect(), tt.expected, got)
+		}
+	}
+
+	if _, err := ToGo(&Builtin{}); err == nil {
+		t.Errorf("ToGo(builtin) expected an error")
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	in := convertUser{Name: "ann", Age: 30, Email: "a@b", Tags: []string{"x", "y"}}
+
+	obj, err := FromGo(in)
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+
+	var out convertUser
+	if err := Unmarshal(obj, &out); err != nil {
+		t.Fatalf("Unmarshal returned error: %s", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip wrong. want=%#v, got=%#v", in, out)
+	}
+
+	var small int8
+	err = Unmarshal(&Integer{Value: 300}, &small)
+	if err == nil || err.Error() != "integer 300 overflows int8" {
+		t.Errorf("wrong overflow error, got %v", err)
+	}
+
+	var names []string
+	err = Unmarshal(&Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}, &names)
+	if err == nil || err.Error() != "index 1: cannot convert INTEGER to string" {
+		t.Errorf("wrong element error, got %v", err)
+	}
+
+	var p *int
+	err = Unmarshal(nil, &p)
+	if err == nil || err.Error() != "cannot convert nil to *int" {
+		t.Errorf("wrong nil error, got %v", err)
+	}
+
+	if err := Unmarshal(NULL, out); err == nil {
+		t.Errorf("Unmarshal into a non-pointer expected an error")
+	}
+}
+
+func TestFromGoFunc(t *testing.T) {
+	tests := []struct {
+		fn       any
+		args     []Object
+		expected string
+	}{
+		{strings.ToUpper, []Object{&String{Value: "hi"}}, "HI"},
+		{func(a, b int) int { return a + b }, []Object{&Integer{Value: 1}, &Integer{Value: 2}}, "3"},
+		{func(xs ...int) int { return len(xs) }, []Object{&Integer{Value: 1}, &Integer{Value: 2}}, "2"},
+		{func(u convertUser) string { return u.Name }, []Object{mustFromGo(t, convertUser{Name: "ann"})}, "ann"},
+		{func(v any) any { return v }, []Object{&Array{Elements: []Object{&Integer{Value: 1}}}}, "[1]"},
+		{func(o Object) Object { return o }, []Object{TRUE}, "true"},
+		{func() {}, nil, "null"},
+		{func() (int, string) { return 1, "a" }, nil, "[1, a]"},
+		{func() (int, error) { return 0, errors.New("boom") }, nil, "ERROR: boom"},
+		{func(a int) int { return a }, nil, "ERROR: wrong number of arguments. got = 0, want = 1"},
+		{func(a int) int { return a }, []Object{&String{Value: "x"}}, "ERROR: argument 1: cannot convert STRING to int"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.fn)
+		if err != nil {
+			t.Fatalf("FromGo returned error: %s", err)
+		}
+
+		builtin, ok := obj.(*Builtin)
+		if !ok {
+			t.Fatalf("object is not Builtin. got=%T", obj)
+		}
+
+		result := builtin.Fn(nil, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result. want=%q, got=%q", tt.expected, result.Inspect())
+		}
+	}
+}
+
+func mustFromGo(t *testing.T, v any) Object {
+	t.Helper()
+
+	obj, err := FromGo(v)
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+	return obj
+}
@@ -3,18 +3,39 @@ package object
 func NewLocalEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.session = outer.session
 
 	return env
 }
 
 func NewEnvironment() *Environment {
+	return NewSessionEnvironment(&Session{})
+}
+
+// NewSessionEnvironment returns an empty environment for a run of session.
+func NewSessionEnvironment(session *Session) *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, session: session}
+}
+
+// NewCatchEnvironment returns the environment of a catch block, which binds
+// param to value within the block only. Other names the block defines go
+// to outer, since blocks do not have scopes of their own.
+func NewCatchEnvironment(outer *Environment, param string, value Object) *Environment {
+	env := NewLocalEnvironment(outer)
+	env.param = param
+	env.store[param] = value
+
+	return env
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	outer   *Environment
+	session *Session
+
+	// param is the parameter of a catch block environment.
+	param string
 }
 
 func (e *Environment) Empty() {
@@ -33,6 +54,10 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	if e.param != "" && name != e.param {
+		return e.outer.Set(name, val)
+	}
+
 	e.store[name] = val
 	return val
 }
@@ -41,6 +66,11 @@ func (e *Environment) Outer() *Environment {
 	return e.outer
 }
 
+// Session returns the session of the run the environment belongs to.
+func (e *Environment) Session() *Session {
+	return e.session
+}
+
 func (e *Environment) Store() map[string]Object {
 	return e.store
 }
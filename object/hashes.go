@@ -0,0 +1,134 @@
+package object
+
+func hashArg(name string, arg Object) (*Hash, *Error) {
+	hash, ok := arg.(*Hash)
+	if !ok {
+		return nil, newError("argument to `%s` must be HASH, got %s", name, arg.Type())
+	}
+
+	return hash, nil
+}
+
+func keyArg(arg Object) (Hashable, *Error) {
+	key, ok := AsHashable(arg)
+	if !ok {
+		return nil, newError("unusable as hash key: %s", arg.Type())
+	}
+
+	return key, nil
+}
+
+func copyHash(hash *Hash) *Hash {
+	out := NewHash()
+	for _, pair := range hash.Pairs() {
+		out.Set(pair.Key.(Hashable), pair.Value)
+	}
+
+	return out
+}
+
+func bltnHas(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	hash, err := hashArg("has", args[0])
+	if err != nil {
+		return err
+	}
+
+	key, err := keyArg(args[1])
+	if err != nil {
+		return err
+	}
+
+	_, ok := hash.Get(key)
+	return nativeBool(ok)
+}
+
+func bltnDelete(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	hash, err := hashArg("delete", args[0])
+	if err != nil {
+		return err
+	}
+
+	key, err := keyArg(args[1])
+	if err != nil {
+		return err
+	}
+
+	out := copyHash(hash)
+	out.Delete(key)
+
+	return out
+}
+
+func bltnMerge(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	out := NewHash()
+	for _, arg := range args {
+		hash, err := hashArg("merge", arg)
+		if err != nil {
+			return err
+		}
+
+		for _, pair := range hash.Pairs() {
+			out.Set(pair.Key.(Hashable), pair.Value)
+		}
+	}
+
+	return out
+}
+
+func bltnEntries(env *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got = %d, want = 1", len(args))
+	}
+
+	hash, err := hashArg("entries", args[0])
+	if err != nil {
+		return err
+	}
+
+	entries := make([]Object, hash.Len())
+	for i, pair := range hash.Pairs() {
+		entries[i] = &Array{Elements: []Object{pair.Key, pair.Value}}
+	}
+
+	return &Array{Elements: entries}
+}
+
+func bltnFromEntries(env *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got = %d, want = 1", len(args))
+	}
+
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return newError("argument to `fromEntries` must be ARRAY, got %s", args[0].Type())
+	}
+
+	out := NewHash()
+	for _, elem := range arr.Elements {
+		entry, ok := elem.(*Array)
+		if !ok || len(entry.Elements) != 2 {
+			return newError("entries of `fromEntries` must be [key, value] pairs, got %s", elem.Inspect())
+		}
+
+		key, err := keyArg(entry.Elements[0])
+		if err != nil {
+			return err
+		}
+
+		out.Set(key, entry.Elements[1])
+	}
+
+	return out
+}
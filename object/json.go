@@ -0,0 +1,218 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var jsonModule = newModule([]moduleFunction{
+	{"encode", bltnJSONEncode},
+	{"decode", bltnJSONDecode},
+})
+
+type moduleFunction struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+// newModule builds a hash of builtins, so that related builtins can be
+// reached as `module.name`.
+func newModule(fns []moduleFunction) *Hash {
+	module := NewHash()
+	for _, fn := range fns {
+		module.Set(&String{Value: fn.Name}, &Builtin{Fn: fn.Fn})
+	}
+
+	return module
+}
+
+// maxIndent is the most spaces json.encode indents by.
+const maxIndent = 16
+
+func bltnJSONEncode(env *Environment, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 1 or 2", len(args))
+	}
+
+	var out bytes.Buffer
+	if err := encodeJSON(&out, args[0]); err != nil {
+		return newError("json.encode: %s", err)
+	}
+
+	if len(args) == 1 {
+		return &String{Value: out.String()}
+	}
+
+	var indent string
+	switch arg := args[1].(type) {
+	case *Integer:
+		if arg.Value < 0 || arg.Value > maxIndent {
+			return newArgumentError("json.encode: indent must be between 0 and %d, got %d", maxIndent, arg.Value)
+		}
+		indent = strings.Repeat(" ", int(arg.Value))
+	case *String:
+		indent = arg.Value
+	default:
+		return newError("argument to `json.encode` must be INTEGER or STRING, got %s", arg.Type())
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, out.Bytes(), "", indent); err != nil {
+		return newError("json.encode: %s", err)
+	}
+
+	return &String{Value: indented.String()}
+}
+
+func encodeJSON(out *bytes.Buffer, obj Object) error {
+	switch obj := obj.(type) {
+	case *Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *Null:
+		out.WriteString("null")
+	case *String:
+		encodeJSONString(out, obj.Value)
+	case *Array:
+		out.WriteByte('[')
+		for i, elem := range obj.Elements {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := encodeJSON(out, elem); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+	case *Hash:
+		out.WriteByte('{')
+		for i, pair := range obj.Pairs() {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+
+			switch key := pair.Key.(type) {
+			case *String:
+				encodeJSONString(out, key.Value)
+			case *Integer, *Boolean:
+				encodeJSONString(out, key.Inspect())
+			default:
+				return fmt.Errorf("unsupported hash key %s", key.Type())
+			}
+
+			out.WriteByte(':')
+			if err := encodeJSON(out, pair.Value); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot encode %s", obj.Type())
+	}
+
+	return nil
+}
+
+func encodeJSONString(out *bytes.Buffer, s string) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	// Encode terminates every value with a newline.
+	out.Truncate(out.Len() - 1)
+}
+
+func bltnJSONDecode(env *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got = %d, want = 1", len(args))
+	}
+
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `json.decode` must be STRING, got %s", args[0].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+
+	obj, err := decodeJSON(dec)
+	if err != nil {
+		return newError("json.decode: %s", err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return newError("json.decode: unexpected data after top-level value")
+	}
+
+	return obj
+}
+
+// decodeJSON reads the next value from dec token by token, so that object
+// keys end up in the hash in the order they appear in the input.
+func decodeJSON(dec *json.Decoder) (Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		return nativeBool(tok), nil
+	case string:
+		return &String{Value: tok}, nil
+	case json.Number:
+		value, err := strconv.ParseInt(tok.String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("number %s is not an integer", tok)
+		}
+		return &Integer{Value: value}, nil
+	case json.Delim:
+		switch tok {
+		case '[':
+			elements := []Object{}
+			for dec.More() {
+				elem, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, elem)
+			}
+
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return &Array{Elements: elements}, nil
+		case '{':
+			hash := NewHash()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				value, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				hash.Set(&String{Value: keyTok.(string)}, value)
+			}
+
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return hash, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
@@ -2,10 +2,12 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/token"
 	"strings"
 )
 
@@ -24,24 +26,48 @@ const (
 	NULL_OBJ              = "NULL"
 	RETURN_VALUE_OBJ      = "RETURN_VALUE"
 	ERROR_OBJ             = "ERROR"
+	THROWN_VALUE_OBJ      = "THROWN_VALUE"
 	FUNCTION_OBJ          = "FUNCTION"
 	STRING_OBJ            = "STRING"
 	BUILTIN_OBJ           = "BUILTIN"
 	ARRAY_OBJ             = "ARRAY"
 	HASH_OBJ              = "HASH"
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
-	CLOSURE_OBJ           = "CLOSURE_OBJ"
+	QUOTE_OBJ             = "QUOTE"
+	MACRO_OBJ             = "MACRO"
 )
 
+// CLOSURE_OBJ is the type of the VM's closures. It equals FUNCTION_OBJ on
+// purpose: closures are the functions of compiled code, so type() and the
+// error messages that name a type must say FUNCTION in both engines. Tell
+// the two apart by their Go types, *Function and *Closure, not by Type().
+const CLOSURE_OBJ = FUNCTION_OBJ
+
 type Object interface {
 	Type() ObjectType
 	Inspect() string
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
+// AsHashable returns obj as a Hashable if it can be used as a hash key.
+// Arrays are only usable when all of their elements are.
+func AsHashable(obj Object) (Hashable, bool) {
+	if arr, ok := obj.(*Array); ok {
+		for _, elem := range arr.Elements {
+			if _, ok := AsHashable(elem); !ok {
+				return nil, false
+			}
+		}
+	}
+
+	key, ok := obj.(Hashable)
+	return key, ok
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -87,33 +113,125 @@ type ReturnValue struct {
 func (*ReturnValue) Type() ObjectType   { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 
+// Kinds of runtime errors, reported to scripts as the `type` of an error.
+const (
+	GENERIC_ERROR  = "Error"
+	TYPE_ERROR     = "TypeError"
+	NAME_ERROR     = "NameError"
+	ARGUMENT_ERROR = "ArgumentError"
+)
+
 type Error struct {
 	Message string
+	Kind    string
+	Pos     token.Position
 }
 
 func (*Error) Type() ObjectType  { return ERROR_OBJ }
 func (e *Error) Inspect() string { return "ERROR: " + e.Message }
+func (e *Error) Error() string   { return e.Message }
+
+// Field returns the `message` or `type` of the error, so that scripts can
+// inspect a caught error with e.message and e.type.
+func (e *Error) Field(name Object) Object {
+	str, ok := name.(*String)
+	if !ok {
+		return NULL
+	}
+
+	switch str.Value {
+	case "message":
+		return &String{Value: e.Message}
+	case "type":
+		if e.Kind == "" {
+			return &String{Value: GENERIC_ERROR}
+		}
+		return &String{Value: e.Kind}
+	default:
+		return NULL
+	}
+}
+
+// ThrownValue wraps a value being thrown while the evaluator unwinds to the
+// nearest try expression. Pos is where it was thrown.
+type ThrownValue struct {
+	Value Object
+	Pos   token.Position
+}
+
+func (*ThrownValue) Type() ObjectType   { return THROWN_VALUE_OBJ }
+func (tv *ThrownValue) Inspect() string { return tv.Value.Inspect() }
+
+// Uncaught returns the error reported for a value thrown at pos that no try
+// expression caught. An error keeps the position it was raised at, if any.
+func Uncaught(value Object, pos token.Position) *Error {
+	if err, ok := value.(*Error); ok {
+		if !err.Pos.IsValid() {
+			err.Pos = pos
+		}
+		return err
+	}
+
+	return &Error{Message: "uncaught exception: " + value.Inspect(), Pos: pos}
+}
 
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+
+	// Name is the name the function was bound to with let, if any.
+	Name string
 }
 
 func (*Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
+	params := make([]string, len(f.Parameters))
+	for i, p := range f.Parameters {
+		params[i] = p.Value
+	}
+
+	return inspectFunction(f.Name, params)
+}
+
+// inspectFunction returns the form functions print as in both engines.
+// The VM does not keep the source of a function body.
+func inspectFunction(name string, params []string) string {
+	if name != "" {
+		name = " " + name
+	}
+	return "fn" + name + "(" + strings.Join(params, ", ") + ") { ... }"
+}
+
+// Quote is an unevaluated syntax tree, produced by `quote` and passed to
+// macros as their arguments.
+type Quote struct {
+	Node ast.Node
+}
+
+func (*Quote) Type() ObjectType  { return QUOTE_OBJ }
+func (q *Quote) Inspect() string { return "QUOTE(" + q.Node.String() + ")" }
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (*Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
+	for _, p := range m.Parameters {
 		params = append(params, p.String())
 	}
 
-	out.WriteString("fn")
+	out.WriteString("macro")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(m.Body.String())
 	out.WriteString("\n}")
 
 	return out.String()
@@ -144,6 +262,25 @@ type Array struct {
 }
 
 func (*Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+
+	for _, elem := range a.Elements {
+		key, ok := elem.(Hashable)
+		if !ok {
+			h.Write([]byte(elem.Type()))
+			continue
+		}
+
+		hashKey := key.HashKey()
+		h.Write([]byte(hashKey.Type))
+		binary.BigEndian.PutUint64(buf, hashKey.Value)
+		h.Write(buf)
+	}
+
+	return HashKey{Type: a.Type(), Value: h.Sum64()}
+}
 func (a *Array) Inspect() string {
 	var out bytes.Buffer
 
@@ -164,8 +301,81 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash keeps its pairs in insertion order, with an index from HashKey to
+// the positions of the pairs sharing that hash. Keys whose hashes collide
+// are told apart by comparing the keys themselves.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs []HashPair
+	index map[HashKey][]int
+}
+
+func NewHash() *Hash {
+	return &Hash{index: make(map[HashKey][]int)}
+}
+
+func (h *Hash) lookup(key Hashable) (HashKey, int) {
+	hashKey := key.HashKey()
+
+	for _, i := range h.index[hashKey] {
+		if Equal(h.pairs[i].Key, key) {
+			return hashKey, i
+		}
+	}
+
+	return hashKey, -1
+}
+
+func (h *Hash) Get(key Hashable) (HashPair, bool) {
+	if _, i := h.lookup(key); i >= 0 {
+		return h.pairs[i], true
+	}
+
+	return HashPair{}, false
+}
+
+// Set stores value under key. Overwriting an existing key keeps its
+// original position.
+func (h *Hash) Set(key Hashable, value Object) {
+	if h.index == nil {
+		h.index = make(map[HashKey][]int)
+	}
+
+	hashKey, i := h.lookup(key)
+	if i >= 0 {
+		h.pairs[i].Value = value
+		return
+	}
+
+	h.index[hashKey] = append(h.index[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+// Delete removes key from h, reporting whether it was present.
+func (h *Hash) Delete(key Hashable) bool {
+	_, i := h.lookup(key)
+	if i < 0 {
+		return false
+	}
+
+	h.pairs = append(h.pairs[:i:i], h.pairs[i+1:]...)
+
+	h.index = make(map[HashKey][]int)
+	for j, pair := range h.pairs {
+		hashKey := pair.Key.(Hashable).HashKey()
+		h.index[hashKey] = append(h.index[hashKey], j)
+	}
+
+	return true
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Pairs returns the pairs of h in insertion order. The slice must not be
+// modified.
+func (h *Hash) Pairs() []HashPair {
+	return h.pairs
 }
 
 func (*Hash) Type() ObjectType { return HASH_OBJ }
@@ -173,7 +383,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.pairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -188,6 +398,15 @@ type CompiledFunction struct {
 	Instructions code.Instructions
 	NumLocals    int
 	NumParams    int
+
+	// Name is the name the function was bound to with let, if any.
+	Name      string
+	SourceMap code.SourceMap
+
+	// LocalNames and FreeNames hold the names of the local and free
+	// variables, indexed by slot, for debuggers.
+	LocalNames []string
+	FreeNames  []string
 }
 
 func (*CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
@@ -202,5 +421,10 @@ type Closure struct {
 
 func (*Closure) Type() ObjectType { return CLOSURE_OBJ }
 func (c *Closure) Inspect() string {
-	return fmt.Sprintf("Closure[%p]", c)
+	var params []string
+	if len(c.Fn.LocalNames) >= c.Fn.NumParams {
+		params = c.Fn.LocalNames[:c.Fn.NumParams]
+	}
+
+	return inspectFunction(c.Fn.Name, params)
 }
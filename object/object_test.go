@@ -17,3 +17,138 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestEqual(t *testing.T) {
+	hash := func(pairs ...Object) *Hash {
+		h := NewHash()
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i].(Hashable), pairs[i+1])
+		}
+		return h
+	}
+	array := func(elems ...Object) *Array { return &Array{Elements: elems} }
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+	str := &String{Value: "a"}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{one, two, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{one, str, false},
+		{NULL, NULL, true},
+		{TRUE, FALSE, false},
+		{array(one, two), array(&Integer{Value: 1}, &Integer{Value: 2}), true},
+		{array(one, two), array(two, one), false},
+		{array(one), array(one, two), false},
+		{array(array(str)), array(array(&String{Value: "a"})), true},
+		{hash(str, one), hash(&String{Value: "a"}, &Integer{Value: 1}), true},
+		{hash(str, one), hash(str, two), false},
+		{hash(str, one), hash(one, one), false},
+		{hash(str, array(one)), hash(str, array(one)), true},
+		{&Builtin{}, &Builtin{}, false},
+	}
+
+	for i, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] - Equal(%s, %s) wrong. want = %t, got = %t", i, tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestEqualCycles(t *testing.T) {
+	a := &Array{}
+	a.Elements = []Object{&Integer{Value: 1}, a}
+	b := &Array{}
+	b.Elements = []Object{&Integer{Value: 1}, b}
+	c := &Array{}
+	c.Elements = []Object{&Integer{Value: 2}, c}
+
+	if !Equal(a, b) {
+		t.Errorf("cyclic arrays with equal elements are not equal")
+	}
+
+	if Equal(a, c) {
+		t.Errorf("cyclic arrays with different elements are equal")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	arr1 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	arr2 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	diff := &Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}
+	nested := &Array{Elements: []Object{arr1}}
+
+	if arr1.HashKey() != arr2.HashKey() {
+		t.Errorf("arrays with same content have different hash keys")
+	}
+	if arr1.HashKey() == diff.HashKey() {
+		t.Errorf("arrays with different content have same hash keys")
+	}
+	if arr1.HashKey() == nested.HashKey() {
+		t.Errorf("nested array has same hash key as its element")
+	}
+
+	if _, ok := AsHashable(nested); !ok {
+		t.Errorf("array of hashable elements is not hashable")
+	}
+	if _, ok := AsHashable(&Array{Elements: []Object{NewHash()}}); ok {
+		t.Errorf("array containing a hash is hashable")
+	}
+	if _, ok := AsHashable(NewHash()); ok {
+		t.Errorf("hash is hashable")
+	}
+}
+
+// collidingKey hashes every value to the same HashKey.
+type collidingKey struct{ name string }
+
+func (*collidingKey) Type() ObjectType  { return "COLLIDING" }
+func (c *collidingKey) Inspect() string { return c.name }
+func (*collidingKey) HashKey() HashKey  { return HashKey{Type: "COLLIDING", Value: 42} }
+
+func TestHashCollisions(t *testing.T) {
+	a, b := &collidingKey{"a"}, &collidingKey{"b"}
+
+	h := NewHash()
+	h.Set(a, &Integer{Value: 1})
+	h.Set(b, &Integer{Value: 2})
+	h.Set(a, &Integer{Value: 3})
+
+	if h.Len() != 2 {
+		t.Fatalf("hash has wrong num of pairs. want = 2, got = %d", h.Len())
+	}
+
+	for key, expected := range map[*collidingKey]int64{a: 3, b: 2} {
+		pair, ok := h.Get(key)
+		if !ok {
+			t.Errorf("no pair for key %s", key.name)
+			continue
+		}
+
+		if pair.Value.(*Integer).Value != expected {
+			t.Errorf("wrong value for key %s. want = %d, got = %s", key.name, expected, pair.Value.Inspect())
+		}
+	}
+
+	if _, ok := h.Get(&collidingKey{"c"}); ok {
+		t.Errorf("found pair for a key that was never set")
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: "c"}, &Integer{Value: 1})
+	h.Set(&Integer{Value: 1}, &Integer{Value: 2})
+	h.Set(&String{Value: "a"}, &Integer{Value: 3})
+	h.Set(&String{Value: "c"}, &Integer{Value: 4})
+
+	expected := "{c: 4, 1: 2, a: 3}"
+	for i := 0; i < 10; i++ {
+		if h.Inspect() != expected {
+			t.Fatalf("hash inspected wrongly. want = %q, got = %q", expected, h.Inspect())
+		}
+	}
+}
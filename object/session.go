@@ -0,0 +1,68 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"os"
+)
+
+// Session is the state of one run of a program, which every environment
+// of the run shares.
+type Session struct {
+	// Depth is the number of calls in progress in the interpreter.
+	Depth int
+
+	// Tracer, if not nil, follows the interpreter.
+	Tracer Tracer
+
+	// Output is where puts writes, os.Stdout if nil.
+	Output io.Writer
+
+	// Exit is called by exit with the exit status, and is os.Exit if nil.
+	// If it returns, the run stops with an *ExitError.
+	Exit func(status int)
+
+	exited *ExitError
+}
+
+// ExitError ends a run whose program called exit, once the Exit of the
+// session has returned. Try expressions cannot catch it.
+type ExitError struct {
+	Status int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Status)
+}
+
+// Exited returns the error that stops the run after the program called
+// exit, or nil.
+func (s *Session) Exited() *ExitError {
+	return s.exited
+}
+
+func (s *Session) output() io.Writer {
+	if s.Output == nil {
+		return os.Stdout
+	}
+	return s.Output
+}
+
+func (s *Session) exit(status int) {
+	if s.Exit == nil {
+		os.Exit(status)
+	}
+
+	s.Exit(status)
+	s.exited = &ExitError{Status: status}
+}
+
+// Tracer follows evaluation. Trace is called after each node is evaluated,
+// with its result, which is nil for let statements. Call and Return
+// bracket the evaluation of the body of a function.
+type Tracer interface {
+	Call(fn *Function)
+	Return(fn *Function)
+	Trace(node ast.Node, result Object)
+}
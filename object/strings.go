@@ -0,0 +1,374 @@
+package object
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+func stringArgs(name string, args []Object, want ...int) ([]string, *Error) {
+	ok := false
+	for _, n := range want {
+		if len(args) == n {
+			ok = true
+		}
+	}
+
+	if !ok {
+		if len(want) == 1 {
+			return nil, newError("wrong number of arguments. got = %d, want = %d", len(args), want[0])
+		}
+		return nil, newError("wrong number of arguments. got = %d, want = %d or %d", len(args), want[0], want[len(want)-1])
+	}
+
+	values := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*String)
+		if !ok {
+			return nil, newError("argument to `%s` must be STRING, got %s", name, arg.Type())
+		}
+		values[i] = str.Value
+	}
+
+	return values, nil
+}
+
+func intArg(name string, arg Object) (int, *Error) {
+	integer, ok := arg.(*Integer)
+	if !ok {
+		return 0, newError("argument to `%s` must be INTEGER, got %s", name, arg.Type())
+	}
+
+	return int(integer.Value), nil
+}
+
+func stringArray(values []string) *Array {
+	elements := make([]Object, len(values))
+	for i, v := range values {
+		elements[i] = &String{Value: v}
+	}
+
+	return &Array{Elements: elements}
+}
+
+func nativeBool(value bool) *Boolean {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
+func bltnSplit(env *Environment, args ...Object) Object {
+	values, err := stringArgs("split", args, 2)
+	if err != nil {
+		return err
+	}
+
+	return stringArray(strings.Split(values[0], values[1]))
+}
+
+func bltnJoin(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return newError("argument to `join` must be ARRAY, got %s", args[0].Type())
+	}
+
+	sep, ok := args[1].(*String)
+	if !ok {
+		return newError("argument to `join` must be STRING, got %s", args[1].Type())
+	}
+
+	elems := make([]string, len(arr.Elements))
+	for i, elem := range arr.Elements {
+		str, ok := elem.(*String)
+		if !ok {
+			return newError("elements of `join` must be STRING, got %s", elem.Type())
+		}
+		elems[i] = str.Value
+	}
+
+	return &String{Value: strings.Join(elems, sep.Value)}
+}
+
+func trimmer(name string, trim func(string, string) string, trimSpace func(string) string) BuiltinFunction {
+	return func(env *Environment, args ...Object) Object {
+		values, err := stringArgs(name, args, 1, 2)
+		if err != nil {
+			return err
+		}
+
+		if len(values) == 1 {
+			return &String{Value: trimSpace(values[0])}
+		}
+
+		return &String{Value: trim(values[0], values[1])}
+	}
+}
+
+var (
+	bltnTrim = trimmer("trim", strings.Trim, strings.TrimSpace)
+
+	bltnTrimLeft = trimmer("trimLeft", strings.TrimLeft, func(s string) string {
+		return strings.TrimLeft(s, " \t\n\r\v\f")
+	})
+
+	bltnTrimRight = trimmer("trimRight", strings.TrimRight, func(s string) string {
+		return strings.TrimRight(s, " \t\n\r\v\f")
+	})
+)
+
+func bltnUpper(env *Environment, args ...Object) Object {
+	values, err := stringArgs("upper", args, 1)
+	if err != nil {
+		return err
+	}
+
+	return &String{Value: strings.ToUpper(values[0])}
+}
+
+func bltnLower(env *Environment, args ...Object) Object {
+	values, err := stringArgs("lower", args, 1)
+	if err != nil {
+		return err
+	}
+
+	return &String{Value: strings.ToLower(values[0])}
+}
+
+func bltnReplace(env *Environment, args ...Object) Object {
+	if len(args) != 3 && len(args) != 4 {
+		return newError("wrong number of arguments. got = %d, want = 3 or 4", len(args))
+	}
+
+	values, err := stringArgs("replace", args[:3], 3)
+	if err != nil {
+		return err
+	}
+
+	n := -1
+	if len(args) == 4 {
+		if n, err = intArg("replace", args[3]); err != nil {
+			return err
+		}
+	}
+
+	return &String{Value: strings.Replace(values[0], values[1], values[2], n)}
+}
+
+func bltnContains(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		sub, ok := args[1].(*String)
+		if !ok {
+			return newError("argument to `contains` must be STRING, got %s", args[1].Type())
+		}
+		return nativeBool(strings.Contains(arg.Value, sub.Value))
+	case *Array:
+		return nativeBool(arrayIndex(arg, args[1]) >= 0)
+	default:
+		return newError("argument to `contains` not supported, got %s", arg.Type())
+	}
+}
+
+func bltnStartsWith(env *Environment, args ...Object) Object {
+	values, err := stringArgs("startsWith", args, 2)
+	if err != nil {
+		return err
+	}
+
+	return nativeBool(strings.HasPrefix(values[0], values[1]))
+}
+
+func bltnEndsWith(env *Environment, args ...Object) Object {
+	values, err := stringArgs("endsWith", args, 2)
+	if err != nil {
+		return err
+	}
+
+	return nativeBool(strings.HasSuffix(values[0], values[1]))
+}
+
+func bltnIndexOf(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		sub, ok := args[1].(*String)
+		if !ok {
+			return newError("argument to `indexOf` must be STRING, got %s", args[1].Type())
+		}
+
+		idx := strings.Index(arg.Value, sub.Value)
+		if idx >= 0 {
+			idx = utf8.RuneCountInString(arg.Value[:idx])
+		}
+		return &Integer{Value: int64(idx)}
+	case *Array:
+		return &Integer{Value: int64(arrayIndex(arg, args[1]))}
+	default:
+		return newError("argument to `indexOf` not supported, got %s", arg.Type())
+	}
+}
+
+// arrayIndex returns the index of the first element of arr equal to x, or -1.
+func arrayIndex(arr *Array, x Object) int {
+	for i, elem := range arr.Elements {
+		if Equal(elem, x) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func bltnSubstr(env *Environment, args ...Object) Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got = %d, want = 2 or 3", len(args))
+	}
+
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `substr` must be STRING, got %s", args[0].Type())
+	}
+
+	runes := []rune(str.Value)
+
+	start, err := intArg("substr", args[1])
+	if err != nil {
+		return err
+	}
+
+	if start < 0 {
+		start += len(runes)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > len(runes) {
+		start = len(runes)
+	}
+
+	end := len(runes)
+	if len(args) == 3 {
+		length, err := intArg("substr", args[2])
+		if err != nil {
+			return err
+		}
+		if length < 0 {
+			return newError("length of `substr` must not be negative, got %d", length)
+		}
+		if length < end-start {
+			end = start + length
+		}
+	}
+
+	return &String{Value: string(runes[start:end])}
+}
+
+// MaxStringLength limits the strings that builtins such as `repeat` build
+// from a count, so that one call cannot exhaust memory.
+const MaxStringLength = 1 << 24
+
+func bltnRepeat(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `repeat` must be STRING, got %s", args[0].Type())
+	}
+
+	count, err := intArg("repeat", args[1])
+	if err != nil {
+		return err
+	}
+
+	if count < 0 {
+		return newError("count of `repeat` must not be negative, got %d", count)
+	}
+
+	if len(str.Value) > 0 && count > MaxStringLength/len(str.Value) {
+		return newArgumentError("result of `repeat` would be longer than %d bytes", MaxStringLength)
+	}
+
+	return &String{Value: strings.Repeat(str.Value, count)}
+}
+
+func bltnChars(env *Environment, args ...Object) Object {
+	values, err := stringArgs("chars", args, 1)
+	if err != nil {
+		return err
+	}
+
+	chars := []string{}
+	for _, r := range values[0] {
+		chars = append(chars, string(r))
+	}
+
+	return stringArray(chars)
+}
+
+func padder(name string, left bool) BuiltinFunction {
+	return func(env *Environment, args ...Object) Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("wrong number of arguments. got = %d, want = 2 or 3", len(args))
+		}
+
+		str, ok := args[0].(*String)
+		if !ok {
+			return newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+		}
+
+		width, err := intArg(name, args[1])
+		if err != nil {
+			return err
+		}
+
+		pad := " "
+		if len(args) == 3 {
+			p, ok := args[2].(*String)
+			if !ok {
+				return newError("argument to `%s` must be STRING, got %s", name, args[2].Type())
+			}
+			if p.Value == "" {
+				return newError("padding of `%s` must not be empty", name)
+			}
+			pad = p.Value
+		}
+
+		if width > MaxStringLength {
+			return newArgumentError("width of `%s` must be at most %d, got %d", name, MaxStringLength, width)
+		}
+
+		missing := width - utf8.RuneCountInString(str.Value)
+		if missing <= 0 {
+			return str
+		}
+
+		padRunes := []rune(pad)
+		padding := make([]rune, missing)
+		for i := range padding {
+			padding[i] = padRunes[i%len(padRunes)]
+		}
+
+		if left {
+			return &String{Value: string(padding) + str.Value}
+		}
+		return &String{Value: str.Value + string(padding)}
+	}
+}
+
+var (
+	bltnPadLeft  = padder("padLeft", true)
+	bltnPadRight = padder("padRight", false)
+)
@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+// Error is a syntax error. Expected is the token type the parser wanted, if
+// there was a single one, and Found is the token it got instead. Hint is an
+// optional suggestion on how to fix the error.
+type Error struct {
+	Pos      token.Position
+	Expected token.TokenType
+	Found    token.Token
+	Message  string
+	Hint     string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+func (p *Parser) addError(err *Error) {
+	if p.recovering {
+		return
+	}
+
+	p.errors = append(p.errors, err)
+	p.recovering = true
+}
+
+func (p *Parser) peekError(t token.TokenType) {
+	p.addError(&Error{
+		Pos:      p.peekTok.Pos,
+		Expected: t,
+		Found:    p.peekTok,
+		Message:  fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekTok.Type),
+		Hint:     p.peekHint(t),
+	})
+}
+
+func (p *Parser) peekHint(t token.TokenType) string {
+	switch {
+	case p.peekTok.Type == token.EOF:
+		return "the input ended before the construct was complete"
+	case t == token.IDENT && token.LookupIdent(p.peekTok.Literal) != token.IDENT:
+		return fmt.Sprintf("`%s` is a keyword and cannot be used as a name", p.peekTok.Literal)
+	case t == token.ASSIGN && p.curTokIs(token.IDENT):
+		return "let statements have the form `let <name> = <value>;`"
+	case t == token.RPAREN || t == token.RBRACKET:
+		return fmt.Sprintf("a closing `%s` may be missing", t)
+	}
+
+	return ""
+}
+
+func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	err := &Error{
+		Pos:     p.curTok.Pos,
+		Found:   p.curTok,
+		Message: fmt.Sprintf("no prefix parse function for %s found", t),
+	}
+
+	switch t {
+	case token.EOF:
+		err.Hint = "the input ended where an expression was expected"
+	case token.ILLEGAL:
+		err.Hint = fmt.Sprintf("%q is not a valid character here", p.curTok.Literal)
+	case token.RPAREN, token.RBRACKET, token.RBRACE, token.SEMICOLON, token.COMMA:
+		err.Hint = "an expression is missing before this token"
+	}
+
+	p.addError(err)
+}
+
+// synchronize skips the rest of a statement that failed to parse, so that
+// the next error reported is an independent one. outer is the number of
+// braces open around the statement. Outside the braces the statement opened
+// itself, it stops on the closing semicolon, or before a keyword that
+// starts a statement or the brace that closes the enclosing block.
+func (p *Parser) synchronize(outer int) {
+	for !p.curTokIs(token.EOF) {
+		if p.braces <= outer {
+			if p.curTokIs(token.SEMICOLON) {
+				break
+			}
+
+			switch p.peekTok.Type {
+			case token.LET, token.RETURN, token.THROW, token.RBRACE, token.EOF:
+				p.recovering = false
+				return
+			}
+		}
+
+		p.nextToken()
+	}
+
+	p.recovering = false
+}
@@ -14,7 +14,11 @@ type Parser struct {
 	curTok  token.Token
 	peekTok token.Token
 
-	errors []string
+	errors     []*Error
+	recovering bool
+
+	// braces is how many braces are open up to and including curTok.
+	braces int
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -50,6 +54,7 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:    PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -71,7 +76,7 @@ func (p *Parser) curPrecedence() int {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:              l,
-		errors:         []string{},
+		errors:         []*Error{},
 		prefixParseFns: make(map[token.TokenType]prefixParseFn),
 		infixParseFns:  make(map[token.TokenType]infixParseFn),
 	}
@@ -87,7 +92,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
@@ -104,22 +111,25 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	return p
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*Error {
 	return p.errors
 }
 
-func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekTok.Type)
-	p.errors = append(p.errors, msg)
-}
-
 func (p *Parser) nextToken() {
 	p.curTok = p.peekTok
 	p.peekTok = p.l.NextToken()
+
+	switch p.curTok.Type {
+	case token.LBRACE:
+		p.braces++
+	case token.RBRACE:
+		p.braces--
+	}
 }
 
 func (p *Parser) registerPrefix(t token.TokenType, fn prefixParseFn) {
@@ -135,20 +145,42 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.curTok.Type != token.EOF {
-		stmt := p.parseStatement()
-		program.Statements = append(program.Statements, stmt)
+		if stmt := p.parseStatementOrRecover(); stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
 		p.nextToken()
 	}
 
 	return program
 }
 
+// parseStatementOrRecover parses a statement, returning nil and skipping to
+// the end of the statement if it contains a syntax error.
+func (p *Parser) parseStatementOrRecover() ast.Statement {
+	// The braces open around the statement, not counting its first token.
+	outer := p.braces
+	if p.curTokIs(token.LBRACE) {
+		outer--
+	}
+
+	stmt := p.parseStatement()
+
+	if p.recovering {
+		p.synchronize(outer)
+		return nil
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curTok.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -196,6 +228,20 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curTok}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curTok}
 
@@ -208,11 +254,6 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
-}
-
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curTok.Type]
 	if prefix == nil {
@@ -221,6 +262,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	}
 
 	leftExp := prefix()
+	if p.recovering {
+		return nil
+	}
 
 	for !p.peekTokIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekTok.Type]
@@ -231,6 +275,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.nextToken()
 
 		leftExp = infix(leftExp)
+		if p.recovering {
+			return nil
+		}
 	}
 
 	return leftExp
@@ -245,8 +292,12 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curTok.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curTok.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(&Error{
+			Pos:     p.curTok.Pos,
+			Found:   p.curTok,
+			Message: fmt.Sprintf("could not parse %q as integer", p.curTok.Literal),
+			Hint:    "integers must fit in 64 bits",
+		})
 		return nil
 	}
 
@@ -307,12 +358,24 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.curTokIs(token.RBRACE) && !p.curTokIs(token.EOF) {
-		stmt := p.parseStatement()
-		block.Statements = append(block.Statements, stmt)
+		if stmt := p.parseStatementOrRecover(); stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
 
 		p.nextToken()
 	}
 
+	if p.curTokIs(token.EOF) {
+		p.addError(&Error{
+			Pos:      p.curTok.Pos,
+			Expected: token.RBRACE,
+			Found:    p.curTok,
+			Message:  "expected next token to be }, got EOF instead",
+			Hint:     fmt.Sprintf("the block opened at %s is never closed", block.Token.Pos),
+		})
+	}
+	block.Rbrace = p.curTok.Pos
+
 	return block
 }
 
@@ -349,6 +412,62 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curTok}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		expression.Param = &ast.Identifier{Token: p.curTok, Value: p.curTok.Literal}
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.addError(&Error{
+			Pos:     p.peekTok.Pos,
+			Found:   p.peekTok,
+			Message: fmt.Sprintf("expected catch or finally after try block, got %s instead", p.peekTok.Type),
+			Hint:    "add a `catch (e) { ... }` or `finally { ... }` block",
+		})
+		return nil
+	}
+
+	return expression
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
@@ -357,14 +476,18 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		return identifiers
 	}
 
-	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
 
 	ident := &ast.Identifier{Token: p.curTok, Value: p.curTok.Literal}
 	identifiers = append(identifiers, ident)
 
 	for p.peekTokIs(token.COMMA) {
 		p.nextToken()
-		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 		ident := &ast.Identifier{Token: p.curTok, Value: p.curTok.Literal}
 		identifiers = append(identifiers, ident)
 	}
@@ -392,12 +515,29 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curTok}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+	return lit
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{
 		Token:    p.curTok,
 		Function: function,
 	}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curTok.Pos
 	return exp
 }
 
@@ -409,6 +549,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curTok}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbrack = p.curTok.Pos
 
 	return array
 }
@@ -456,11 +597,33 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curTok, Left: left}
+	tok := p.curTok
 
-	p.nextToken()
+	var index ast.Expression
+	if !p.peekTokIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+}
+
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
 
-	exp.Index = p.parseExpression(LOWEST)
+	if !p.peekTokIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -469,6 +632,23 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseMemberExpression desugars `h.name` into the index expression
+// `h["name"]`, keeping the dot token so the original form can be recovered.
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	tok := p.curTok
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	name := &ast.StringLiteral{
+		Token: token.Token{Type: token.STRING, Literal: p.curTok.Literal, Pos: p.curTok.Pos},
+		Value: p.curTok.Literal,
+	}
+
+	return &ast.IndexExpression{Token: tok, Left: left, Index: name}
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curTok}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -485,6 +665,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -494,6 +675,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curTok.Pos
 
 	return hash
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
+	"reflect"
 	"testing"
 )
 
@@ -848,6 +850,37 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"myArray[1:2]", "(myArray[1:2])"},
+		{"myArray[:2]", "(myArray[:2])"},
+		{"myArray[1:]", "(myArray[1:])"},
+		{"myArray[:]", "(myArray[:])"},
+		{"myArray[-2:a + 1]", "(myArray[(-2):(a + 1)])"},
+		{"s[1:][0]", "((s[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.SliceExpression); !ok && tt.input != "s[1:][0]" {
+			t.Fatalf("exp not *ast.SliceExpression. got = %T", stmt.Expression)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -981,3 +1014,330 @@ func TestFunctionLiteralWithName(t *testing.T) {
 		t.Fatalf("function literal name wrong. want 'myFunction', got=%q\n", function.Name)
 	}
 }
+
+func TestParsingHashLiteralKeyOrder(t *testing.T) {
+	input := `{"b": 1, "a": 2, 3: 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got = %T", stmt.Expression)
+	}
+
+	expected := []string{"b", "a", "3"}
+	if len(hash.Keys) != len(expected) {
+		t.Fatalf("hash.Keys has wrong length. got = %d", len(hash.Keys))
+	}
+
+	for i, key := range hash.Keys {
+		if key.String() != expected[i] {
+			t.Errorf("hash.Keys[%d] wrong. want = %q, got = %q", i, expected[i], key.String())
+		}
+	}
+
+	if hash.String() != `{b:1, a:2, 3:3}` {
+		t.Errorf("hash.String() wrong. got = %q", hash.String())
+	}
+}
+
+func TestParsingMemberExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"h.name", "(h[name])"},
+		{"h.a.b", "((h[a])[b])"},
+		{"h.f(1)", "(h[f])(1)"},
+		{"h.a + 1", "((h[a]) + 1)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New("h.name")).ParseProgram()
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	if _, ok := exp.Index.(*ast.StringLiteral); !ok {
+		t.Errorf("member index is not *ast.StringLiteral. got = %T", exp.Index)
+	}
+
+	p := New(lexer.New("h.1"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected an error for a member access without a name")
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	input := `throw err;`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program does not contain 1 statements. got = %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ThrowStatement. got = %T", program.Statements[0])
+	}
+
+	if stmt.TokenLiteral() != "throw" {
+		t.Errorf("stmt.TokenLiteral not 'throw', got = %q", stmt.TokenLiteral())
+	}
+
+	if !testLiteralExpression(t, stmt.Value, "err") {
+		return
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input      string
+		hasCatch   bool
+		hasFinally bool
+		expected   string
+	}{
+		{"try { x } catch (e) { y }", true, false, "try xcatch (e) y"},
+		{"try { x } finally { z }", false, true, "try xfinally z"},
+		{"try { x } catch (e) { y } finally { z }", true, true, "try xcatch (e) yfinally z"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got = %T", program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got = %T", stmt.Expression)
+		}
+
+		if (exp.Catch != nil) != tt.hasCatch {
+			t.Errorf("exp.Catch wrong. want present = %t, got = %v", tt.hasCatch, exp.Catch)
+		}
+
+		if tt.hasCatch && !testIdentifier(t, exp.Param, "e") {
+			return
+		}
+
+		if (exp.Finally != nil) != tt.hasFinally {
+			t.Errorf("exp.Finally wrong. want present = %t, got = %v", tt.hasFinally, exp.Finally)
+		}
+
+		if exp.String() != tt.expected {
+			t.Errorf("exp.String() wrong. want = %q, got = %q", tt.expected, exp.String())
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(1) { }", "expected next token to be IDENT, got INT instead"},
+		{"fn(x, \"y\") { }", "expected next token to be IDENT, got STRING instead"},
+		{"fn(x y) { }", "expected next token to be ), got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Message != tt.expected {
+			t.Errorf("wrong parser errors for %q. want first = %q, got = %q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { x }", "expected catch or finally after try block, got EOF instead"},
+		{"try { x } catch e { y }", "expected next token to be (, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Message != tt.expected {
+			t.Errorf("wrong parser errors for %q. want first = %q, got = %q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let = 5;
+let x 10;
+let y = 15;
+let z = (1 + ;
+if (y) { let = 1; y }
+let w = 2
+`
+
+	expected := []struct {
+		pos      token.Position
+		message  string
+		expected token.TokenType
+		hint     string
+	}{
+		{token.Position{Line: 1, Column: 5}, "expected next token to be IDENT, got = instead", token.IDENT, ""},
+		{token.Position{Line: 2, Column: 7}, "expected next token to be =, got INT instead", token.ASSIGN,
+			"let statements have the form `let <name> = <value>;`"},
+		{token.Position{Line: 4, Column: 14}, "no prefix parse function for ; found", "",
+			"an expression is missing before this token"},
+		{token.Position{Line: 5, Column: 14}, "expected next token to be IDENT, got = instead", token.IDENT, ""},
+	}
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. want = %d, got = %d (%v)", len(expected), len(errors), errors)
+	}
+
+	for i, want := range expected {
+		err := errors[i]
+		if err.Pos != want.pos || err.Message != want.message || err.Expected != want.expected || err.Hint != want.hint {
+			t.Errorf("errors[%d] wrong. want = %+v, got = %+v", i, want, *err)
+		}
+	}
+
+	for i, stmt := range program.Statements {
+		if stmt == nil || reflect.ValueOf(stmt).IsNil() {
+			t.Fatalf("program.Statements[%d] is nil", i)
+		}
+	}
+
+	if got := program.String(); got != "let y = 15;if y ylet w = 2;" {
+		t.Errorf("program.String() wrong. got = %q", got)
+	}
+}
+
+func TestErrorRecoveryInsideLiteral(t *testing.T) {
+	tests := []string{
+		`let h = {"a": 1, ) "b": 2};`,
+		`let a = [1, ) {"b": 2}]; let c = 3;`,
+		`if (x) { f({"a": ) }) }`,
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if errors := p.Errors(); len(errors) != 1 {
+			t.Errorf("%q: wrong number of errors. want = 1, got = %d (%v)", input, len(errors), errors)
+		}
+	}
+}
+
+func TestUnclosedBlock(t *testing.T) {
+	p := New(lexer.New("let f = fn(x) {\n  x + 1;\n"))
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. want = 1, got = %d (%v)", len(errors), errors)
+	}
+
+	if errors[0].Expected != token.RBRACE || errors[0].Hint != "the block opened at 1:15 is never closed" {
+		t.Errorf("wrong error. got = %+v", *errors[0])
+	}
+
+	if len(program.Statements) != 0 {
+		t.Errorf("program has %d statements, want 0", len(program.Statements))
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program does not contain 1 statements. got = %d\n", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got = %T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got = %T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got = %d\n", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got = %d\n", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got = %T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func FuzzParseProgram(f *testing.F) {
+	f.Add(`let add = fn add(x, y) { return x + y; }; add(1, 2 * 3)`)
+	f.Add(`if (a < b) { [1, 2][0:1] } else { {"a": fn() {}}["a"]() }`)
+	f.Add(`try { throw "x" } catch (e) { e.message }; macro(x) { quote(unquote(x)) }`)
+	f.Add(`fn(x y) { x }`)
+	f.Add(`let = ; ) ] }`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			_ = program.String()
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package profile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// WritePprof writes the profile to w in the gzipped protocol buffer format
+// of pprof. Each monkey function is a pprof function and each line a
+// location, so pprof shows the program's call stacks rather than the VM's.
+//
+// See https://github.com/google/pprof/blob/main/proto/profile.proto.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	strs := &stringTable{index: map[string]int{"": 0}, list: []string{""}}
+	var b protobuf
+
+	countType := "instructions"
+	if p.Sampling() {
+		countType = "samples"
+	}
+	valueType(&b, 1, strs.add(countType), strs.add("count"))
+	valueType(&b, 1, strs.add("wall"), strs.add("nanoseconds"))
+
+	locations := make(map[location]uint64)
+	functions := make(map[interface{}]uint64)
+	var locs, fns protobuf
+
+	locationID := func(loc location) uint64 {
+		if id, ok := locations[loc]; ok {
+			return id
+		}
+
+		fnID, ok := functions[loc.fn]
+		if !ok {
+			fnID = uint64(len(functions) + 1)
+			functions[loc.fn] = fnID
+
+			f := p.functions[loc.fn]
+			fns.message(5, func(b *protobuf) {
+				b.uint64(1, fnID)
+				b.int64(2, int64(strs.add(pprofName(f))))
+				b.int64(3, int64(strs.add(f.name)))
+				b.int64(4, int64(strs.add(f.pos.Filename)))
+				b.int64(5, int64(f.pos.Line))
+			})
+		}
+
+		id := uint64(len(locations) + 1)
+		locations[loc] = id
+
+		locs.message(4, func(b *protobuf) {
+			b.uint64(1, id)
+			b.message(4, func(b *protobuf) {
+				b.uint64(1, fnID)
+				b.int64(2, int64(loc.line))
+			})
+		})
+
+		return id
+	}
+
+	p.walk(p.root, func(n *node) {
+		if n.count == 0 {
+			return
+		}
+
+		var stack []uint64
+		for s := n; s != p.root; s = s.parent {
+			stack = append(stack, locationID(s.loc))
+		}
+
+		b.message(2, func(b *protobuf) {
+			b.packed(1, stack)
+			b.packed(2, []uint64{uint64(n.count), uint64(n.nanos)})
+		})
+	})
+
+	b.data = append(b.data, locs.data...)
+	b.data = append(b.data, fns.data...)
+	for _, s := range strs.list {
+		b.string(6, s)
+	}
+
+	b.int64(9, p.start.UnixNano())
+	b.int64(10, int64(p.duration))
+	valueType(&b, 11, strs.add("wall"), strs.add("nanoseconds"))
+	b.int64(12, int64(p.interval))
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b.data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// pprofName returns the name of f for pprof, which drops names in angle
+// brackets. Top-level code is named after its file, and anonymous functions
+// after where they start, with an @ so that no script function has the same
+// name.
+func pprofName(f *function) string {
+	switch f.name {
+	case "<main>":
+		return fmt.Sprintf("main@%s", f.pos.Filename)
+	case "<anonymous>":
+		return fmt.Sprintf("anonymous@%s:%d", f.pos.Filename, f.pos.Line)
+	}
+	return f.name
+}
+
+func valueType(b *protobuf, field int, typ, unit int) {
+	b.message(field, func(b *protobuf) {
+		b.int64(1, int64(typ))
+		b.int64(2, int64(unit))
+	})
+}
+
+// stringTable is the string table of a profile; messages refer to strings
+// by index.
+type stringTable struct {
+	index map[string]int
+	list  []string
+}
+
+func (t *stringTable) add(s string) int {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+
+	t.index[s] = len(t.list)
+	t.list = append(t.list, s)
+	return len(t.list) - 1
+}
+
+// protobuf encodes the fields of a protocol buffer message.
+type protobuf struct {
+	data []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protobuf) tag(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// uint64 writes a varint field, leaving it out if it is zero.
+func (b *protobuf) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.tag(field, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protobuf) packed(field int, xs []uint64) {
+	var inner protobuf
+	for _, x := range xs {
+		inner.varint(x)
+	}
+	b.bytes(field, inner.data)
+}
+
+func (b *protobuf) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *protobuf) message(field int, f func(b *protobuf)) {
+	var inner protobuf
+	f(&inner)
+	b.bytes(field, inner.data)
+}
+
+func (b *protobuf) bytes(field int, data []byte) {
+	b.tag(field, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
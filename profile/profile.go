@@ -0,0 +1,318 @@
+// Package profile measures where programs running on the VM spend their
+// time. A Profiler is installed as the VM's hook. It either counts and
+// times every instruction, or samples the call stack at an interval, and
+// reports the cost of each function and source line as text or as a pprof
+// profile for `go tool pprof`.
+package profile
+
+import (
+	"monkey/object"
+	"monkey/token"
+	"monkey/vm"
+	"sort"
+	"time"
+)
+
+// Profiler is a vm.Hook that records the call stacks a program executes
+// in a call tree.
+type Profiler struct {
+	// interval is the sampling interval, or 0 to record every instruction.
+	interval time.Duration
+
+	start    time.Time
+	duration time.Duration
+	stopped  bool
+
+	root      *node
+	functions map[*object.CompiledFunction]*function
+
+	// path holds the node of each active frame, outermost first.
+	path []*node
+
+	// last is the node of the previous instruction, which is charged the
+	// time until the next one starts.
+	last     *node
+	lastTime time.Time
+
+	// next is when the next sample is due, and skip the number of
+	// instructions until the clock is read again.
+	next time.Time
+	skip int
+}
+
+// clockEvery is how many instructions the sampling profiler runs between
+// reading the clock.
+const clockEvery = 64
+
+// location is a source line of a function.
+type location struct {
+	fn   *object.CompiledFunction
+	file string
+	line int
+}
+
+// node is a call stack in the call tree: the location being executed and
+// the node of the frame that called it.
+type node struct {
+	loc      location
+	parent   *node
+	children map[location]*node
+
+	count int64
+	nanos int64
+}
+
+func (n *node) child(loc location) *node {
+	if c, ok := n.children[loc]; ok {
+		return c
+	}
+
+	c := &node{loc: loc, parent: n, children: make(map[location]*node)}
+	n.children[loc] = c
+	return c
+}
+
+type function struct {
+	name string
+	pos  token.Position
+}
+
+// New returns a profiler that counts and times every instruction.
+func New() *Profiler {
+	return &Profiler{
+		start:     time.Now(),
+		root:      &node{children: make(map[location]*node)},
+		functions: make(map[*object.CompiledFunction]*function),
+	}
+}
+
+// NewSampling returns a profiler that records the call stack once every
+// interval. It slows the program down much less than New, at the cost of
+// precision.
+func NewSampling(interval time.Duration) *Profiler {
+	p := New()
+	p.interval = interval
+	p.next = p.start.Add(interval)
+	return p
+}
+
+// Before implements vm.Hook.
+func (p *Profiler) Before(machine *vm.VM, frame *vm.Frame) error {
+	if p.stopped {
+		return nil
+	}
+
+	if p.interval > 0 {
+		// Reading the clock for every instruction would cost more than
+		// recording one, so it is only read every so often.
+		p.skip--
+		if p.skip > 0 {
+			return nil
+		}
+		p.skip = clockEvery
+
+		now := time.Now()
+		if now.Before(p.next) {
+			return nil
+		}
+		n := int64(now.Sub(p.next)/p.interval) + 1
+		p.next = p.next.Add(time.Duration(n) * p.interval)
+
+		p.path = p.path[:0]
+		node := p.enter(machine.Frames())
+		node.count += n
+		node.nanos += n * int64(p.interval)
+
+		return nil
+	}
+
+	if p.last != nil {
+		p.last.nanos += int64(time.Since(p.lastTime))
+	}
+
+	p.last = p.enter(machine.Frames())
+	p.last.count++
+	p.lastTime = time.Now()
+
+	return nil
+}
+
+// enter returns the node of the current call stack. The nodes of the
+// outer frames are kept from the previous instruction, since their
+// locations only change once control returns to them.
+func (p *Profiler) enter(frames []*vm.Frame) *node {
+	depth := len(frames)
+	if len(p.path) > depth-1 {
+		p.path = p.path[:depth-1]
+	}
+
+	for i := len(p.path); i < depth; i++ {
+		parent := p.root
+		if i > 0 {
+			parent = p.path[i-1]
+		}
+		p.path = append(p.path, parent.child(p.locate(frames, i)))
+	}
+
+	return p.path[depth-1]
+}
+
+func (p *Profiler) locate(frames []*vm.Frame, i int) location {
+	fn := frames[i].Closure().Fn
+
+	if _, ok := p.functions[fn]; !ok {
+		f := &function{name: "<anonymous>"}
+		switch {
+		case i == 0:
+			f.name = "<main>"
+		case fn.Name != "":
+			f.name = fn.Name
+		}
+		if len(fn.SourceMap) > 0 {
+			f.pos = fn.SourceMap[0].Pos
+		}
+		p.functions[fn] = f
+	}
+
+	pos, _ := frames[i].Pos()
+	return location{fn: fn, file: pos.Filename, line: pos.Line}
+}
+
+// Stop ends the profile. Instructions executed afterwards are not
+// recorded.
+func (p *Profiler) Stop() {
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+	p.duration = time.Since(p.start)
+
+	if p.last != nil {
+		p.last.nanos += int64(time.Since(p.lastTime))
+	}
+}
+
+// Sampling reports whether the profile was sampled rather than exact.
+func (p *Profiler) Sampling() bool {
+	return p.interval > 0
+}
+
+// Cost is what a function or line took: the number of instructions, or of
+// samples when sampling, and the time.
+type Cost struct {
+	Count int64
+	Time  time.Duration
+}
+
+func (c *Cost) add(n *node) {
+	c.Count += n.count
+	c.Time += time.Duration(n.nanos)
+}
+
+// Stat is the cost of a function or a source line. Flat is the cost of
+// the function or line itself, and Cum includes the calls it made.
+type Stat struct {
+	// Name is the name of the function, or of the function the line is
+	// in.
+	Name string
+
+	// Pos is the position of the function or the line.
+	Pos token.Position
+
+	Flat Cost
+	Cum  Cost
+}
+
+// Total returns the cost of the whole profile.
+func (p *Profiler) Total() Cost {
+	var total Cost
+	p.walk(p.root, func(n *node) { total.add(n) })
+	return total
+}
+
+// Functions returns the cost of each function that was executed, most
+// expensive first.
+func (p *Profiler) Functions() []Stat {
+	return p.stats(func(loc location) interface{} { return loc.fn }, func(loc location) token.Position {
+		return p.functions[loc.fn].pos
+	})
+}
+
+// Lines returns the cost of each source line that was executed, most
+// expensive first.
+func (p *Profiler) Lines() []Stat {
+	return p.stats(func(loc location) interface{} { return loc }, func(loc location) token.Position {
+		return token.Position{Filename: loc.file, Line: loc.line}
+	})
+}
+
+// stats sums the cost of the call tree by the given key. A node's cost
+// counts towards the flat cost of its own key, and towards the cumulative
+// cost of every key on its stack, once even in recursive calls.
+func (p *Profiler) stats(key func(location) interface{}, pos func(location) token.Position) []Stat {
+	byKey := make(map[interface{}]*Stat)
+	onStack := make(map[interface{}]int)
+
+	var visit func(n *node) Cost
+	visit = func(n *node) Cost {
+		k := key(n.loc)
+		stat, ok := byKey[k]
+		if !ok {
+			stat = &Stat{Name: p.functions[n.loc.fn].name, Pos: pos(n.loc)}
+			byKey[k] = stat
+		}
+		stat.Flat.add(n)
+
+		onStack[k]++
+		cum := Cost{Count: n.count, Time: time.Duration(n.nanos)}
+		for _, c := range n.children {
+			sub := visit(c)
+			cum.Count += sub.Count
+			cum.Time += sub.Time
+		}
+		onStack[k]--
+
+		if onStack[k] == 0 {
+			stat.Cum.Count += cum.Count
+			stat.Cum.Time += cum.Time
+		}
+
+		return cum
+	}
+
+	for _, c := range p.root.children {
+		visit(c)
+	}
+
+	out := make([]Stat, 0, len(byKey))
+	for _, stat := range byKey {
+		out = append(out, *stat)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Flat.Time != b.Flat.Time {
+			return a.Flat.Time > b.Flat.Time
+		}
+		if a.Flat.Count != b.Flat.Count {
+			return a.Flat.Count > b.Flat.Count
+		}
+		if a.Cum.Count != b.Cum.Count {
+			return a.Cum.Count > b.Cum.Count
+		}
+		if a.Pos.Filename != b.Pos.Filename {
+			return a.Pos.Filename < b.Pos.Filename
+		}
+		return a.Pos.Before(b.Pos)
+	})
+
+	return out
+}
+
+// walk calls f for every node below n.
+func (p *Profiler) walk(n *node, f func(n *node)) {
+	for _, c := range n.children {
+		f(c)
+		p.walk(c, f)
+	}
+}
@@ -0,0 +1,291 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/vm"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `let f = fn(x) { x };
+let g = fn() {
+	f(1) + f(2)
+};
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+g();
+fn() { 1 }();
+fib(10);
+`
+
+// counter is a hook that counts instructions.
+type counter int64
+
+func (c *counter) Before(*vm.VM, *vm.Frame) error {
+	*c++
+	return nil
+}
+
+func run(t *testing.T, input string, hook vm.Hook) {
+	t.Helper()
+
+	p := parser.New(lexer.NewFile("main.mk", input))
+	parsed := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(parsed); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.SetHook(hook)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+}
+
+func find(t *testing.T, stats []Stat, name string, line int) Stat {
+	t.Helper()
+
+	for _, stat := range stats {
+		if stat.Name == name && stat.Pos.Line == line {
+			return stat
+		}
+	}
+
+	t.Fatalf("no stat for %s at line %d in %v", name, line, stats)
+	return Stat{}
+}
+
+func TestExact(t *testing.T) {
+	var instructions counter
+	run(t, program, &instructions)
+
+	p := New()
+	run(t, program, p)
+	p.Stop()
+
+	total := p.Total()
+	if total.Count != int64(instructions) {
+		t.Errorf("wrong total: got %d, want %d", total.Count, instructions)
+	}
+
+	functions := p.Functions()
+	if len(functions) != 5 {
+		t.Errorf("wrong number of functions: %v", functions)
+	}
+
+	main := find(t, functions, "<main>", 1)
+	if main.Cum != total {
+		t.Errorf("wrong cumulative cost of <main>: got %v, want %v", main.Cum, total)
+	}
+
+	// f runs OpGetLocal and OpReturnValue, twice.
+	f := find(t, functions, "f", 1)
+	if f.Flat.Count != 4 || f.Cum.Count != 4 {
+		t.Errorf("wrong cost of f: %+v", f)
+	}
+
+	g := find(t, functions, "g", 3)
+	if g.Cum.Count != g.Flat.Count+f.Cum.Count || g.Cum.Time < f.Cum.Time {
+		t.Errorf("wrong cost of g: %+v", g)
+	}
+
+	// Recursive calls are only counted once.
+	fib := find(t, functions, "fib", 5)
+	if fib.Cum != fib.Flat {
+		t.Errorf("wrong cost of fib: %+v", fib)
+	}
+
+	if anon := find(t, functions, "<anonymous>", 7); anon.Flat.Count != 2 {
+		t.Errorf("wrong cost of the anonymous function: %+v", anon)
+	}
+
+	lines := p.Lines()
+	line := find(t, lines, "g", 3)
+	if line.Flat != g.Flat || line.Cum != g.Cum || line.Pos.Filename != "main.mk" {
+		t.Errorf("wrong cost of line 3: %+v", line)
+	}
+
+	var sum int64
+	for _, line := range lines {
+		sum += line.Flat.Count
+	}
+	if sum != total.Count {
+		t.Errorf("wrong sum of lines: got %d, want %d", sum, total.Count)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	p := NewSampling(100 * time.Microsecond)
+	run(t, "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(20);", p)
+	p.Stop()
+
+	total := p.Total()
+	if total.Count == 0 {
+		t.Fatalf("no samples in %s", p.duration)
+	}
+	if total.Time != time.Duration(total.Count)*100*time.Microsecond {
+		t.Errorf("wrong time for %d samples: %s", total.Count, total.Time)
+	}
+
+	fib := find(t, p.Functions(), "fib", 1)
+	if fib.Cum.Count == 0 || fib.Cum.Count > total.Count {
+		t.Errorf("wrong samples of fib: %+v", fib)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	p := New()
+	run(t, program, p)
+	p.Stop()
+
+	var out strings.Builder
+	if err := p.WriteText(&out, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	text := out.String()
+	if !strings.Contains(text, " instructions in ") {
+		t.Errorf("no summary in report:\n%s", text)
+	}
+	if strings.Count(text, "main.mk") != 4 {
+		t.Errorf("report not limited to 2 functions and 2 lines:\n%s", text)
+	}
+	if !strings.Contains(text, "fib main.mk:5") || !strings.Contains(text, "main.mk:5 fib") {
+		t.Errorf("fib not in report:\n%s", text)
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	p := New()
+	run(t, program, p)
+	p.Stop()
+
+	fields := pprof(t, p)
+
+	strs := pprofStrings(t, fields)
+	for _, s := range []string{"instructions", "count", "wall", "nanoseconds", "main@main.mk", "f", "g", "fib", "anonymous@main.mk:7", "main.mk"} {
+		if !contains(strs, s) {
+			t.Errorf("%q not in string table %q", s, strs)
+		}
+	}
+
+	if len(fields[1]) != 2 || len(fields[5]) != 5 || len(fields[2]) == 0 || len(fields[4]) == 0 {
+		t.Errorf("wrong number of sample types, functions, samples or locations")
+	}
+
+	// Every sample has a stack and both values.
+	for _, sample := range fields[2] {
+		s := decode(t, sample)
+		if len(s[1]) != 1 || len(s[2]) != 1 || len(s[2][0]) < 2 {
+			t.Errorf("malformed sample %v", s)
+		}
+	}
+}
+
+func TestWritePprofMainFunction(t *testing.T) {
+	p := New()
+	run(t, "let main = fn() { 1 };\nmain();\n", p)
+	p.Stop()
+
+	strs := pprofStrings(t, pprof(t, p))
+	for _, s := range []string{"main", "main@main.mk"} {
+		if !contains(strs, s) {
+			t.Errorf("%q not in string table %q", s, strs)
+		}
+	}
+}
+
+// pprof returns the fields of the pprof profile written by p.
+func pprof(t *testing.T, p *Profiler) map[int][][]byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := p.WritePprof(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return decode(t, data)
+}
+
+// pprofStrings returns the string table of a pprof profile.
+func pprofStrings(t *testing.T, fields map[int][][]byte) []string {
+	t.Helper()
+
+	var strs []string
+	for _, s := range fields[6] {
+		strs = append(strs, string(s))
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("string table does not start with an empty string: %q", strs)
+	}
+	return strs
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// decode returns the length-delimited fields of a protocol buffer message
+// by field number, skipping varints.
+func decode(t *testing.T, data []byte) map[int][][]byte {
+	t.Helper()
+
+	varint := func() uint64 {
+		var x uint64
+		for shift := 0; ; shift += 7 {
+			if len(data) == 0 {
+				t.Fatal("truncated varint")
+			}
+			b := data[0]
+			data = data[1:]
+			x |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				return x
+			}
+		}
+	}
+
+	fields := make(map[int][][]byte)
+	for len(data) > 0 {
+		tag := varint()
+		switch tag & 7 {
+		case 0:
+			varint()
+		case 2:
+			n := varint()
+			if uint64(len(data)) < n {
+				t.Fatal("truncated field")
+			}
+			fields[int(tag>>3)] = append(fields[int(tag>>3)], data[:n])
+			data = data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,58 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteText writes a report of the top most expensive functions and
+// lines to w, or of all of them if top is 0.
+func (p *Profiler) WriteText(w io.Writer, top int) error {
+	total := p.Total()
+
+	unit := "instrs"
+	if p.Sampling() {
+		unit = "samples"
+		fmt.Fprintf(w, "%d samples every %s over %s\n", total.Count, p.interval, ms(p.duration))
+	} else {
+		fmt.Fprintf(w, "%d instructions in %s\n", total.Count, ms(total.Time))
+	}
+
+	fmt.Fprintf(w, "\n%10s %6s %10s %6s %10s  %s\n", "flat", "flat%", "cum", "cum%", unit, "function")
+	for i, stat := range p.Functions() {
+		if top > 0 && i == top {
+			break
+		}
+		writeRow(w, stat, total, fmt.Sprintf("%s %s", stat.Name, stat.Pos))
+	}
+
+	fmt.Fprintf(w, "\n%10s %6s %10s %6s %10s  %s\n", "flat", "flat%", "cum", "cum%", unit, "line")
+	for i, stat := range p.Lines() {
+		if top > 0 && i == top {
+			break
+		}
+		writeRow(w, stat, total, fmt.Sprintf("%s:%d %s", stat.Pos.Filename, stat.Pos.Line, stat.Name))
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func writeRow(w io.Writer, stat Stat, total Cost, what string) {
+	fmt.Fprintf(w, "%10s %6s %10s %6s %10d  %s\n",
+		ms(stat.Flat.Time), percent(stat.Flat.Time, total.Time),
+		ms(stat.Cum.Time), percent(stat.Cum.Time, total.Time),
+		stat.Flat.Count, what)
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+func percent(d, total time.Duration) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(total))
+}
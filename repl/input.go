@@ -0,0 +1,104 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+const CONTINUATION_PROMPT = ".. "
+
+// PASTE_END ends the input of :paste mode, on a line of its own.
+const PASTE_END = ":end"
+
+// reader reads the inputs of the REPL. An input continues over several
+// lines, with the continuation prompt, until its delimiters balance and it
+// parses without running out of tokens; an empty line ends it anyway. The
+// :paste command reads every line up to PASTE_END as one input.
+type reader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func newReader(in io.Reader, out io.Writer) *reader {
+	return &reader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// read returns the next input, or false at the end of the input.
+func (r *reader) read() (string, bool) {
+	fmt.Fprint(r.out, PROMPT)
+	if !r.scanner.Scan() {
+		return "", false
+	}
+
+	line := r.scanner.Text()
+	if strings.TrimSpace(line) == ":paste" {
+		return r.paste(), true
+	}
+
+	lines := []string{line}
+	for incomplete(strings.Join(lines, "\n")) {
+		fmt.Fprint(r.out, CONTINUATION_PROMPT)
+		if !r.scanner.Scan() {
+			break
+		}
+
+		line := r.scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n"), true
+}
+
+func (r *reader) paste() string {
+	fmt.Fprintf(r.out, "// paste mode, end with %s on a line of its own\n", PASTE_END)
+
+	var lines []string
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if strings.TrimSpace(line) == PASTE_END {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// incomplete reports whether src needs more lines: it leaves parentheses,
+// brackets or braces open, or the parser reaches its end while it still
+// expects more.
+func incomplete(src string) bool {
+	l := lexer.New(src)
+
+	depth := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+		}
+	}
+	if depth != 0 {
+		// Too many closing delimiters are an error more lines won't fix.
+		return depth > 0
+	}
+
+	p := parser.New(lexer.New(src))
+	p.ParseProgram()
+	for _, err := range p.Errors() {
+		if err.Found.Type == token.EOF {
+			return true
+		}
+	}
+
+	return false
+}
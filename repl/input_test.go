@@ -0,0 +1,76 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"", false},
+		{"1 + 2", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n a + b\n}", false},
+		{"[1, 2,", true},
+		{"puts(1,\n2", true},
+		{"let x =", true},
+		{"1 +", true},
+		{"if (x) { 1 } else", true},
+		{"1 + 2)", false},
+		{"let = 5", false},
+		{"}", false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.incomplete {
+			t.Errorf("incomplete(%q) = %t, want %t", tt.input, got, tt.incomplete)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	in := strings.Join([]string{
+		"let add = fn(a, b) {",
+		"  a + b",
+		"};",
+		"add(1,",
+		"",
+		":paste",
+		"let x = 1",
+		"x",
+		":end",
+		"2",
+	}, "\n")
+
+	var out bytes.Buffer
+	r := newReader(strings.NewReader(in), &out)
+
+	expected := []string{
+		"let add = fn(a, b) {\n  a + b\n};",
+		"add(1,",
+		"let x = 1\nx",
+		"2",
+	}
+	for _, want := range expected {
+		got, ok := r.read()
+		if !ok {
+			t.Fatalf("input ended, want %q", want)
+		}
+		if got != want {
+			t.Errorf("wrong input. want = %q, got = %q", want, got)
+		}
+	}
+
+	if got, ok := r.read(); ok {
+		t.Errorf("input did not end, got %q", got)
+	}
+
+	prompts := ">> .. .. >> .. >> // paste mode, end with :end on a line of its own\n>> >> "
+	if out.String() != prompts {
+		t.Errorf("wrong prompts. want = %q, got = %q", prompts, out.String())
+	}
+}
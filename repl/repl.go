@@ -1,14 +1,15 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"monkey/compiler"
+	"monkey/diag"
 	"monkey/eval"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/stdlib"
 	"monkey/vm"
 )
 
@@ -27,9 +28,8 @@ const MONKEY_FACE = `           __,__
 `
 
 func StartCompiler(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	input := newReader(in, out)
 
-	constants := []object.Object{}
 	globals := make([]object.Object, vm.GlobalSize)
 
 	symbolTable := compiler.NewSymbolTable()
@@ -37,25 +37,42 @@ func StartCompiler(in io.Reader, out io.Writer) {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
 
+	constants, err := stdlib.Compile(symbolTable, globals)
+	if err != nil {
+		fmt.Fprintf(out, "Woops! Loading the standard library failed:\n %s\n", err)
+		return
+	}
+
+	renderer := newRenderer(out)
+	macroEnv := object.NewEnvironment()
+
 	for {
-		fmt.Fprint(out, PROMPT)
-		if scanned := scanner.Scan(); !scanned {
+		src, ok := input.read()
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		l := lexer.New(src)
 		p := parser.New(l)
 
+		renderer.Sources[""] = src
+
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParseErrors(out, p.Errors())
+			printParseErrors(out, renderer, p.Errors())
+			continue
+		}
+
+		eval.DefineMacros(program, macroEnv)
+		program, err := eval.ExpandMacros(program, macroEnv)
+		if err != nil {
+			renderer.Render(out, diag.FromError(err))
 			continue
 		}
 
 		comp := compiler.NewWithState(symbolTable, constants)
 		if err := comp.Compile(program); err != nil {
-			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
+			renderer.Render(out, diag.FromError(err))
 			continue
 		}
 
@@ -65,46 +82,72 @@ func StartCompiler(in io.Reader, out io.Writer) {
 		machine := vm.NewWithGlobalStore(code, globals)
 
 		if err := machine.Run(); err != nil {
-			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s \n", err)
+			renderer.Render(out, diag.FromError(err))
 			continue
 		}
 
-		lastPopped := machine.LastPoppedStackElem()
-		fmt.Fprintln(out, lastPopped.Inspect())
+		// A program of only macro definitions leaves nothing behind.
+		if lastPopped := machine.LastPoppedStackElem(); lastPopped != nil {
+			fmt.Fprintln(out, lastPopped.Inspect())
+		}
 	}
 }
 
 func StartInterpreter(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	input := newReader(in, out)
 	env := object.NewEnvironment()
 
+	if err := stdlib.Eval(env); err != nil {
+		fmt.Fprintf(out, "Woops! Loading the standard library failed:\n %s\n", err)
+		return
+	}
+
+	renderer := newRenderer(out)
+	macroEnv := object.NewEnvironment()
+
 	for {
-		fmt.Fprint(out, PROMPT)
-		if scanned := scanner.Scan(); !scanned {
+		src, ok := input.read()
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		l := lexer.New(src)
 		p := parser.New(l)
 
+		renderer.Sources[""] = src
+
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParseErrors(out, p.Errors())
+			printParseErrors(out, renderer, p.Errors())
+			continue
+		}
+
+		eval.DefineMacros(program, macroEnv)
+		program, err := eval.ExpandMacros(program, macroEnv)
+		if err != nil {
+			renderer.Render(out, diag.FromError(err))
 			continue
 		}
 
-		evaluated := eval.Eval(program, env)
+		evaluated, err := eval.Run(program, env)
+		if err != nil {
+			renderer.Render(out, diag.FromError(err))
+			continue
+		}
 		if evaluated != nil {
 			fmt.Fprintln(out, evaluated.Inspect())
 		}
 	}
 }
 
-func printParseErrors(out io.Writer, errors []string) {
-	fmt.Fprintln(out, "Woops! We ran into some monkey business here!")
-	fmt.Fprintln(out, " parse errors:")
-	for _, msg := range errors {
-		fmt.Fprintln(out, "\t", msg)
+// newRenderer returns a renderer that knows the standard library sources.
+// The current input is stored under the empty file name.
+func newRenderer(out io.Writer) *diag.Renderer {
+	return &diag.Renderer{Sources: stdlib.Sources(), Color: diag.ColorEnabled(out)}
+}
+
+func printParseErrors(out io.Writer, renderer *diag.Renderer, errors []*parser.Error) {
+	for _, err := range errors {
+		renderer.Render(out, diag.FromError(err))
 	}
 }
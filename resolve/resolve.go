@@ -0,0 +1,211 @@
+// Package resolve binds the names of a program to their definitions with
+// the compiler's symbol tables, so that tools such as the linter and the
+// language server see the same scopes as compiled code.
+package resolve
+
+import (
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/object"
+)
+
+// Kinds of definitions.
+const (
+	Let   = "let"
+	Param = "parameter"
+	Catch = "catch"
+)
+
+// Definition is a name bound by a let statement, a parameter or a catch.
+type Definition struct {
+	Name *ast.Identifier
+	Kind string
+
+	// Value is the bound expression of a let statement.
+	Value ast.Expression
+
+	// Func is the function or macro literal the definition is local to,
+	// or nil for globals.
+	Func ast.Node
+
+	// Block is the catch block a catch parameter is limited to.
+	Block *ast.BlockStatement
+}
+
+// Use is an identifier that resolves to a symbol.
+type Use struct {
+	Ident  *ast.Identifier
+	Symbol compiler.Symbol
+
+	// Def is the definition the identifier refers to, or nil for builtins
+	// and for globals defined outside the program.
+	Def *Definition
+}
+
+// Result holds the definitions and uses of a program, in source order.
+type Result struct {
+	Defs []*Definition
+	Uses []*Use
+
+	uses map[*ast.Identifier]*Use
+}
+
+// Lookup returns what ident resolves to, if it is a use of a name.
+func (r *Result) Lookup(ident *ast.Identifier) (*Use, bool) {
+	use, ok := r.uses[ident]
+	return use, ok
+}
+
+// Program resolves the names of program. The builtins are defined before
+// it, and then globals, such as those of the standard library.
+func Program(program *ast.Program, globals ...string) *Result {
+	table := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		table.DefineBuiltin(i, v.Name)
+	}
+	for _, name := range globals {
+		table.Define(name)
+	}
+
+	r := &resolver{
+		result: &Result{uses: make(map[*ast.Identifier]*Use)},
+		scope:  &scope{table: table, defs: make(map[string]*Definition)},
+	}
+	ast.Walk(r, program)
+
+	return r.result
+}
+
+type scope struct {
+	table *compiler.SymbolTable
+	outer *scope
+	defs  map[string]*Definition
+
+	// fn is the function the scope belongs to and name its name, by which
+	// it can refer to itself, and self the let statement the function is
+	// bound to, if any.
+	fn   ast.Node
+	name string
+	self *Definition
+}
+
+type resolver struct {
+	result *Result
+	scope  *scope
+}
+
+// Visit handles the nodes that bind names, and lets Walk go through the
+// others.
+func (r *resolver) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		r.use(node)
+
+	case *ast.LetStatement:
+		// The compiler defines the name before compiling the value.
+		def := r.define(node.Name, Let, node.Value)
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok && fn.Name == node.Name.Value {
+			r.function(fn, fn.Name, fn.Parameters, fn.Body, def)
+		} else {
+			ast.Walk(r, node.Value)
+		}
+		return nil
+
+	case *ast.FunctionLiteral:
+		r.function(node, node.Name, node.Parameters, node.Body, nil)
+		return nil
+
+	case *ast.MacroLiteral:
+		r.function(node, "", node.Parameters, node.Body, nil)
+		return nil
+
+	case *ast.TryExpression:
+		ast.Walk(r, node.Block)
+		if node.Catch != nil {
+			r.catch(node.Param, node.Catch)
+		}
+		if node.Finally != nil {
+			ast.Walk(r, node.Finally)
+		}
+		return nil
+	}
+
+	return r
+}
+
+func (r *resolver) define(name *ast.Identifier, kind string, value ast.Expression) *Definition {
+	def := &Definition{Name: name, Kind: kind, Value: value, Func: r.scope.fn}
+
+	r.scope.table.Define(name.Value)
+	r.scope.defs[name.Value] = def
+	r.result.Defs = append(r.result.Defs, def)
+
+	return def
+}
+
+// catch resolves a catch block, where the parameter hides what its name
+// refers to outside.
+func (r *resolver) catch(param *ast.Identifier, block *ast.BlockStatement) {
+	outer, shadows := r.scope.defs[param.Value]
+	_, restore := r.scope.table.DefineBlock(param.Value)
+
+	def := &Definition{Name: param, Kind: Catch, Func: r.scope.fn, Block: block}
+	r.scope.defs[param.Value] = def
+	r.result.Defs = append(r.result.Defs, def)
+
+	ast.Walk(r, block)
+
+	restore()
+	if shadows {
+		r.scope.defs[param.Value] = outer
+	} else {
+		delete(r.scope.defs, param.Value)
+	}
+}
+
+func (r *resolver) use(ident *ast.Identifier) {
+	sym, ok := r.scope.table.Resolve(ident.Value)
+	if !ok {
+		return
+	}
+
+	use := &Use{Ident: ident, Symbol: sym}
+	if sym.Scope != compiler.BuiltinScope {
+		for s := r.scope; s != nil; s = s.outer {
+			if def, ok := s.defs[ident.Value]; ok {
+				use.Def = def
+				break
+			}
+			if s.name == ident.Value {
+				use.Def = s.self
+				break
+			}
+		}
+	}
+
+	r.result.Uses = append(r.result.Uses, use)
+	r.result.uses[ident] = use
+}
+
+func (r *resolver) function(fn ast.Node, name string, params []*ast.Identifier, body *ast.BlockStatement, self *Definition) {
+	r.scope = &scope{
+		table: compiler.NewEnclosedSymbolTable(r.scope.table),
+		outer: r.scope,
+		defs:  make(map[string]*Definition),
+		fn:    fn,
+		name:  name,
+		self:  self,
+	}
+
+	if name != "" {
+		r.scope.table.DefineFunctionName(name)
+	}
+
+	for _, param := range params {
+		r.define(param, Param, nil)
+	}
+
+	ast.Walk(r, body)
+
+	r.scope = r.scope.outer
+}
@@ -0,0 +1,53 @@
+package resolve
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestProgram(t *testing.T) {
+	input := `let x = 1;
+let f = fn(a) { let x = a; f(x) };
+let g = fn() { x };
+try { len(lib) } catch (e) { e };
+missing; e;`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+
+	names := Program(program, "lib")
+
+	expectedDefs := []string{"let x 1:5", "let f 2:5", "parameter a 2:12", "let x 2:21", "let g 3:5", "catch e 4:25"}
+	if len(names.Defs) != len(expectedDefs) {
+		t.Fatalf("wrong number of definitions. want=%d, got=%d", len(expectedDefs), len(names.Defs))
+	}
+	for i, def := range names.Defs {
+		if got := def.Kind + " " + def.Name.Value + " " + def.Name.Pos().String(); got != expectedDefs[i] {
+			t.Errorf("wrong definition %d. want=%q, got=%q", i, expectedDefs[i], got)
+		}
+	}
+
+	// Uses name the definition they refer to by its position, or the
+	// scope of the symbol when there is none.
+	expectedUses := []string{"a 2:25 -> 2:12", "f 2:28 -> 2:5", "x 2:30 -> 2:21", "x 3:16 -> 1:5", "len 4:7 -> BUILTIN", "lib 4:11 -> GLOBAL", "e 4:30 -> 4:25"}
+	if len(names.Uses) != len(expectedUses) {
+		t.Fatalf("wrong number of uses. want=%d, got=%d", len(expectedUses), len(names.Uses))
+	}
+	for i, use := range names.Uses {
+		target := string(use.Symbol.Scope)
+		if use.Def != nil {
+			target = use.Def.Name.Pos().String()
+		}
+		if got := use.Ident.Value + " " + use.Ident.Pos().String() + " -> " + target; got != expectedUses[i] {
+			t.Errorf("wrong use %d. want=%q, got=%q", i, expectedUses[i], got)
+		}
+
+		if found, ok := names.Lookup(use.Ident); !ok || found != use {
+			t.Errorf("Lookup(%s) did not find use %d", use.Ident.Value, i)
+		}
+	}
+}
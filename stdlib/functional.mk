@@ -0,0 +1,24 @@
+// Functional utilities.
+
+// identity returns x unchanged.
+let identity = fn(x) { x };
+
+// constant returns a function that always returns x.
+let constant = fn(x) { fn(y) { x } };
+
+// compose returns a function computing f(g(x)).
+let compose = fn(f, g) { fn(x) { f(g(x)) } };
+
+// pipe returns a function applying every function of fns in turn.
+let pipe = fn(fns) {
+	fn(x) { reduce(fns, x, fn(acc, f) { f(acc) }) }
+};
+
+// partial binds the first argument of the two-argument function f.
+let partial = fn(f, a) { fn(b) { f(a, b) } };
+
+// flip swaps the arguments of the two-argument function f.
+let flip = fn(f) { fn(a, b) { f(b, a) } };
+
+// times returns the results of calling f with 0 through n - 1.
+let times = fn(n, f) { map(range(0, n), f) };
@@ -0,0 +1,15 @@
+// Hash utilities.
+
+// size returns the number of pairs in h.
+let size = fn(h) { len(keys(h)) };
+
+// isEmpty reports whether the array or hash x has no elements.
+let isEmpty = fn(x) { !toBool(x) };
+
+// get returns h[k], or fallback when h has no key k.
+let get = fn(h, k, fallback) {
+	if (has(h, k)) { h[k] } else { fallback }
+};
+
+// pluck returns the value stored under key in every hash of arr.
+let pluck = fn(arr, key) { map(arr, fn(h) { h[key] }) };
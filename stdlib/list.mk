@@ -0,0 +1,173 @@
+// List utilities.
+
+// range returns the integers from start (inclusive) to end (exclusive).
+let range = fn(start, end) {
+	let iter = fn(i, acc) {
+		if (i >= end) {
+			return acc;
+		}
+		iter(i + 1, push(acc, i))
+	};
+	iter(start, [])
+};
+
+// map returns a new array with f applied to every element of arr.
+let map = fn(arr, f) {
+	let iter = fn(arr, acc) {
+		if (len(arr) == 0) {
+			return acc;
+		}
+		iter(rest(arr), push(acc, f(first(arr))))
+	};
+	iter(arr, [])
+};
+
+// filter returns the elements of arr for which f is truthy.
+let filter = fn(arr, f) {
+	let iter = fn(arr, acc) {
+		if (len(arr) == 0) {
+			return acc;
+		}
+		if (f(first(arr))) {
+			iter(rest(arr), push(acc, first(arr)))
+		} else {
+			iter(rest(arr), acc)
+		}
+	};
+	iter(arr, [])
+};
+
+// reduce folds arr from the left, starting with initial.
+let reduce = fn(arr, initial, f) {
+	let iter = fn(arr, acc) {
+		if (len(arr) == 0) {
+			return acc;
+		}
+		iter(rest(arr), f(acc, first(arr)))
+	};
+	iter(arr, initial)
+};
+
+// forEach calls f with every element of arr.
+let forEach = fn(arr, f) {
+	if (len(arr) > 0) {
+		f(first(arr));
+		forEach(rest(arr), f)
+	}
+};
+
+// concat returns the elements of a followed by the elements of b.
+let concat = fn(a, b) {
+	reduce(b, a, fn(acc, x) { push(acc, x) })
+};
+
+// flatten concatenates an array of arrays into a single array.
+let flatten = fn(arr) {
+	reduce(arr, [], concat)
+};
+
+// reverse returns the elements of arr in reverse order.
+let reverse = fn(arr) {
+	let iter = fn(i, acc) {
+		if (i < 0) {
+			return acc;
+		}
+		iter(i - 1, push(acc, arr[i]))
+	};
+	iter(len(arr) - 1, [])
+};
+
+// find returns the first element for which f is truthy, or null.
+let find = fn(arr, f) {
+	if (len(arr) > 0) {
+		if (f(first(arr))) {
+			first(arr)
+		} else {
+			find(rest(arr), f)
+		}
+	}
+};
+
+// any reports whether f is truthy for at least one element of arr.
+let any = fn(arr, f) {
+	if (len(arr) == 0) {
+		return false;
+	}
+	if (f(first(arr))) {
+		return true;
+	}
+	any(rest(arr), f)
+};
+
+// all reports whether f is truthy for every element of arr.
+let all = fn(arr, f) {
+	if (len(arr) == 0) {
+		return true;
+	}
+	if (f(first(arr))) {
+		return all(rest(arr), f);
+	}
+	false
+};
+
+// take returns the first n elements of arr.
+let take = fn(arr, n) {
+	let iter = fn(arr, n, acc) {
+		if (n <= 0) {
+			return acc;
+		}
+		if (len(arr) == 0) {
+			return acc;
+		}
+		iter(rest(arr), n - 1, push(acc, first(arr)))
+	};
+	iter(arr, n, [])
+};
+
+// drop returns arr without its first n elements.
+let drop = fn(arr, n) {
+	if (n <= 0) {
+		return arr;
+	}
+	if (len(arr) == 0) {
+		return arr;
+	}
+	drop(rest(arr), n - 1)
+};
+
+// zip pairs up the elements of a and b, stopping at the shorter array.
+let zip = fn(a, b) {
+	let iter = fn(a, b, acc) {
+		if (len(a) == 0) {
+			return acc;
+		}
+		if (len(b) == 0) {
+			return acc;
+		}
+		iter(rest(a), rest(b), push(acc, [first(a), first(b)]))
+	};
+	iter(a, b, [])
+};
+
+// sum returns the sum of an array of integers.
+let sum = fn(arr) {
+	reduce(arr, 0, fn(acc, x) { acc + x })
+};
+
+// min returns the smallest element of a non-empty array of integers.
+let min = fn(arr) {
+	reduce(
+		rest(arr),
+		first(arr),
+		fn(acc, x) { if (x < acc) { x } else { acc } }
+	)
+};
+
+// max returns the largest element of a non-empty array of integers.
+let max = fn(arr) {
+	reduce(
+		rest(arr),
+		first(arr),
+		fn(acc, x) { if (x > acc) { x } else { acc } }
+	)
+};
@@ -0,0 +1,119 @@
+package stdlib
+
+import (
+	"embed"
+	"fmt"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"strings"
+)
+
+//go:embed *.mk
+var sources embed.FS
+
+// Files lists the standard library sources in load order. Later files may
+// use definitions from earlier ones, since the compiler resolves globals at
+// compile time.
+var Files = []string{
+	"list.mk",
+	"functional.mk",
+	"hash.mk",
+}
+
+func Source() string {
+	var out strings.Builder
+
+	for _, name := range Files {
+		out.WriteString(readFile(name))
+		out.WriteString("\n")
+	}
+
+	return out.String()
+}
+
+// Sources returns the text of every standard library file, keyed by the
+// file name used in the positions of its tokens.
+func Sources() map[string]string {
+	files := make(map[string]string, len(Files))
+	for _, name := range Files {
+		files[filename(name)] = readFile(name)
+	}
+
+	return files
+}
+
+func filename(name string) string {
+	return "stdlib/" + name
+}
+
+func readFile(name string) string {
+	src, err := sources.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return string(src)
+}
+
+func Program() (*ast.Program, error) {
+	program := &ast.Program{Statements: []ast.Statement{}}
+
+	for _, name := range Files {
+		p := parser.New(lexer.NewFile(filename(name), readFile(name)))
+
+		file := p.ParseProgram()
+		if errs := p.Errors(); len(errs) != 0 {
+			msgs := make([]string, len(errs))
+			for i, err := range errs {
+				msgs[i] = err.Error()
+			}
+			return nil, fmt.Errorf("stdlib: parse errors: %s", strings.Join(msgs, "; "))
+		}
+
+		program.Statements = append(program.Statements, file.Statements...)
+	}
+
+	return program, nil
+}
+
+// Eval loads the standard library into env.
+func Eval(env *object.Environment) error {
+	program, err := Program()
+	if err != nil {
+		return err
+	}
+
+	if _, err := eval.Run(program, env); err != nil {
+		return fmt.Errorf("stdlib: %w", err)
+	}
+
+	return nil
+}
+
+// Compile defines the standard library in symbolTable and runs it against
+// globals, returning the constants the compiled code refers to.
+func Compile(symbolTable *compiler.SymbolTable, globals []object.Object) ([]object.Object, error) {
+	program, err := Program()
+	if err != nil {
+		return nil, err
+	}
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("stdlib: %w", err)
+	}
+
+	bytecode := comp.Bytecode()
+
+	machine := vm.NewWithGlobalStore(bytecode, globals)
+	if err := machine.Run(); err != nil {
+		return nil, fmt.Errorf("stdlib: %w", err)
+	}
+
+	return bytecode.Constants, nil
+}
@@ -0,0 +1,152 @@
+package stdlib
+
+import (
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// Every file in tests/ is a program whose value is an array of
+// [actual, expected] pairs; a pair passes when both sides inspect equally.
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+func runEval(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	env := object.NewEnvironment()
+	if err := Eval(env); err != nil {
+		t.Fatalf("loading stdlib failed: %s", err)
+	}
+
+	return eval.Eval(parse(t, input), env)
+}
+
+func runVm(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	globals := make([]object.Object, vm.GlobalSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	constants, err := Compile(symbolTable, globals)
+	if err != nil {
+		t.Fatalf("loading stdlib failed: %s", err)
+	}
+
+	comp := compiler.NewWithState(symbolTable, constants)
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.NewWithGlobalStore(comp.Bytecode(), globals)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+func testPairs(t *testing.T, result object.Object) {
+	t.Helper()
+
+	pairs, ok := result.(*object.Array)
+	if !ok {
+		t.Fatalf("result is not Array. got = %T (%+v)", result, result)
+	}
+
+	for i, elem := range pairs.Elements {
+		pair, ok := elem.(*object.Array)
+		if !ok || len(pair.Elements) != 2 {
+			t.Errorf("case %d is not an [actual, expected] pair. got = %s", i, elem.Inspect())
+			continue
+		}
+
+		actual, expected := pair.Elements[0].Inspect(), pair.Elements[1].Inspect()
+		if actual != expected {
+			t.Errorf("case %d wrong. want = %s, got = %s", i, expected, actual)
+		}
+	}
+}
+
+func testFiles(t *testing.T) map[string]string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join("tests", "*.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(path)] = string(src)
+	}
+
+	return files
+}
+
+func TestStdlib(t *testing.T) {
+	for name, src := range testFiles(t) {
+		t.Run(name+"/eval", func(t *testing.T) {
+			testPairs(t, runEval(t, src))
+		})
+
+		t.Run(name+"/vm", func(t *testing.T) {
+			testPairs(t, runVm(t, src))
+		})
+	}
+}
+
+func TestEveryFunctionIsTested(t *testing.T) {
+	program, err := Program()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := testFiles(t)
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+
+		used := regexp.MustCompile(`\b` + let.Name.Value + `\(`)
+
+		tested := false
+		for _, src := range files {
+			if used.MatchString(src) {
+				tested = true
+				break
+			}
+		}
+
+		if !tested {
+			t.Errorf("stdlib function %s has no test in tests/", let.Name.Value)
+		}
+	}
+}
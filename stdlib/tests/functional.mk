@@ -0,0 +1,13 @@
+let inc = fn(x) { x + 1 };
+let double = fn(x) { x * 2 };
+let sub = fn(a, b) { a - b };
+
+[
+	[identity(5), 5],
+	[constant(7)(1), 7],
+	[compose(inc, double)(5), 11],
+	[pipe([inc, double])(5), 12],
+	[partial(sub, 10)(3), 7],
+	[flip(sub)(10, 3), -7],
+	[times(3, double), [0, 2, 4]]
+]
@@ -0,0 +1,12 @@
+let h = {"a": 1, "b": false};
+
+[
+	[size(h), 2],
+	[size({}), 0],
+	[isEmpty({}), true],
+	[isEmpty([1]), false],
+	[get(h, "a", 0), 1],
+	[get(h, "b", 2), false],
+	[get(h, "c", 3), 3],
+	[pluck([{"x": 1}, {"x": 2}], "x"), [1, 2]]
+]
@@ -0,0 +1,29 @@
+let double = fn(x) { x * 2 };
+let isEven = fn(x) { (x / 2) * 2 == x };
+
+[
+	[range(0, 5), [0, 1, 2, 3, 4]],
+	[range(3, 3), []],
+	[map([1, 2, 3], double), [2, 4, 6]],
+	[map([], double), []],
+	[filter([1, 2, 3, 4], isEven), [2, 4]],
+	[reduce([1, 2, 3], 10, fn(acc, x) { acc + x }), 16],
+	[forEach([1, 2], fn(x) { x }), if (false) { 0 }],
+	[concat([1, 2], [3]), [1, 2, 3]],
+	[flatten([[1], [], [2, 3]]), [1, 2, 3]],
+	[reverse([1, 2, 3]), [3, 2, 1]],
+	[find([1, 2, 3, 4], isEven), 2],
+	[find([1, 3], isEven), if (false) { 0 }],
+	[any([1, 3, 4], isEven), true],
+	[any([1, 3], isEven), false],
+	[all([2, 4], isEven), true],
+	[all([2, 3], isEven), false],
+	[take([1, 2, 3], 2), [1, 2]],
+	[take([1], 5), [1]],
+	[drop([1, 2, 3], 2), [3]],
+	[drop([1], 5), []],
+	[zip([1, 2, 3], [4, 5]), [[1, 4], [2, 5]]],
+	[sum([1, 2, 3]), 6],
+	[min([3, 1, 2]), 1],
+	[max([3, 1, 2]), 3]
+]
@@ -0,0 +1,44 @@
+package trace
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"strings"
+)
+
+// Eval traces the interpreter. Install it with eval.SetTracer.
+type Eval struct {
+	w     *Writer
+	stack []string
+}
+
+func NewEval(w *Writer) *Eval {
+	return &Eval{w: w, stack: []string{"<main>"}}
+}
+
+// Call implements eval.Tracer.
+func (t *Eval) Call(fn *object.Function) {
+	t.stack = append(t.stack, functionName(fn.Name))
+}
+
+// Return implements eval.Tracer.
+func (t *Eval) Return(fn *object.Function) {
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// Trace implements eval.Tracer.
+func (t *Eval) Trace(node ast.Node, result object.Object) {
+	if !t.w.traced(t.stack) {
+		return
+	}
+
+	t.w.write(&Step{
+		Engine:   "eval",
+		Pos:      node.Pos().String(),
+		Depth:    len(t.stack) - 1,
+		Function: t.stack[len(t.stack)-1],
+		Node:     strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."),
+		Result:   describe(result),
+	})
+}
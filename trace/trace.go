@@ -0,0 +1,130 @@
+// Package trace logs every step a program takes in either engine: each
+// node the interpreter evaluates, with its result, and each instruction
+// the VM executes, with the top of the stack after it. Traces are written
+// as text or as JSON Lines, so that the traces of the two engines for the
+// same program can be compared.
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/object"
+	"strings"
+)
+
+// Step is one line of a trace. Depth is the number of calls in progress
+// and Function the name of the innermost one; the program itself is
+// "<main>".
+type Step struct {
+	Engine   string `json:"engine"`
+	Pos      string `json:"pos"`
+	Depth    int    `json:"depth"`
+	Function string `json:"function"`
+
+	// Node and Result are set by the interpreter.
+	Node   string `json:"node,omitempty"`
+	Result string `json:"result,omitempty"`
+
+	// Op, Operands and Top are set by the VM.
+	Op       string `json:"op,omitempty"`
+	Operands []int  `json:"operands,omitempty"`
+	Top      string `json:"top,omitempty"`
+}
+
+// Writer writes the steps of a trace.
+type Writer struct {
+	// JSON selects JSON Lines output instead of text.
+	JSON bool
+
+	// Functions, if not empty, limits the trace to calls of the functions
+	// with these names, including the calls they make.
+	Functions []string
+
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err returns the first error writing the trace.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// traced reports whether steps with the given calls in progress, outermost
+// first, pass the function filter.
+func (w *Writer) traced(stack []string) bool {
+	if len(w.Functions) == 0 {
+		return true
+	}
+
+	for _, name := range stack {
+		for _, f := range w.Functions {
+			if name == f {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (w *Writer) write(step *Step) {
+	if w.err != nil {
+		return
+	}
+
+	if w.JSON {
+		enc := json.NewEncoder(w.w)
+		enc.SetEscapeHTML(false)
+		w.err = enc.Encode(step)
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s%s: ", step.Pos, strings.Repeat("  ", step.Depth), step.Function)
+
+	if step.Engine == "vm" {
+		b.WriteString(step.Op)
+		for _, operand := range step.Operands {
+			fmt.Fprintf(&b, " %d", operand)
+		}
+		if step.Top != "" {
+			fmt.Fprintf(&b, " => %s", step.Top)
+		}
+	} else {
+		b.WriteString(step.Node)
+		if step.Result != "" {
+			fmt.Fprintf(&b, " => %s", step.Result)
+		}
+	}
+
+	b.WriteByte('\n')
+	_, w.err = io.WriteString(w.w, b.String())
+}
+
+// describe returns the form of a value in a trace.
+func describe(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return ""
+	case *object.CompiledFunction:
+		return (&object.Closure{Fn: obj}).Inspect()
+	case *object.ReturnValue:
+		return describe(obj.Value)
+	case *object.ThrownValue:
+		return "throw " + describe(obj.Value)
+	}
+
+	return obj.Inspect()
+}
+
+func functionName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
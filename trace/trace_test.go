@@ -0,0 +1,130 @@
+package trace
+
+import (
+	"encoding/json"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"strings"
+	"testing"
+)
+
+const program = `let add = fn(a, b) { a + b };
+let twice = fn(x) { add(x, x) };
+let r = twice(2);
+fn() { r }();
+`
+
+func parse(t *testing.T) *parser.Parser {
+	t.Helper()
+	return parser.New(lexer.NewFile("main.mk", program))
+}
+
+func traceEval(t *testing.T, w *Writer) {
+	t.Helper()
+
+	p := parse(t)
+	parsed := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+
+	env := object.NewEnvironment()
+	eval.SetTracer(env, NewEval(w))
+
+	if _, err := eval.Run(parsed, env); err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+}
+
+func traceVM(t *testing.T, w *Writer) {
+	t.Helper()
+
+	p := parse(t)
+	parsed := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(parsed); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	tracer := NewVM(w)
+	machine.SetHook(tracer)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	tracer.Flush(machine)
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		run      func(*testing.T, *Writer)
+		expected []string
+	}{
+		{traceEval, []string{
+			"main.mk:1:11: <main>: FunctionLiteral => fn add(a, b) { ... }",
+			"main.mk:1:1: <main>: LetStatement\n",
+			"main.mk:1:24:     add: InfixExpression => 4",
+			"main.mk:2:24:   twice: CallExpression => 4",
+			"main.mk:4:8:   <anonymous>: Identifier => 4",
+		}},
+		{traceVM, []string{
+			"main.mk:1:11: <main>: OpClosure 0 0 => fn add(a, b) { ... }",
+			"main.mk:1:1: <main>: OpSetGlobal 0\n",
+			"main.mk:1:24:     add: OpAdd => 4",
+			"main.mk:2:24:   twice: OpCall 2 => 2",
+			"main.mk:4:8:   <anonymous>: OpGetGlobal 2 => 4",
+			"main.mk:4:1: <main>: OpPop\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		tt.run(t, NewWriter(&out))
+
+		for _, line := range tt.expected {
+			if !strings.Contains(out.String(), line) {
+				t.Errorf("%q not in trace:\n%s", line, out.String())
+			}
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	for _, run := range []func(*testing.T, *Writer){traceEval, traceVM} {
+		var out strings.Builder
+		w := NewWriter(&out)
+		w.JSON = true
+		w.Functions = []string{"twice"}
+		run(t, w)
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		functions := make(map[string]bool)
+		for _, line := range lines {
+			var step Step
+			if err := json.Unmarshal([]byte(line), &step); err != nil {
+				t.Fatalf("invalid JSON %q: %s", line, err)
+			}
+
+			functions[step.Function] = true
+			if step.Depth < 1 || !strings.HasPrefix(step.Pos, "main.mk:") {
+				t.Errorf("wrong step %+v", step)
+			}
+			if step.Engine == "vm" && step.Op == "" || step.Engine == "eval" && step.Node == "" {
+				t.Errorf("wrong step %+v", step)
+			}
+		}
+
+		// Calls made by twice are traced too.
+		if len(functions) != 2 || !functions["twice"] || !functions["add"] {
+			t.Errorf("wrong functions traced: %v", functions)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"monkey/code"
+	"monkey/vm"
+)
+
+// VM traces the VM. Install it with SetHook, and call Flush when Run
+// returns.
+type VM struct {
+	w *Writer
+
+	// pending is the step of the instruction being executed, which is
+	// written with the top of the stack once it has run.
+	pending *Step
+}
+
+func NewVM(w *Writer) *VM {
+	return &VM{w: w}
+}
+
+// Before implements vm.Hook.
+func (t *VM) Before(machine *vm.VM, frame *vm.Frame) error {
+	t.Flush(machine)
+
+	frames := machine.Frames()
+	if len(t.w.Functions) > 0 {
+		stack := make([]string, len(frames))
+		for i := range frames {
+			stack[i] = frameName(frames, i)
+		}
+		if !t.w.traced(stack) {
+			return nil
+		}
+	}
+
+	ins := frame.Instructions()
+	ip := frame.IP()
+
+	step := &Step{
+		Engine:   "vm",
+		Depth:    len(frames) - 1,
+		Function: frameName(frames, len(frames)-1),
+	}
+	if pos, ok := frame.Pos(); ok {
+		step.Pos = pos.String()
+	}
+
+	def, err := code.Lookup(ins[ip])
+	if err != nil {
+		step.Op = err.Error()
+	} else {
+		step.Op = def.Name
+		step.Operands, _ = code.ReadOperands(def, ins[ip+1:])
+		if len(step.Operands) == 0 {
+			step.Operands = nil
+		}
+	}
+
+	t.pending = step
+	return nil
+}
+
+// Flush writes the step of the last instruction executed.
+func (t *VM) Flush(machine *vm.VM) {
+	if t.pending == nil {
+		return
+	}
+
+	t.pending.Top = describe(machine.StackTop())
+	t.w.write(t.pending)
+	t.pending = nil
+}
+
+func frameName(frames []*vm.Frame, i int) string {
+	if i == 0 {
+		return "<main>"
+	}
+	return functionName(frames[i].Closure().Fn.Name)
+}
@@ -3,6 +3,7 @@ package vm
 import (
 	"monkey/code"
 	"monkey/object"
+	"monkey/token"
 )
 
 type Frame struct {
@@ -18,6 +19,21 @@ func NewFrame(cl *object.Closure, bp int) *Frame {
 		bp: bp,
 	}
 }
+
 func (f *Frame) Instructions() code.Instructions {
 	return f.cl.Fn.Instructions
 }
+
+func (f *Frame) Closure() *object.Closure {
+	return f.cl
+}
+
+// IP returns the offset of the instruction being executed.
+func (f *Frame) IP() int {
+	return f.ip
+}
+
+// Pos returns the source position of the instruction being executed.
+func (f *Frame) Pos() (token.Position, bool) {
+	return f.cl.Fn.SourceMap.Lookup(f.ip)
+}
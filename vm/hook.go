@@ -0,0 +1,48 @@
+package vm
+
+import "monkey/object"
+
+// Hook is called before every instruction the VM executes. frame is the
+// current frame, with its ip at the instruction about to run. If Before
+// returns an error, the VM stops and Run returns it; try expressions in the
+// program cannot catch it.
+type Hook interface {
+	Before(vm *VM, frame *Frame) error
+}
+
+// stopError carries an error that stops the VM out of run, past the
+// handlers of try expressions: one returned by the hook, or the
+// *object.ExitError of a program that called exit.
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string {
+	return e.err.Error()
+}
+
+// SetHook installs h, or removes the hook if h is nil.
+func (vm *VM) SetHook(h Hook) {
+	vm.hook = h
+}
+
+// Frames returns the active frames, outermost first.
+func (vm *VM) Frames() []*Frame {
+	return vm.frames[:vm.framesIdx]
+}
+
+// Stack returns the values on the stack, bottom first.
+func (vm *VM) Stack() []object.Object {
+	return vm.stack[:vm.sp]
+}
+
+// Locals returns the local variables of frame, indexed by slot. Slots that
+// have not been assigned yet hold nil.
+func (vm *VM) Locals(frame *Frame) []object.Object {
+	return vm.stack[frame.bp : frame.bp+frame.cl.Fn.NumLocals]
+}
+
+// Globals returns the global store.
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
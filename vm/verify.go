@@ -0,0 +1,259 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+)
+
+// Verify checks bytecode before it runs, so that malformed instructions
+// are reported instead of making the VM panic. Besides what code.Verify
+// checks, every instruction must find the values it uses on the stack,
+// with the same stack depth on every path to it, refer to constants,
+// locals, builtins and free variables that exist, and only end try blocks
+// that it is in. Functions must return instead of running past their end.
+func Verify(bytecode *compiler.Bytecode) error {
+	main := &object.CompiledFunction{Instructions: bytecode.Instructions}
+
+	v := &verifier{constants: bytecode.Constants, free: make(map[*object.CompiledFunction]int)}
+	if err := v.decode(main); err != nil {
+		return fmt.Errorf("main program: %w", err)
+	}
+	for i, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			if err := v.decode(fn); err != nil {
+				return fmt.Errorf("%s: %w", describeConstant(fn, i), err)
+			}
+		}
+	}
+
+	if err := v.verify(main, true); err != nil {
+		return fmt.Errorf("main program: %w", err)
+	}
+	for i, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			if err := v.verify(fn, false); err != nil {
+				return fmt.Errorf("%s: %w", describeConstant(fn, i), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func describeConstant(fn *object.CompiledFunction, index int) string {
+	if fn.Name == "" {
+		return fmt.Sprintf("function at constant %d", index)
+	}
+	return fmt.Sprintf("function %s at constant %d", fn.Name, index)
+}
+
+type verifier struct {
+	constants []object.Object
+
+	// free holds how many free variables each function reads, which its
+	// closures must be created with.
+	free map[*object.CompiledFunction]int
+}
+
+// decode checks the instructions of fn with code.Verify and records the
+// free variables it reads.
+func (v *verifier) decode(fn *object.CompiledFunction) error {
+	if err := code.Verify(fn.Instructions); err != nil {
+		return err
+	}
+
+	ins := fn.Instructions
+	for i := 0; i < len(ins); {
+		def, _ := code.Lookup(ins[i])
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		if code.Opcode(ins[i]) == code.OpGetFree && operands[0]+1 > v.free[fn] {
+			v.free[fn] = operands[0] + 1
+		}
+
+		i += 1 + read
+	}
+
+	return nil
+}
+
+// state is what the VM holds at an instruction: the depth of the stack
+// above the locals of the frame, and the try blocks entered in the frame.
+type state struct {
+	depth int
+	tries int
+}
+
+// verify follows every path through fn, which has been decoded, from its
+// first instruction.
+func (v *verifier) verify(fn *object.CompiledFunction, main bool) error {
+	if fn.NumParams > fn.NumLocals {
+		return fmt.Errorf("%d parameters but only %d locals", fn.NumParams, fn.NumLocals)
+	}
+
+	ins := fn.Instructions
+	states := make(map[int]state)
+	work := []int{0}
+	states[0] = state{}
+
+	flow := func(from, to int, s state) error {
+		if seen, ok := states[to]; ok {
+			if seen != s {
+				return &code.VerifyError{Offset: from, Message: fmt.Sprintf("reaches %04d with stack depth %d and %d try blocks, but another path has %d and %d", to, s.depth, s.tries, seen.depth, seen.tries)}
+			}
+			return nil
+		}
+
+		states[to] = s
+		work = append(work, to)
+		return nil
+	}
+
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+		s := states[i]
+
+		if i == len(ins) {
+			if !main {
+				return &code.VerifyError{Offset: i, Message: "function ends without returning"}
+			}
+			continue
+		}
+
+		op := code.Opcode(ins[i])
+		def, _ := code.Lookup(ins[i])
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		next := i + 1 + read
+
+		fail := func(format string, a ...interface{}) error {
+			return &code.VerifyError{Offset: i, Message: def.Name + " " + fmt.Sprintf(format, a...)}
+		}
+
+		if err := v.check(fn, main, op, operands, fail); err != nil {
+			return err
+		}
+
+		pops, pushes := stackEffect(op, operands)
+		if s.depth < pops {
+			return fail("needs %d values on the stack, but there are %d", pops, s.depth)
+		}
+		after := state{depth: s.depth - pops + pushes, tries: s.tries}
+
+		var err error
+		switch op {
+		case code.OpJump:
+			err = flow(i, operands[0], after)
+
+		case code.OpJumpNotTruthy:
+			if err = flow(i, operands[0], after); err == nil {
+				err = flow(i, next, after)
+			}
+
+		case code.OpTry:
+			// A thrown value arrives at the handler on top of the stack
+			// as it was when the try block was entered.
+			if err = flow(i, operands[0], state{depth: s.depth + 1, tries: s.tries}); err == nil {
+				after.tries++
+				err = flow(i, next, after)
+			}
+
+		case code.OpEndTry:
+			if s.tries == 0 {
+				return fail("is not in a try block")
+			}
+			after.tries--
+			err = flow(i, next, after)
+
+		case code.OpReturnValue, code.OpReturn, code.OpThrow:
+			// Execution does not continue in this frame.
+
+		default:
+			err = flow(i, next, after)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// check reports operands that refer to things that do not exist.
+func (v *verifier) check(fn *object.CompiledFunction, main bool, op code.Opcode, operands []int, fail func(string, ...interface{}) error) error {
+	switch op {
+	case code.OpConstant:
+		if operands[0] >= len(v.constants) {
+			return fail("refers to constant %d, but there are %d", operands[0], len(v.constants))
+		}
+
+	case code.OpClosure:
+		if operands[0] >= len(v.constants) {
+			return fail("refers to constant %d, but there are %d", operands[0], len(v.constants))
+		}
+		closed, ok := v.constants[operands[0]].(*object.CompiledFunction)
+		if !ok {
+			return fail("refers to constant %d, which is %s, not a function", operands[0], v.constants[operands[0]].Type())
+		}
+		if operands[1] < v.free[closed] {
+			return fail("closes over %d values, but the function reads %d free variables", operands[1], v.free[closed])
+		}
+
+	case code.OpGetLocal, code.OpSetLocal:
+		if operands[0] >= fn.NumLocals {
+			return fail("refers to local %d, but there are %d", operands[0], fn.NumLocals)
+		}
+
+	case code.OpGetFree:
+		if main {
+			return fail("is in the main program, which has no free variables")
+		}
+
+	case code.OpGetBuiltin:
+		if operands[0] >= len(object.Builtins) {
+			return fail("refers to builtin %d, but there are %d", operands[0], len(object.Builtins))
+		}
+
+	case code.OpHash:
+		if operands[0]%2 != 0 {
+			return fail("has an odd number of keys and values, %d", operands[0])
+		}
+
+	case code.OpReturn:
+		if main {
+			return fail("is in the main program")
+		}
+	}
+
+	return nil
+}
+
+// stackEffect returns how many values the instruction pops off the stack
+// and how many it pushes.
+func stackEffect(op code.Opcode, operands []int) (pops, pushes int) {
+	switch op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull, code.OpGetGlobal, code.OpGetLocal,
+		code.OpGetBuiltin, code.OpGetFree, code.OpCurrentClosure:
+		return 0, 1
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpEqual, code.OpNotEqual,
+		code.OpGreaterEqual, code.OpGreaterThan, code.OpLessEqual, code.OpLessThan, code.OpIndex:
+		return 2, 1
+	case code.OpMinus, code.OpBang:
+		return 1, 1
+	case code.OpPop, code.OpJumpNotTruthy, code.OpSetGlobal, code.OpSetLocal, code.OpReturnValue, code.OpThrow:
+		return 1, 0
+	case code.OpArray, code.OpHash:
+		return operands[0], 1
+	case code.OpSlice:
+		return 3, 1
+	case code.OpCall:
+		return operands[0] + 1, 1
+	case code.OpClosure:
+		return operands[1], 1
+	default:
+		// OpJump, OpReturn, OpTry and OpEndTry.
+		return 0, 0
+	}
+}
@@ -0,0 +1,156 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+	"testing"
+)
+
+func instructions(ins ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, i := range ins {
+		out = append(out, i...)
+	}
+	return out
+}
+
+func TestVerify(t *testing.T) {
+	add := &object.CompiledFunction{
+		Name:         "add",
+		NumLocals:    2,
+		NumParams:    2,
+		Instructions: instructions(code.Make(code.OpGetLocal, 0), code.Make(code.OpGetLocal, 1), code.Make(code.OpAdd), code.Make(code.OpReturnValue)),
+	}
+	adder := &object.CompiledFunction{
+		NumLocals:    1,
+		NumParams:    1,
+		Instructions: instructions(code.Make(code.OpGetFree, 0), code.Make(code.OpGetLocal, 0), code.Make(code.OpAdd), code.Make(code.OpReturnValue)),
+	}
+	constants := []object.Object{&object.Integer{Value: 1}, add, adder}
+
+	tests := []struct {
+		main      code.Instructions
+		constants []object.Object
+		expected  string
+	}{
+		{
+			instructions(code.Make(code.OpClosure, 1, 0), code.Make(code.OpConstant, 0), code.Make(code.OpConstant, 0), code.Make(code.OpCall, 2), code.Make(code.OpPop)),
+			constants,
+			"",
+		},
+		{
+			instructions(code.Make(code.OpTry, 8), code.Make(code.OpNull), code.Make(code.OpEndTry), code.Make(code.OpJump, 10), code.Make(code.OpPop), code.Make(code.OpPop)),
+			constants,
+			"main program: 0009: OpPop needs 1 values on the stack, but there are 0",
+		},
+		{
+			instructions(code.Make(code.OpAdd)),
+			constants,
+			"main program: 0000: OpAdd needs 2 values on the stack, but there are 0",
+		},
+		{
+			instructions(code.Make(code.OpConstant, 3)),
+			constants,
+			"main program: 0000: OpConstant refers to constant 3, but there are 3",
+		},
+		{
+			instructions(code.Make(code.OpClosure, 0, 0)),
+			constants,
+			"main program: 0000: OpClosure refers to constant 0, which is INTEGER, not a function",
+		},
+		{
+			instructions(code.Make(code.OpClosure, 2, 0), code.Make(code.OpPop)),
+			constants,
+			"main program: 0000: OpClosure closes over 0 values, but the function reads 1 free variables",
+		},
+		{
+			instructions(code.Make(code.OpGetLocal, 0), code.Make(code.OpPop)),
+			constants,
+			"main program: 0000: OpGetLocal refers to local 0, but there are 0",
+		},
+		{
+			instructions(code.Make(code.OpGetBuiltin, 255), code.Make(code.OpPop)),
+			constants,
+			fmt.Sprintf("main program: 0000: OpGetBuiltin refers to builtin 255, but there are %d", len(object.Builtins)),
+		},
+		{
+			instructions(code.Make(code.OpEndTry)),
+			constants,
+			"main program: 0000: OpEndTry is not in a try block",
+		},
+		{
+			instructions(code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 8), code.Make(code.OpNull), code.Make(code.OpJump, 8), code.Make(code.OpPop)),
+			constants,
+			"main program: 0005: reaches 0008 with stack depth 1 and 0 try blocks, but another path has 0 and 0",
+		},
+		{
+			instructions(code.Make(code.OpNull), code.Make(code.OpNull), code.Make(code.OpHash, 1)),
+			constants,
+			"main program: 0002: OpHash has an odd number of keys and values, 1",
+		},
+		{
+			instructions(code.Make(code.OpReturn)),
+			constants,
+			"main program: 0000: OpReturn is in the main program",
+		},
+		{
+			instructions(),
+			[]object.Object{&object.CompiledFunction{Name: "f", Instructions: instructions(code.Make(code.OpNull), code.Make(code.OpPop))}},
+			"function f at constant 0: 0002: function ends without returning",
+		},
+		{
+			instructions(),
+			[]object.Object{&object.CompiledFunction{NumParams: 1, Instructions: instructions(code.Make(code.OpReturn))}},
+			"function at constant 0: 1 parameters but only 0 locals",
+		},
+		{
+			instructions(),
+			[]object.Object{&object.CompiledFunction{Instructions: instructions(code.Make(code.OpJump, 7))}},
+			"function at constant 0: 0000: OpJump to 0007, which is not the start of an instruction",
+		},
+	}
+
+	for _, tt := range tests {
+		err := Verify(&compiler.Bytecode{Instructions: tt.main, Constants: tt.constants})
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.expected {
+			t.Errorf("wrong error for\n%s\nwant = %q, got = %q", tt.main, tt.expected, got)
+		}
+	}
+}
+
+// FuzzVerify runs bytecode that passes Verify, which must end with a
+// result or an error rather than a panic.
+func FuzzVerify(f *testing.F) {
+	f.Add(
+		[]byte(instructions(code.Make(code.OpClosure, 1, 0), code.Make(code.OpConstant, 0), code.Make(code.OpCall, 1), code.Make(code.OpPop))),
+		[]byte(instructions(code.Make(code.OpGetLocal, 0), code.Make(code.OpConstant, 0), code.Make(code.OpDiv), code.Make(code.OpReturnValue))),
+	)
+	f.Add(
+		[]byte(instructions(code.Make(code.OpTry, 16), code.Make(code.OpClosure, 1, 0), code.Make(code.OpConstant, 0), code.Make(code.OpCall, 1), code.Make(code.OpEndTry), code.Make(code.OpJump, 16), code.Make(code.OpPop))),
+		[]byte(instructions(code.Make(code.OpCurrentClosure), code.Make(code.OpThrow))),
+	)
+
+	f.Fuzz(func(t *testing.T, main, body []byte) {
+		fn := &object.CompiledFunction{Instructions: body, NumLocals: 2, NumParams: 1}
+		bytecode := &compiler.Bytecode{
+			Instructions: main,
+			Constants:    []object.Object{&object.Integer{Value: 0}, fn},
+		}
+		if Verify(bytecode) != nil {
+			return
+		}
+
+		limit := stepLimit(10000)
+		vm := New(bytecode)
+		vm.SetHook(&limit)
+		discard(vm.Session())
+		vm.Run()
+	})
+}
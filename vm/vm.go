@@ -5,6 +5,7 @@ import (
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/object"
+	"monkey/token"
 )
 
 const (
@@ -23,10 +24,28 @@ type VM struct {
 
 	frames    []*Frame
 	framesIdx int
+
+	handlers []handler
+
+	hook Hook
+
+	session *object.Session
+
+	// globalNames holds the names of the globals by slot, for locals()
+	// and globals().
+	globalNames []string
+}
+
+// handler is pushed by OpTry and records where execution continues, and
+// how much of the stack and which frames to keep, when a value is thrown.
+type handler struct {
+	ip        int
+	sp        int
+	framesIdx int
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
@@ -40,9 +59,19 @@ func New(bytecode *compiler.Bytecode) *VM {
 		globals:   make([]object.Object, GlobalSize),
 		frames:    frames,
 		framesIdx: 1,
+
+		session: &object.Session{},
+
+		globalNames: bytecode.GlobalNames,
 	}
 }
 
+// Session returns the session of the run, through which builtins write
+// output and exit.
+func (vm *VM) Session() *object.Session {
+	return vm.session
+}
+
 func NewWithGlobalStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
 	vm := New(bytecode)
 	vm.globals = s
@@ -61,7 +90,26 @@ func (vm *VM) LastPoppedStackElem() object.Object {
 	return vm.stack[vm.sp]
 }
 
+// Run executes the bytecode. Errors and thrown values unwind to the nearest
+// handler installed by a try expression; if there is none, Run returns them.
 func (vm *VM) Run() error {
+	for {
+		err := vm.run()
+		if err == nil {
+			return nil
+		}
+
+		if stop, ok := err.(*stopError); ok {
+			return stop.err
+		}
+
+		if err := vm.throw(err); err != nil {
+			return err
+		}
+	}
+}
+
+func (vm *VM) run() error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
@@ -69,6 +117,12 @@ func (vm *VM) Run() error {
 	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
 		vm.currentFrame().ip++
 
+		if vm.hook != nil {
+			if err := vm.hook.Before(vm, vm.currentFrame()); err != nil {
+				return &stopError{err: err}
+			}
+		}
+
 		ip = vm.currentFrame().ip
 		ins = vm.currentFrame().Instructions()
 
@@ -100,7 +154,7 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterEqual, code.OpGreaterThan, code.OpLessEqual, code.OpLessThan:
 			if err := vm.executeComparison(op); err != nil {
 				return err
 			}
@@ -143,7 +197,12 @@ func (vm *VM) Run() error {
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
 
-			if err := vm.push(vm.globals[globalIndex]); err != nil {
+			value := vm.globals[globalIndex]
+			if value == nil {
+				return unassigned(vm.globalNames, int(globalIndex))
+			}
+
+			if err := vm.push(value); err != nil {
 				return err
 			}
 
@@ -181,6 +240,15 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeSliceExpression(left, start, end); err != nil {
+				return err
+			}
+
 		case code.OpCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
@@ -192,8 +260,14 @@ func (vm *VM) Run() error {
 		case code.OpReturnValue:
 			retVal := vm.pop()
 
+			// A return outside of functions ends the program.
+			if vm.framesIdx == 1 {
+				return nil
+			}
+
 			frame := vm.popFrame()
 			vm.sp = frame.bp - 1
+			vm.dropHandlers()
 
 			if err := vm.push(retVal); err != nil {
 				return err
@@ -202,6 +276,7 @@ func (vm *VM) Run() error {
 		case code.OpReturn:
 			frame := vm.popFrame()
 			vm.sp = frame.bp - 1
+			vm.dropHandlers()
 
 			if err := vm.push(object.NULL); err != nil {
 				return err
@@ -220,7 +295,12 @@ func (vm *VM) Run() error {
 			vm.currentFrame().ip++
 
 			frame := vm.currentFrame()
-			if err := vm.push(vm.stack[frame.bp+int(localIndex)]); err != nil {
+			value := vm.stack[frame.bp+int(localIndex)]
+			if value == nil {
+				return unassigned(frame.cl.Fn.LocalNames, int(localIndex))
+			}
+
+			if err := vm.push(value); err != nil {
 				return err
 			}
 
@@ -259,15 +339,95 @@ func (vm *VM) Run() error {
 			if err := vm.push(currentClosure); err != nil {
 				return err
 			}
+
+		case code.OpTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.handlers = append(vm.handlers, handler{ip: pos, sp: vm.sp, framesIdx: vm.framesIdx})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpThrow:
+			return &thrown{value: vm.pop()}
 		}
 	}
 
 	return nil
 }
 
+// unassigned reports a variable read in its own definition, such as
+// `let x = x`, which the compiler resolves before it is assigned.
+func unassigned(names []string, slot int) error {
+	name := fmt.Sprintf("#%d", slot)
+	if slot < len(names) {
+		name = names[slot]
+	}
+	return newError(object.NAME_ERROR, "identifier not found: %s", name)
+}
+
+// thrown carries a value thrown by OpThrow out of run.
+type thrown struct {
+	value object.Object
+}
+
+func (t *thrown) Error() string {
+	return object.Uncaught(t.value, token.Position{}).Message
+}
+
+func newError(kind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
+
+// throw unwinds to the innermost handler and pushes the thrown value for
+// the catch block. It returns the error as uncaught if there is no handler.
+func (vm *VM) throw(err error) error {
+	var value object.Object
+	switch err := err.(type) {
+	case *thrown:
+		value = err.value
+	case *object.Error:
+		value = err
+	default:
+		value = &object.Error{Message: err.Error(), Kind: object.GENERIC_ERROR}
+	}
+
+	pos := vm.position()
+	if e, ok := value.(*object.Error); ok && !e.Pos.IsValid() {
+		e.Pos = pos
+	}
+
+	if len(vm.handlers) == 0 {
+		return object.Uncaught(value, pos)
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.framesIdx = h.framesIdx
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.ip - 1
+
+	return vm.push(value)
+}
+
+// position returns the source position of the instruction being executed.
+func (vm *VM) position() token.Position {
+	pos, _ := vm.currentFrame().Pos()
+	return pos
+}
+
+// dropHandlers removes the handlers of frames that have returned.
+func (vm *VM) dropHandlers() {
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIdx > vm.framesIdx {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+}
+
 func (vm *VM) push(o object.Object) error {
 	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+		return newError(object.GENERIC_ERROR, "stack overflow")
 	}
 
 	vm.stack[vm.sp] = o
@@ -297,7 +457,30 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 		return vm.executeBinaryStringOperation(op, left, right)
 	}
 
-	return fmt.Errorf("unsupported types for binary operation: %s %s", leftType, rightType)
+	return operatorError(op, left, right)
+}
+
+// operators holds the symbols of the binary operators, for errors.
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterEqual: ">=",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpLessThan:     "<",
+}
+
+// operatorError reports a binary operator applied to operands it does not
+// support, as the interpreter does.
+func operatorError(op code.Opcode, left, right object.Object) error {
+	if left.Type() != right.Type() {
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+	return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
 }
 
 func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
@@ -317,10 +500,13 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 		result = leftValue * rightValue
 
 	case code.OpDiv:
+		if rightValue == 0 {
+			return newError(object.GENERIC_ERROR, "division by zero")
+		}
 		result = leftValue / rightValue
 
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return operatorError(op, left, right)
 	}
 	return vm.push(&object.Integer{Value: result})
 
@@ -328,7 +514,7 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 
 func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
 	if op != code.OpAdd {
-		return fmt.Errorf("unknown string operator: %d", op)
+		return operatorError(op, left, right)
 	}
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
@@ -343,14 +529,17 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 		return vm.executeIntegerComparison(op, left, right)
 	}
 
+	if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+		return vm.executeStringComparison(op, left, right)
+	}
+
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(right == left))
+		return vm.push(nativeBoolToBooleanObject(object.Equal(left, right)))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(right != left))
+		return vm.push(nativeBoolToBooleanObject(!object.Equal(left, right)))
 	default:
-		return fmt.Errorf("unknown operator: %d (%s %s)",
-			op, left.Type(), right.Type())
+		return operatorError(op, left, right)
 	}
 }
 
@@ -367,8 +556,34 @@ func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object
 		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
 	case code.OpGreaterThan:
 		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
 	default:
-		return fmt.Errorf("unknown operator: %d", op)
+		return operatorError(op, left, right)
+	}
+}
+
+func (vm *VM) executeStringComparison(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	default:
+		return operatorError(op, left, right)
 	}
 }
 
@@ -390,7 +605,7 @@ func (vm *VM) executeBangOperator() error {
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 	if operand.Type() != object.INTEGER_OBJ {
-		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", operand.Type())
 	}
 	value := operand.(*object.Integer).Value
 	return vm.push(&object.Integer{Value: -value})
@@ -407,33 +622,35 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 }
 
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
-	hashedPairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
 	for i := startIndex; i < endIndex; i += 2 {
 		key := vm.stack[i]
 		value := vm.stack[i+1]
 
-		pair := object.HashPair{Key: key, Value: value}
-
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
-			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			return nil, newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
 		}
 
-		hashedPairs[hashKey.HashKey()] = pair
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: hashedPairs}, nil
+	return hash, nil
 }
 
 func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeStringIndex(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == object.ERROR_OBJ:
+		return vm.push(left.(*object.Error).Field(index))
 	default:
-		return fmt.Errorf("index operator not supported: %s", left.Type())
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", left.Type())
 	}
 }
 
@@ -450,15 +667,90 @@ func (vm *VM) executeArrayIndex(array, index object.Object) error {
 	return vm.push(arrayObject.Elements[i])
 }
 
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	runes := []rune(str.(*object.String).Value)
+	i := index.(*object.Integer).Value
+
+	max := int64(len(runes) - 1)
+
+	if i < 0 || i > max {
+		return vm.push(object.NULL)
+	}
+
+	return vm.push(&object.String{Value: string(runes[i])})
+}
+
+func (vm *VM) executeSliceExpression(left, start, end object.Object) error {
+	var length int
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		length = len([]rune(left.Value))
+	default:
+		return newError(object.TYPE_ERROR, "slice operator not supported: %s", left.Type())
+	}
+
+	from, err := sliceBound(start, 0, length)
+	if err != nil {
+		return err
+	}
+
+	to, err := sliceBound(end, length, length)
+	if err != nil {
+		return err
+	}
+
+	if from > to {
+		from = to
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, to-from)
+		copy(elements, left.Elements[from:to])
+		return vm.push(&object.Array{Elements: elements})
+	default:
+		runes := []rune(left.(*object.String).Value)
+		return vm.push(&object.String{Value: string(runes[from:to])})
+	}
+}
+
+func sliceBound(bound object.Object, fallback, length int) (int, error) {
+	if bound == object.NULL {
+		return fallback, nil
+	}
+
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newError(object.TYPE_ERROR, "slice bound must be INTEGER, got %s", bound.Type())
+	}
+
+	i := integer.Value
+	if i < 0 {
+		i += int64(length)
+	}
+
+	if i < 0 {
+		return 0, nil
+	}
+
+	if i > int64(length) {
+		return length, nil
+	}
+
+	return int(i), nil
+}
+
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
-		return fmt.Errorf("unusable as hash key: %s", index.Type())
+		return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return vm.push(object.NULL)
 	}
@@ -486,13 +778,17 @@ func (vm *VM) executeCall(numArgs int) error {
 	case *object.Builtin:
 		return vm.callBuiltin(callee, numArgs)
 	default:
-		return fmt.Errorf("calling non-function and non-built-in")
+		return newError(object.TYPE_ERROR, "not a function: %s", callee.Type())
 	}
 }
 
 func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 	if numArgs != cl.Fn.NumParams {
-		return fmt.Errorf("wrong number of arguments: want = %d, got = %d", cl.Fn.NumParams, numArgs)
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments: want = %d, got = %d", cl.Fn.NumParams, numArgs)
+	}
+
+	if vm.framesIdx >= MaxFrames || vm.sp-numArgs+cl.Fn.NumLocals > StackSize {
+		return newError(object.GENERIC_ERROR, "stack overflow")
 	}
 
 	frame := NewFrame(cl, vm.sp-numArgs)
@@ -500,15 +796,31 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 
 	vm.sp = frame.bp + cl.Fn.NumLocals
 
+	// Clear the locals that are not parameters, so that values left on the
+	// stack by earlier calls are not mistaken for them.
+	for i := frame.bp + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+
 	return nil
 }
 
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
-	env := object.NewEnvironment()
+
+	env := object.NewSessionEnvironment(vm.session)
+	if scopeBuiltins[builtin] {
+		env = vm.environment()
+	}
 
 	result := builtin.Fn(env, args...)
 	vm.sp = vm.sp - numArgs - 1
+	if exited := vm.session.Exited(); exited != nil {
+		return &stopError{err: exited}
+	}
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
 	if result != nil {
 		vm.push(result)
 	} else {
@@ -517,6 +829,41 @@ func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	return nil
 }
 
+// scopeBuiltins are the builtins that inspect the variables of their
+// caller, which the interpreter passes them in its environment.
+var scopeBuiltins = map[*object.Builtin]bool{
+	object.GetBuiltinByName("locals").(*object.Builtin):  true,
+	object.GetBuiltinByName("globals").(*object.Builtin): true,
+}
+
+// environment returns the variables the current frame can see as the
+// interpreter would hold them: the globals, enclosing the locals of the
+// frame unless it is the main one. Variables that have not been assigned
+// yet are left out, and so are the unnamed slots of variables that were
+// defined again and of catch parameters.
+func (vm *VM) environment() *object.Environment {
+	globals := object.NewSessionEnvironment(vm.session)
+	for i, name := range vm.globalNames {
+		if name != "" && vm.globals[i] != nil {
+			globals.Set(name, vm.globals[i])
+		}
+	}
+
+	if vm.framesIdx == 1 {
+		return globals
+	}
+
+	env := object.NewLocalEnvironment(globals)
+	frame := vm.currentFrame()
+	for i, name := range frame.cl.Fn.LocalNames {
+		if v := vm.stack[frame.bp+i]; name != "" && v != nil {
+			env.Set(name, v)
+		}
+	}
+
+	return env
+}
+
 func (vm *VM) pushClosure(constIndex, numFree int) error {
 	constant := vm.constants[constIndex]
 
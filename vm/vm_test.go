@@ -1,7 +1,9 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"monkey/ast"
 	"monkey/compiler"
 	"monkey/lexer"
@@ -72,8 +74,22 @@ func runVmTests(t *testing.T, tests []vmTestCase) {
 			t.Fatalf("compiler error: %s", err)
 		}
 
+		if err := Verify(comp.Bytecode()); err != nil {
+			t.Fatalf("%s does not verify: %s", tt.input, err)
+		}
+
 		vm := New(comp.Bytecode())
-		if err := vm.Run(); err != nil {
+		err := vm.Run()
+
+		// Builtin errors are thrown, so an expected error must be uncaught.
+		if expected, ok := tt.expected.(*object.Error); ok {
+			if err == nil || err.Error() != expected.Message {
+				t.Errorf("wrong vm error. want=%q, got=%v", expected.Message, err)
+			}
+			continue
+		}
+
+		if err != nil {
 			t.Fatalf("vm error: %s", err)
 		}
 
@@ -131,15 +147,16 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 			return
 		}
 
-		if len(hash.Pairs) != len(expected) {
-			t.Errorf("hash has wrong number of Pairs. want = %d, got = %d", len(expected), len(hash.Pairs))
+		if hash.Len() != len(expected) {
+			t.Errorf("hash has wrong number of Pairs. want = %d, got = %d", len(expected), hash.Len())
 			return
 		}
 
-		for expectedKey, expectedValue := range expected {
-			pair, ok := hash.Pairs[expectedKey]
+		for _, pair := range hash.Pairs() {
+			expectedValue, ok := expected[pair.Key.(object.Hashable).HashKey()]
 			if !ok {
-				t.Errorf("no pair for given key in Pairs")
+				t.Errorf("unexpected key in Pairs: %s", pair.Key.Inspect())
+				continue
 			}
 
 			if err := testIntegerObject(expectedValue, pair.Value); err != nil {
@@ -147,6 +164,23 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 			}
 		}
 
+	case []string:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want = %d, got = %d", len(expected), len(array.Elements))
+			return
+		}
+		for i, expectedElem := range expected {
+			if err := testStringObject(expectedElem, array.Elements[i]); err != nil {
+				t.Errorf("testStringObject failed: %s", err)
+			}
+		}
+
 	case *object.Error:
 		errObj, ok := actual.(*object.Error)
 
@@ -229,6 +263,18 @@ func TestConditionals(t *testing.T) {
 		{"if (1 > 2) { 10 }", object.NULL},
 		{"if (false) { 10 }", object.NULL},
 		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { }", object.NULL},
+		{"if (false) { 10 } else { let a = 1; }", object.NULL},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTopLevelReturn(t *testing.T) {
+	tests := []vmTestCase{
+		{"return 10; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { return 10; } 1", 10},
 	}
 
 	runVmTests(t, tests)
@@ -508,6 +554,10 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 			t.Fatalf("compiler error: %s", err)
 		}
 
+		if err := Verify(comp.Bytecode()); err != nil {
+			t.Fatalf("%s does not verify: %s", tt.input, err)
+		}
+
 		vm := New(comp.Bytecode())
 
 		err := vm.Run()
@@ -696,3 +746,290 @@ func TestRecursiveFibonacci(t *testing.T) {
 	}
 	runVmTests(t, tests)
 }
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("héllo")`, 5},
+		{`split("a,b,c", ",")`, []string{"a", "b", "c"}},
+		{`split("abc", "")`, []string{"a", "b", "c"}},
+		{`join(["a", "b"], ", ")`, "a, b"},
+		{`join([], "-")`, ""},
+		{`join([1], "-")`, &object.Error{Message: "elements of `join` must be STRING, got INTEGER"}},
+		{`trim("  hi  ")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`trimLeft("  hi  ")`, "hi  "},
+		{`trimRight("  hi  ")`, "  hi"},
+		{`upper("Hello")`, "HELLO"},
+		{`lower("Hello")`, "hello"},
+		{`replace("aaa", "a", "b")`, "bbb"},
+		{`replace("aaa", "a", "b", 2)`, "bba"},
+		{`contains("hello", "ell")`, true},
+		{`contains("hello", "xyz")`, false},
+		{`contains([1, "a"], "a")`, true},
+		{`contains([1, "a"], 2)`, false},
+		{`startsWith("hello", "he")`, true},
+		{`endsWith("hello", "he")`, false},
+		{`indexOf("héllo", "l")`, 2},
+		{`indexOf("hello", "z")`, -1},
+		{`indexOf([3, 4, 5], 5)`, 2},
+		{`substr("héllo", 1, 3)`, "éll"},
+		{`substr("hello", -3)`, "llo"},
+		{`substr("hello", 2, 10)`, "llo"},
+		{`substr("abc", 1, 9223372036854775807)`, "bc"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, &object.Error{Message: "count of `repeat` must not be negative, got -1"}},
+		{`chars("hé")`, []string{"h", "é"}},
+		{`padLeft("7", 3, "0")`, "007"},
+		{`padRight("ab", 5, "xy")`, "abxyx"},
+		{`padLeft("long", 2)`, "long"},
+		{`repeat("ab", 9223372036854775807)`, &object.Error{Message: "result of `repeat` would be longer than 16777216 bytes"}},
+		{`padLeft("a", 99999999999)`, &object.Error{Message: "width of `padLeft` must be at most 16777216, got 99999999999"}},
+		{`upper(1)`, &object.Error{Message: "argument to `upper` must be STRING, got INTEGER"}},
+		{`upper("a", "b")`, &object.Error{Message: "wrong number of arguments. got = 2, want = 1"}},
+		{`trim()`, &object.Error{Message: "wrong number of arguments. got = 0, want = 1 or 2"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringIndexAndSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`"héllo"[1]`, "é"},
+		{`"hello"[5]`, object.NULL},
+		{`"hello"[-1]`, object.NULL},
+		{`"hello"[1:3]`, "el"},
+		{`"héllo"[-3:]`, "llo"},
+		{`"hello"[:-1]`, "hell"},
+		{`"hello"[3:1]`, ""},
+		{`"hello"[:]`, "hello"},
+		{`let s = "hello"; s[1:][0]`, "e"},
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3][:10]", []int{1, 2, 3}},
+		{"[1, 2, 3][-10:1]", []int{1}},
+		{"[1, 2, 3][2:1]", []int{}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []vmTestCase{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"ab" > "a"`, true},
+		{`"a" <= "a"`, true},
+		{`"a" >= "b"`, false},
+		{`let a = "x"; let b = "x"; a == b`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] != [1, 2, 3]", true},
+		{`[[1, "a"], []] == [[1, "a"], []]`, true},
+		{`{"a": [1], 2: true} == {2: true, "a": [1]}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} != {"b": 1}`, true},
+		{"[1] == 1", false},
+		{`deepEqual([1, {"a": [2]}], [1, {"a": [2]}])`, true},
+		{`deepEqual("a", "b")`, false},
+		{"let f = fn() { 1 }; f == f", true},
+		{"fn() { 1 } == fn() { 1 }", false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArrayHashKeys(t *testing.T) {
+	tests := []vmTestCase{
+		{`{[1, 2]: 5}[[1, 2]]`, 5},
+		{`{[1, 2]: 5}[[2, 1]]`, object.NULL},
+		{`let k = [1, ["a", true]]; {k: 5}[[1, ["a", true]]]`, 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHashOrdering(t *testing.T) {
+	runVmInspectTests(t, []vmTestCase{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, "{b: 1, a: 2, 3: 3, true: 4}"},
+		{`keys({"b": 1, "a": 2, 3: 3})`, "[b, a, 3]"},
+		{`values({"b": [1], "a": 2})`, "[[1], 2]"},
+		{`keys({[1, 2]: 1})[0][1]`, "2"},
+		{`keys({1: 1})[0] + 1`, "2"},
+	})
+}
+
+// runVmInspectTests compares the inspected result of every input with the
+// expected string.
+func runVmInspectTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		if err := Verify(comp.Bytecode()); err != nil {
+			t.Fatalf("%s does not verify: %s", tt.input, err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+
+		var got string
+		if errObj, ok := err.(*object.Error); ok {
+			got = errObj.Inspect()
+		} else if err != nil {
+			t.Fatalf("vm error: %s", err)
+		} else {
+			got = vm.LastPoppedStackElem().Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("wrong result for %s. want = %s, got = %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	runVmInspectTests(t, []vmTestCase{
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({[1]: 1}, [1])`, "true"},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, "{a: 1, c: 3}"},
+		{`delete({"a": 1}, "z")`, "{a: 1}"},
+		{`let h = {"a": 1}; delete(h, "a"); h`, "{a: 1}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`entries({"a": 1, 2: "b"})`, "[[a, 1], [2, b]]"},
+		{`fromEntries([["a", 1], [2, "b"]])`, "{a: 1, 2: b}"},
+		{`fromEntries(entries({"x": [1], "y": {}}))`, "{x: [1], y: {}}"},
+		{`let h = {"name": "monkey", "inner": {"x": 5}}; h.name`, "monkey"},
+		{`let h = {"inner": {"x": 5}}; h.inner.x`, "5"},
+		{`let h = {"f": fn(x) { x * 2 }}; h.f(4)`, "8"},
+		{`let h = {"a": [1, 2]}; h.a[1]`, "2"},
+		{`{"a": 1}.b`, "null"},
+		{`has(1, "a")`, "ERROR: argument to `has` must be HASH, got INTEGER"},
+		{`has({}, {})`, "ERROR: unusable as hash key: HASH"},
+		{`fromEntries([1])`, "ERROR: entries of `fromEntries` must be [key, value] pairs, got 1"},
+		{`merge({}, [])`, "ERROR: argument to `merge` must be HASH, got ARRAY"},
+	})
+}
+
+func TestJSON(t *testing.T) {
+	runVmInspectTests(t, []vmTestCase{
+		{`json.encode({"b": 1, "a": [true, first([]), "x"]})`, `{"b":1,"a":[true,null,"x"]}`},
+		{`json.encode({1: "one", true: "yes"})`, `{"1":"one","true":"yes"}`},
+		{`json.encode("<a href=\"\">")`, `"<a href=\"\">"`},
+		{`json.encode([1, {"a": []}], 2)`, "[\n  1,\n  {\n    \"a\": []\n  }\n]"},
+		{`json.encode({"a": 1}, "\t")`, "{\n\t\"a\": 1\n}"},
+		{`json.encode(1, -1)`, "ERROR: json.encode: indent must be between 0 and 16, got -1"},
+		{`json.encode(1, 9223372036854775807)`, "ERROR: json.encode: indent must be between 0 and 16, got 9223372036854775807"},
+		{`json.encode(fn(x) { x })`, "ERROR: json.encode: cannot encode FUNCTION"},
+		{`json.encode({[1]: 1})`, "ERROR: json.encode: unsupported hash key ARRAY"},
+		{`json.encode(len)`, "ERROR: json.encode: cannot encode BUILTIN"},
+		{`json.decode("{\"z\": 1, \"a\": [true, null, \"s\"], \"n\": {}}")`, "{z: 1, a: [true, null, s], n: {}}"},
+		{`json.decode("[1, 2, 3]")[2]`, "3"},
+		{`json.decode("1.5")`, "ERROR: json.decode: number 1.5 is not an integer"},
+		{`json.decode("[1,")`, "ERROR: json.decode: unexpected end of JSON input"},
+		{`json.decode("1 2")`, "ERROR: json.decode: unexpected data after top-level value"},
+		{`json.decode(json.encode({"k": ["v", -3]})).k[1]`, "-3"},
+		{`json.decode(1)`, "ERROR: argument to `json.decode` must be STRING, got INTEGER"},
+	})
+}
+
+func TestExceptions(t *testing.T) {
+	runVmInspectTests(t, []vmTestCase{
+		{`try { throw "boom"; 1 } catch (e) { e }`, "boom"},
+		{`try { 1 } catch (e) { 2 }`, "1"},
+		{`try { 1 + true } catch (e) { e.type }`, "TypeError"},
+		{`try { -true } catch (e) { [e.type, e.message] }`, "[TypeError, unknown operator: -BOOLEAN]"},
+		{`try { len(1) } catch (e) { e.message }`, "argument to `len` not supported, got INTEGER"},
+		{`try { len(1) } catch (e) { e.type }`, "Error"},
+		{`try { fn(x) { x }() } catch (e) { e.type }`, "ArgumentError"},
+		{`let f = fn() { throw {"code": 42}; 1 }; try { f() } catch (e) { e.code }`, "42"},
+		{`let f = fn(x) { if (x == 0) { throw "bottom" } f(x - 1) }; try { f(5) } catch (e) { e }`, "bottom"},
+		{`let r = try { throw 1 } catch (e) { 5 }; r * 2`, "10"},
+		{`1 + try { throw 1 } catch (e) { 2 }`, "3"},
+		{`try { 1 } finally { let done = true; }; done`, "true"},
+		{`try { throw 1 } catch (e) { e + 1 } finally { let done = 5; }`, "2"},
+		{`try { try { throw 1 } finally { let x = 0; } } catch (e) { e + 10 }`, "11"},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, "2"},
+		{`let f = fn() { try { throw 1 } catch (e) { return e + 1 } }; f() + 1`, "3"},
+		{`let f = fn() { try { return 1 } catch (e) { 100 } }; f(); throw 5`, "ERROR: uncaught exception: 5"},
+		{`try { throw 1 } catch (e) { throw e + 1 }`, "ERROR: uncaught exception: 2"},
+		{`try { 1 } catch (e) { 2 } finally { throw "late" }`, "ERROR: uncaught exception: late"},
+		{`throw "x"`, "ERROR: uncaught exception: x"},
+		{`try { {}[fn() {}] } catch (e) { e }`, "ERROR: unusable as hash key: FUNCTION"},
+		{`try { 1 / 0 } catch (e) { [e.type, e.message] }`, "[Error, division by zero]"},
+		{`let f = fn(x) { f(x + 1) }; try { f(0) } catch (e) { [e.type, e.message] }`, "[Error, stack overflow]"},
+		{`try { let x = x; } catch (e) { [e.type, e.message] }`, "[NameError, identifier not found: x]"},
+		{`let e = 1; try { throw 2 } catch (e) { e }; e`, "1"},
+		{`try { throw 2 } catch (e) { let y = e + 1 }; y`, "3"},
+		{`let f = fn() { let y = y + 1; }; try { f() } catch (e) { e.message }`, "identifier not found: y"},
+	})
+}
+
+// stepLimit stops programs that run for too long, such as ones that
+// recurse without end through many frames.
+type stepLimit int
+
+var errStepLimit = errors.New("step limit reached")
+
+func (n *stepLimit) Before(vm *VM, frame *Frame) error {
+	if *n--; *n < 0 {
+		return errStepLimit
+	}
+	return nil
+}
+
+func FuzzRun(f *testing.F) {
+	f.Add(`let add = fn add(x, y) { return x + y; }; add(1, 2 * 3)`)
+	f.Add(`let f = fn(a) { fn(b) { a / b } }; f(1)(0)`)
+	f.Add(`let f = fn(x) { f(x + 1) }; try { f(0) } catch (e) { e.message }`)
+	f.Add(`first([]); last([]); rest([]); [1, 2][-1:5]; "abc"[9]; {"a": 1}["b"]`)
+	f.Add(`try { throw [1] } catch (e) { e[0] } finally { puts(locals(), globals()) }`)
+	f.Add(`substr("abc", 1, 9223372036854775807); repeat("ab", 9223372036854775807); padLeft("a", 99999999999); json.encode(1, 9223372036854775807)`)
+	f.Add(`let f = fn() { try { exit(2) } catch (e) { e } finally { puts(1) } }; f(); puts(2)`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return
+		}
+
+		if err := Verify(comp.Bytecode()); err != nil {
+			t.Fatalf("%q does not verify: %s", input, err)
+		}
+
+		limit := stepLimit(100000)
+		vm := New(comp.Bytecode())
+		vm.SetHook(&limit)
+		discard(vm.Session())
+		vm.Run()
+	})
+}
+
+// discard makes the output of session go nowhere, and exit end only the
+// run.
+func discard(session *object.Session) {
+	session.Output = io.Discard
+	session.Exit = func(status int) {}
+}